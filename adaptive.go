@@ -0,0 +1,250 @@
+package scout
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/go-appsec/scout/sources"
+)
+
+// AdaptiveRateLimitOptions configures how per-source rate limits respond to provider feedback.
+// Limits decrease multiplicatively when the provider throttles a request and increase additively after
+// sustained success, never exceeding the source's configured or recommended rate.
+type AdaptiveRateLimitOptions struct {
+	// DecreaseFactor multiplies the limit when a request is throttled. Default is 0.5.
+	DecreaseFactor float64
+
+	// IncreaseStep is added to the limit, in requests/second, after SuccessThreshold successful responses in a row.
+	// Default is a tenth of the source's configured rate, or of InitialLimit for sources without one.
+	IncreaseStep float64
+
+	// SuccessThreshold is the number of successful responses in a row before the limit increases. Default is 10.
+	SuccessThreshold int
+
+	// MinLimit is the lowest limit in requests/second. Default is 0.01.
+	MinLimit float64
+
+	// InitialLimit is applied, in requests/second, when a source without a rate limit is first throttled. Default is 1.
+	InitialLimit float64
+
+	// MaxPause bounds how long a Retry-After header or an exhausted quota pauses a source. Default is 5 minutes.
+	MaxPause time.Duration
+}
+
+// withDefaults returns the options with zero values replaced by defaults.
+func (o AdaptiveRateLimitOptions) withDefaults() AdaptiveRateLimitOptions {
+	if o.DecreaseFactor <= 0 || o.DecreaseFactor >= 1 {
+		o.DecreaseFactor = 0.5
+	}
+	if o.SuccessThreshold <= 0 {
+		o.SuccessThreshold = 10
+	}
+	if o.MinLimit <= 0 {
+		o.MinLimit = 0.01
+	}
+	if o.InitialLimit <= 0 {
+		o.InitialLimit = 1
+	}
+	if o.MaxPause <= 0 {
+		o.MaxPause = 5 * time.Minute
+	}
+	return o
+}
+
+// adaptiveLimiter is a source rate limiter adjusted from the responses to its requests.
+type adaptiveLimiter struct {
+	limiter *rate.Limiter
+	now     func() time.Time
+
+	mu          sync.Mutex
+	opts        AdaptiveRateLimitOptions
+	ceiling     rate.Limit // Configured rate, the limit never increases beyond it
+	successes   int
+	pausedUntil time.Time
+}
+
+func newAdaptiveLimiter(ceiling rate.Limit, burst int, opts AdaptiveRateLimitOptions) *adaptiveLimiter {
+	return &adaptiveLimiter{
+		limiter: rate.NewLimiter(ceiling, burst),
+		now:     time.Now,
+		opts:    opts.withDefaults(),
+		ceiling: ceiling,
+	}
+}
+
+// configure updates the configured rate, burst, and options, keeping any reduction from throttling.
+func (a *adaptiveLimiter) configure(ceiling rate.Limit, burst int, opts AdaptiveRateLimitOptions) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.opts = opts.withDefaults()
+	a.ceiling = ceiling
+	if a.limiter.Limit() > ceiling {
+		a.limiter.SetLimit(ceiling)
+	}
+	if a.limiter.Burst() != burst {
+		a.limiter.SetBurst(burst)
+	}
+}
+
+// Limit returns the current limit in requests/second.
+func (a *adaptiveLimiter) Limit() rate.Limit {
+	return a.limiter.Limit()
+}
+
+// wait blocks until a pause set by the provider has passed and the limiter allows a request.
+func (a *adaptiveLimiter) wait(ctx context.Context) error {
+	a.mu.Lock()
+	pause := a.pausedUntil.Sub(a.now())
+	a.mu.Unlock()
+	if pause > 0 {
+		timer := time.NewTimer(pause)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return a.limiter.Wait(ctx)
+}
+
+// observe adjusts the limit from a response, returning the new limit and reason if it changed.
+func (a *adaptiveLimiter) observe(resp *http.Response) (rate.Limit, string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	remaining, reset, hasQuota := quotaHeaders(resp.Header, now)
+	if retryAfter, ok := ParseRetryAfter(resp.Header.Get("Retry-After"), now); ok {
+		a.pause(retryAfter, now)
+	} else if hasQuota && remaining == 0 {
+		a.pause(reset, now)
+	}
+
+	throttled := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable ||
+		(resp.StatusCode == http.StatusForbidden && hasQuota && remaining == 0) // GitHub reports exhausted quotas as 403
+	current := a.limiter.Limit()
+	switch {
+	case throttled:
+		a.successes = 0
+		next := rate.Limit(a.opts.InitialLimit)
+		if current != rate.Inf {
+			next = max(current*rate.Limit(a.opts.DecreaseFactor), rate.Limit(a.opts.MinLimit))
+		}
+		return a.set(next, "throttled")
+	case resp.StatusCode >= 400:
+		return current, ""
+	}
+
+	// Pace requests to spread the remaining quota until it resets
+	if hasQuota && remaining > 0 {
+		if window := reset.Sub(now).Seconds(); window > 0 {
+			if pace := rate.Limit(float64(remaining) / window); pace < current {
+				a.successes = 0
+				return a.set(max(pace, rate.Limit(a.opts.MinLimit)), "quota")
+			}
+		}
+	}
+
+	a.successes++
+	if a.successes < a.opts.SuccessThreshold || current >= a.ceiling {
+		return current, ""
+	}
+	a.successes = 0
+	step := a.opts.IncreaseStep
+	if step <= 0 {
+		base := float64(a.ceiling)
+		if a.ceiling == rate.Inf {
+			base = a.opts.InitialLimit
+		}
+		step = base / 10
+	}
+	return a.set(min(current+rate.Limit(step), a.ceiling), "recovered")
+}
+
+// set changes the limit, returning it and the reason, or an empty reason if the limit is unchanged.
+func (a *adaptiveLimiter) set(limit rate.Limit, reason string) (rate.Limit, string) {
+	if limit == a.limiter.Limit() {
+		return limit, ""
+	}
+	a.limiter.SetLimit(limit)
+	return limit, reason
+}
+
+// pause stops requests until the given time, bounded by MaxPause.
+func (a *adaptiveLimiter) pause(until, now time.Time) {
+	if limit := now.Add(a.opts.MaxPause); until.After(limit) {
+		until = limit
+	}
+	if until.After(a.pausedUntil) {
+		a.pausedUntil = until
+	}
+}
+
+// quotaHeaders reads the remaining requests and reset time from X-RateLimit-* headers, as sent by GitHub,
+// or the standardized RateLimit-* headers. The reset is accepted as a Unix time or as seconds from now.
+func quotaHeaders(h http.Header, now time.Time) (remaining int, reset time.Time, ok bool) {
+	for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+		value := h.Get(prefix + "Remaining")
+		if value == "" {
+			continue
+		}
+		remaining, err := strconv.Atoi(value)
+		if err != nil || remaining < 0 {
+			continue
+		}
+		seconds, err := strconv.ParseInt(h.Get(prefix+"Reset"), 10, 64)
+		if err != nil || seconds < 0 {
+			continue
+		}
+		// Delays are small, a Unix time is far larger than any sensible delay
+		if seconds > 1_000_000_000 {
+			return remaining, time.Unix(seconds, 0), true
+		}
+		return remaining, now.Add(time.Duration(seconds) * time.Second), true
+	}
+	return 0, time.Time{}, false
+}
+
+// adaptiveTransport wraps an http.RoundTripper to apply an adaptive rate limit.
+type adaptiveTransport struct {
+	base     http.RoundTripper
+	limiter  *adaptiveLimiter
+	observer Observer
+}
+
+func (t *adaptiveTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	start := time.Now()
+	if err := t.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	source, _ := ctx.Value(sourceContextKey{}).(string)
+	if t.observer != nil {
+		now := time.Now()
+		t.observer.RateLimitWait(RateLimitEvent{Source: source, Wait: now.Sub(start), Time: now})
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if limit, reason := t.limiter.observe(resp); reason != "" {
+		sources.Logger(ctx).DebugContext(ctx, "rate limit adjusted", "limit", float64(limit), "reason", reason, "status", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// adaptiveLimitMiddleware returns middleware applying an adaptive rate limit to all requests.
+// If observer is non-nil, time spent waiting on the limiter is reported to it.
+func adaptiveLimitMiddleware(limiter *adaptiveLimiter, observer Observer) Middleware {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return &adaptiveTransport{base: rt, limiter: limiter, observer: observer}
+	}
+}
@@ -0,0 +1,217 @@
+package scout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+
+	"github.com/go-appsec/scout/sources"
+)
+
+// testAdaptiveLimiter creates an adaptive limiter with a fixed clock.
+func testAdaptiveLimiter(ceiling rate.Limit, opts AdaptiveRateLimitOptions) (*adaptiveLimiter, time.Time) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	a := newAdaptiveLimiter(ceiling, 1, opts)
+	a.now = func() time.Time { return now }
+	return a, now
+}
+
+// response creates a response with the status and header name, value pairs.
+func response(status int, headers ...string) *http.Response {
+	resp := &http.Response{StatusCode: status, Header: make(http.Header)}
+	for i := 0; i+1 < len(headers); i += 2 {
+		resp.Header.Set(headers[i], headers[i+1])
+	}
+	return resp
+}
+
+func TestAdaptiveLimiterObserve(t *testing.T) {
+	t.Parallel()
+
+	t.Run("decreases_on_throttle", func(t *testing.T) {
+		a, _ := testAdaptiveLimiter(4, AdaptiveRateLimitOptions{})
+
+		limit, reason := a.observe(response(http.StatusTooManyRequests))
+		assert.Equal(t, rate.Limit(2), limit)
+		assert.Equal(t, "throttled", reason)
+
+		a.observe(response(http.StatusServiceUnavailable))
+		assert.Equal(t, rate.Limit(1), a.Limit())
+	})
+
+	t.Run("min_limit", func(t *testing.T) {
+		a, _ := testAdaptiveLimiter(1, AdaptiveRateLimitOptions{MinLimit: 0.4})
+
+		a.observe(response(http.StatusTooManyRequests))
+		a.observe(response(http.StatusTooManyRequests))
+		assert.InDelta(t, 0.4, float64(a.Limit()), 0.001)
+	})
+
+	t.Run("unlimited_uses_initial_limit", func(t *testing.T) {
+		a, _ := testAdaptiveLimiter(rate.Inf, AdaptiveRateLimitOptions{InitialLimit: 3})
+
+		a.observe(response(http.StatusOK))
+		assert.Equal(t, rate.Inf, a.Limit())
+
+		a.observe(response(http.StatusTooManyRequests))
+		assert.Equal(t, rate.Limit(3), a.Limit())
+	})
+
+	t.Run("recovers_after_sustained_success", func(t *testing.T) {
+		a, _ := testAdaptiveLimiter(4, AdaptiveRateLimitOptions{SuccessThreshold: 3})
+		a.observe(response(http.StatusTooManyRequests))
+		require.Equal(t, rate.Limit(2), a.Limit())
+
+		for range 2 {
+			_, reason := a.observe(response(http.StatusOK))
+			assert.Empty(t, reason)
+		}
+		limit, reason := a.observe(response(http.StatusOK))
+		assert.InDelta(t, 2.4, float64(limit), 0.001)
+		assert.Equal(t, "recovered", reason)
+
+		for range 30 {
+			a.observe(response(http.StatusOK))
+		}
+		assert.Equal(t, rate.Limit(4), a.Limit())
+	})
+
+	t.Run("client_errors_ignored", func(t *testing.T) {
+		a, _ := testAdaptiveLimiter(4, AdaptiveRateLimitOptions{SuccessThreshold: 2, IncreaseStep: 1})
+		a.observe(response(http.StatusTooManyRequests))
+
+		a.observe(response(http.StatusOK))
+		a.observe(response(http.StatusNotFound))
+		a.observe(response(http.StatusOK))
+		assert.Equal(t, rate.Limit(3), a.Limit())
+	})
+
+	t.Run("retry_after_seconds", func(t *testing.T) {
+		a, now := testAdaptiveLimiter(4, AdaptiveRateLimitOptions{})
+
+		a.observe(response(http.StatusTooManyRequests, "Retry-After", "30"))
+		assert.Equal(t, now.Add(30*time.Second), a.pausedUntil)
+	})
+
+	t.Run("retry_after_date", func(t *testing.T) {
+		a, now := testAdaptiveLimiter(4, AdaptiveRateLimitOptions{})
+
+		a.observe(response(http.StatusServiceUnavailable, "Retry-After", now.Add(time.Minute).Format(http.TimeFormat)))
+		assert.Equal(t, now.Add(time.Minute), a.pausedUntil)
+	})
+
+	t.Run("max_pause", func(t *testing.T) {
+		a, now := testAdaptiveLimiter(4, AdaptiveRateLimitOptions{MaxPause: time.Minute})
+
+		a.observe(response(http.StatusTooManyRequests, "Retry-After", "86400"))
+		assert.Equal(t, now.Add(time.Minute), a.pausedUntil)
+	})
+
+	t.Run("github_exhausted_quota", func(t *testing.T) {
+		a, now := testAdaptiveLimiter(4, AdaptiveRateLimitOptions{})
+		reset := now.Add(90 * time.Second)
+
+		_, reason := a.observe(response(http.StatusForbidden,
+			"X-RateLimit-Remaining", "0", "X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10)))
+		assert.Equal(t, "throttled", reason)
+		assert.WithinDuration(t, reset, a.pausedUntil, 0)
+	})
+
+	t.Run("forbidden_without_quota", func(t *testing.T) {
+		a, _ := testAdaptiveLimiter(4, AdaptiveRateLimitOptions{})
+
+		a.observe(response(http.StatusForbidden))
+		assert.Equal(t, rate.Limit(4), a.Limit())
+		assert.True(t, a.pausedUntil.IsZero())
+	})
+
+	t.Run("paces_remaining_quota", func(t *testing.T) {
+		a, _ := testAdaptiveLimiter(4, AdaptiveRateLimitOptions{})
+
+		limit, reason := a.observe(response(http.StatusOK, "RateLimit-Remaining", "10", "RateLimit-Reset", "20"))
+		assert.Equal(t, rate.Limit(0.5), limit)
+		assert.Equal(t, "quota", reason)
+
+		_, reason = a.observe(response(http.StatusOK, "RateLimit-Remaining", "100", "RateLimit-Reset", "20"))
+		assert.Empty(t, reason)
+	})
+}
+
+func TestAdaptiveLimiterConfigure(t *testing.T) {
+	t.Parallel()
+
+	limiters := NewRateLimiters()
+	a := limiters.adaptiveLimiter("src", 4, 1, AdaptiveRateLimitOptions{})
+	a.observe(response(http.StatusTooManyRequests))
+
+	// A later query keeps the reduced limit, but a lower configured rate applies immediately
+	assert.Same(t, a, limiters.adaptiveLimiter("src", 4, 2, AdaptiveRateLimitOptions{}))
+	assert.Equal(t, rate.Limit(2), a.Limit())
+	assert.Equal(t, 2, a.limiter.Burst())
+	limiters.adaptiveLimiter("src", 1, 2, AdaptiveRateLimitOptions{})
+	assert.Equal(t, rate.Limit(1), a.Limit())
+}
+
+func TestQuotaHeaders(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1_700_000_000, 0)
+	tests := []struct {
+		name          string
+		headers       []string
+		wantRemaining int
+		wantReset     time.Time
+		wantOK        bool
+	}{
+		{name: "none"},
+		{name: "unix_reset", headers: []string{"X-RateLimit-Remaining", "5", "X-RateLimit-Reset", "1700000060"},
+			wantRemaining: 5, wantReset: now.Add(time.Minute), wantOK: true},
+		{name: "delay_reset", headers: []string{"RateLimit-Remaining", "0", "RateLimit-Reset", "30"},
+			wantReset: now.Add(30 * time.Second), wantOK: true},
+		{name: "missing_reset", headers: []string{"X-RateLimit-Remaining", "5"}},
+		{name: "invalid_remaining", headers: []string{"X-RateLimit-Remaining", "many", "X-RateLimit-Reset", "30"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remaining, reset, ok := quotaHeaders(response(http.StatusOK, tt.headers...).Header, now)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantRemaining, remaining)
+			assert.Equal(t, tt.wantReset, reset)
+		})
+	}
+}
+
+func TestQueryAdaptiveRateLimit(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	src := requestingSource("adaptive", server.URL, 2)
+	src.RateLimit = 50
+	var summary *Summary
+	_, err := Collect(Query(t.Context(), "example.com",
+		WithSources([]sources.Source{src}),
+		WithHTTPClient(server.Client()),
+		WithAdaptiveRateLimit(AdaptiveRateLimitOptions{}),
+		WithSummary(func(s *Summary) { summary = s }),
+	))
+	require.NoError(t, err)
+
+	require.NotNil(t, summary)
+	assert.Equal(t, int32(2), requests.Load())
+	assert.InDelta(t, 25, summary.Source("adaptive").RateLimit, 0.001)
+}
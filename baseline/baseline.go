@@ -0,0 +1,263 @@
+// Package baseline compares query results against a previous run, yielding only new results.
+//
+// Each completed run replaces the stored baseline, so repeated runs report only what changed since the last one.
+// Results from sources which did not complete cleanly are kept in the baseline,
+// so a failing source does not cause its assets to be reported as removed and then new again.
+// With URL reduction, URLs the reduction may have dropped are kept likewise.
+package baseline
+
+import (
+	"context"
+	"iter"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/go-appsec/scout"
+	"github.com/go-appsec/scout/cluster"
+	"github.com/go-appsec/scout/normalize"
+	"github.com/go-appsec/scout/sources"
+)
+
+// Changes describes the difference between a run and the baseline.
+type Changes struct {
+	Domain          string           // Queried domain
+	Initial         bool             // The baseline was empty, so every result was added
+	Added           []sources.Result // Results not present in the baseline
+	Removed         []sources.Result // Baseline results not found by this run, as recorded in the baseline
+	Unchanged       int              // Results present in both the baseline and this run
+	Retained        int              // Baseline results kept because their source did not complete cleanly, or URL reduction may have dropped them
+	AddedBySource   map[string]int   // Count of added results by source
+	RemovedBySource map[string]int   // Count of removed results by the source recorded in the baseline
+}
+
+// Sources returns the names of sources with added or removed results, sorted.
+func (c *Changes) Sources() []string {
+	names := slices.Collect(maps.Keys(c.AddedBySource))
+	for name := range c.RemovedBySource {
+		if _, ok := c.AddedBySource[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// config holds the Baseline options.
+type config struct {
+	onChanges  func(*Changes)
+	beforeSave func(context.Context, *Changes) error
+	types      sources.ResultType
+}
+
+// Option configures a Baseline.
+type Option func(*config)
+
+// WithChanges sets a callback which receives the changes once a run completes and the baseline is saved.
+// It is not called when iteration stops early or the context is cancelled, the baseline is left unchanged in that case.
+func WithChanges(fn func(*Changes)) Option {
+	return func(c *config) {
+		c.onChanges = fn
+	}
+}
+
+// WithBeforeSave sets a callback which receives the changes once a run completes, before the baseline is saved,
+// such as to deliver them. If it returns an error, the error is yielded and the baseline is left unchanged,
+// so the next run reports the same changes again.
+func WithBeforeSave(fn func(context.Context, *Changes) error) Option {
+	return func(c *config) {
+		c.beforeSave = fn
+	}
+}
+
+// WithTypes compares only results of the types, such as when a run asks for subdomains of sources yielding URLs too.
+// Results of other types are dropped rather than recorded, and baseline results of other types are kept unchanged.
+func WithTypes(types sources.ResultType) Option {
+	return func(c *config) {
+		c.types = types
+	}
+}
+
+// compared reports whether results of a type are compared against the baseline.
+func (c *config) compared(t sources.ResultType) bool {
+	return c.types == 0 || t&c.types != 0
+}
+
+// Baseline runs queries which yield only results not present in a stored baseline.
+type Baseline struct {
+	store Store
+	cfg   config
+}
+
+// New creates a Baseline backed by the store.
+func New(store Store, opts ...Option) *Baseline {
+	b := &Baseline{store: store}
+	for _, opt := range opts {
+		opt(&b.cfg)
+	}
+	return b
+}
+
+// keyOf identifies a result for comparison by the key it is deduplicated by in a query.
+func keyOf(r sources.Result) string {
+	return scout.DedupeKey(r.Type, r.Value)
+}
+
+// Query runs scout.Query, yielding only results which are not in the baseline, along with all errors.
+// Once the query completes the baseline is replaced with the results of this run, and errors saving it are yielded.
+// The baseline is not updated if iteration stops early or the context is cancelled.
+func (b *Baseline) Query(ctx context.Context, domain string, opts ...scout.Option) iter.Seq2[sources.Result, error] {
+	return func(yield func(sources.Result, error) bool) {
+		previous, err := b.store.Load()
+		if err != nil {
+			yield(sources.Result{}, err)
+			return
+		}
+		resolved := scout.ResolveOptions(opts...)
+		previous = normalizeURLs(previous, resolved.URLNormalization)
+		known := make(map[string]struct{}, len(previous))
+		var current []sources.Result
+		for _, r := range previous {
+			if b.cfg.compared(r.Type) {
+				known[keyOf(r)] = struct{}{}
+			} else {
+				current = append(current, r) // not queried, so neither found nor missing
+			}
+		}
+
+		// Source outcomes decide whether missing results were removed, forward the summary to any existing callback
+		var summary *scout.Summary
+		opts = append(opts, scout.WithSummary(func(s *scout.Summary) {
+			summary = s
+			if resolved.OnSummary != nil {
+				resolved.OnSummary(s)
+			}
+		}))
+
+		changes := &Changes{
+			Domain:          domain,
+			Initial:         len(previous) == 0,
+			AddedBySource:   make(map[string]int),
+			RemovedBySource: make(map[string]int),
+		}
+		seen := make(map[string]bool) // keys found by this run, true if added
+		for result, err := range scout.Query(ctx, domain, opts...) {
+			if err != nil {
+				if !yield(result, err) {
+					return
+				}
+				continue
+			} else if !b.cfg.compared(result.Type) {
+				continue
+			}
+			k := keyOf(result)
+			if added, ok := seen[k]; ok {
+				// repeated without deduplication, yielded like the first copy but counted and saved once
+				if added && !yield(result, nil) {
+					return
+				}
+				continue
+			}
+			current = append(current, result)
+			if _, ok := known[k]; ok {
+				delete(known, k) // remaining entries were not found by this run
+				seen[k] = false
+				changes.Unchanged++
+				continue
+			}
+			seen[k] = true
+			changes.Added = append(changes.Added, result)
+			changes.AddedBySource[result.Source]++
+			if !yield(result, nil) {
+				return
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		reduced := reducedBy(resolved.URLReduction, summary)
+		for _, r := range previous {
+			k := keyOf(r)
+			if _, ok := known[k]; !ok {
+				continue
+			}
+			delete(known, k) // the baseline may contain duplicates
+			var stats *scout.SourceStats
+			if summary != nil {
+				stats = summary.Source(r.Source)
+			}
+			if stats == nil || stats.Status != scout.StatusRan || reduced(r) {
+				current = append(current, r)
+				changes.Retained++
+			} else {
+				changes.Removed = append(changes.Removed, r)
+				changes.RemovedBySource[r.Source]++
+			}
+		}
+
+		slices.SortFunc(current, func(a, b sources.Result) int {
+			if a.Type != b.Type {
+				return int(a.Type) - int(b.Type)
+			}
+			return strings.Compare(a.Value, b.Value)
+		})
+		if b.cfg.beforeSave != nil {
+			if err := b.cfg.beforeSave(ctx, changes); err != nil {
+				yield(sources.Result{}, err)
+				return
+			}
+		}
+		if err := b.store.Save(current); err != nil {
+			yield(sources.Result{}, err)
+			return
+		}
+		if b.cfg.onChanges != nil {
+			b.cfg.onChanges(changes)
+		}
+	}
+}
+
+// normalizeURLs returns the results with URLs put into the canonical form the query yields them in,
+// so baselines written before normalization, or with raw URLs, compare equal to the results of this run.
+func normalizeURLs(results []sources.Result, opts *normalize.URLOptions) []sources.Result {
+	if opts == nil {
+		return results
+	}
+	normalized := slices.Clone(results)
+	for i, r := range normalized {
+		if r.Type != sources.URL {
+			continue
+		} else if value, err := normalize.URL(r.Value, *opts); err == nil {
+			normalized[i].Value = value
+		}
+	}
+	return normalized
+}
+
+// reducedBy returns whether URL reduction may have dropped a result from the run rather than it not being found:
+// URLs with an excluded extension, which are never yielded, and URLs in a cluster the run found.
+func reducedBy(opts *cluster.Options, summary *scout.Summary) func(sources.Result) bool {
+	if opts == nil {
+		return func(sources.Result) bool { return false }
+	}
+	reducer := cluster.NewReducer(*opts)
+	found := make(map[string]bool)
+	if summary != nil {
+		for _, c := range summary.Clusters {
+			found[c.Key] = true
+		}
+	}
+	return func(r sources.Result) bool {
+		if r.Type != sources.URL {
+			return false
+		} else if reducer.Excludes(r.Value) {
+			return true
+		}
+		c, err := cluster.Parse(r.Value)
+		if err != nil {
+			c.Key = r.Value // the reducer keeps such URLs in a cluster of their own
+		}
+		return found[c.Key]
+	}
+}
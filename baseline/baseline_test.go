@@ -0,0 +1,338 @@
+package baseline
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"maps"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-appsec/scout"
+	"github.com/go-appsec/scout/cluster"
+	"github.com/go-appsec/scout/sources"
+)
+
+// mockSource creates a test source yielding subdomains, followed by an error if non-nil.
+func mockSource(name string, values []string, err error) sources.Source {
+	return sources.Source{
+		Name:   name,
+		Yields: sources.Subdomain,
+		Run: func(_ context.Context, _ *http.Client, _ string, _ string) iter.Seq2[sources.Result, error] {
+			return func(yield func(sources.Result, error) bool) {
+				for _, v := range values {
+					if !yield(sources.Result{Type: sources.Subdomain, Value: v, Source: name}, nil) {
+						return
+					}
+				}
+				if err != nil {
+					yield(sources.Result{}, err)
+				}
+			}
+		},
+	}
+}
+
+// memoryStore keeps the baseline in memory, counting saves.
+type memoryStore struct {
+	results []sources.Result
+	saves   int
+	saveErr error
+}
+
+func (s *memoryStore) Load() ([]sources.Result, error) {
+	return s.results, nil
+}
+
+func (s *memoryStore) Save(results []sources.Result) error {
+	if s.saveErr != nil {
+		return s.saveErr
+	}
+	s.saves++
+	s.results = results
+	return nil
+}
+
+// values returns the values of results.
+func values(results []sources.Result) []string {
+	v := make([]string, len(results))
+	for i, r := range results {
+		v[i] = r.Value
+	}
+	return v
+}
+
+func TestBaselineQuery(t *testing.T) {
+	t.Parallel()
+
+	t.Run("yields_only_new", func(t *testing.T) {
+		store := NewFile(filepath.Join(t.TempDir(), "baseline.jsonl"))
+		var changes *Changes
+		b := New(store, WithChanges(func(c *Changes) { changes = c }))
+
+		first, err := scout.Collect(b.Query(t.Context(), "example.com",
+			scout.WithSources([]sources.Source{mockSource("crtsh", []string{"a.example.com", "b.example.com"}, nil)})))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"a.example.com", "b.example.com"}, values(first))
+		require.NotNil(t, changes)
+		assert.True(t, changes.Initial)
+		assert.Len(t, changes.Added, 2)
+
+		second, err := scout.Collect(b.Query(t.Context(), "example.com",
+			scout.WithSources([]sources.Source{mockSource("crtsh", []string{"A.example.com", "c.example.com"}, nil)})))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"c.example.com"}, values(second))
+		assert.Equal(t, "example.com", changes.Domain)
+		assert.False(t, changes.Initial)
+		assert.Equal(t, 1, changes.Unchanged)
+		assert.Equal(t, map[string]int{"crtsh": 1}, changes.AddedBySource)
+		assert.Equal(t, []string{"b.example.com"}, values(changes.Removed))
+		assert.Equal(t, map[string]int{"crtsh": 1}, changes.RemovedBySource)
+		assert.Equal(t, []string{"crtsh"}, changes.Sources())
+
+		saved, err := store.Load()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"A.example.com", "c.example.com"}, values(saved))
+	})
+
+	t.Run("retains_failed_source_results", func(t *testing.T) {
+		store := &memoryStore{results: []sources.Result{
+			{Type: sources.Subdomain, Value: "a.example.com", Source: "crtsh"},
+			{Type: sources.Subdomain, Value: "b.example.com", Source: "anubis"},
+			{Type: sources.Subdomain, Value: "c.example.com", Source: "retired"},
+		}}
+		var changes *Changes
+		b := New(store, WithChanges(func(c *Changes) { changes = c }))
+		srcErr := errors.New("anubis: unexpected status 503")
+
+		results, err := scout.Collect(b.Query(t.Context(), "example.com", scout.WithSources([]sources.Source{
+			mockSource("crtsh", nil, nil),
+			mockSource("anubis", nil, srcErr),
+		})))
+		require.ErrorIs(t, err, srcErr)
+		assert.Empty(t, results)
+
+		require.NotNil(t, changes)
+		assert.Equal(t, []string{"a.example.com"}, values(changes.Removed))
+		assert.Equal(t, 2, changes.Retained)
+		assert.Equal(t, []string{"b.example.com", "c.example.com"}, values(store.results))
+	})
+
+	t.Run("keys_match_dedupe", func(t *testing.T) {
+		store := &memoryStore{results: []sources.Result{
+			{Type: sources.URL, Value: "https://example.com/Admin", Source: "wayback"},
+			{Type: sources.Subdomain, Value: "api.example.com", Source: "wayback"},
+		}}
+		var changes *Changes
+		b := New(store, WithChanges(func(c *Changes) { changes = c }))
+		src := sources.Source{
+			Name:   "wayback",
+			Yields: sources.Subdomain | sources.URL,
+			Run: func(_ context.Context, _ *http.Client, _ string, _ string) iter.Seq2[sources.Result, error] {
+				return func(yield func(sources.Result, error) bool) {
+					_ = yield(sources.Result{Type: sources.URL, Value: "https://example.com/admin", Source: "wayback"}, nil) &&
+						yield(sources.Result{Type: sources.Subdomain, Value: "API.example.com.", Source: "wayback"}, nil)
+				}
+			},
+		}
+
+		results, err := scout.Collect(b.Query(t.Context(), "example.com", scout.WithSources([]sources.Source{src})))
+		require.NoError(t, err)
+
+		// URL paths are case-sensitive, hostnames are compared without case or a trailing dot
+		assert.Equal(t, []string{"https://example.com/admin"}, values(results))
+		require.NotNil(t, changes)
+		assert.Equal(t, []string{"https://example.com/Admin"}, values(changes.Removed))
+		assert.Equal(t, 1, changes.Unchanged)
+	})
+
+	t.Run("no_dedupe_counts_once", func(t *testing.T) {
+		srcs := []sources.Source{
+			mockSource("crtsh", []string{"api.example.com", "new.example.com"}, nil),
+			mockSource("otx", []string{"api.example.com", "new.example.com"}, nil),
+		}
+		store := &memoryStore{results: []sources.Result{{Type: sources.Subdomain, Value: "api.example.com", Source: "crtsh"}}}
+		var changes *Changes
+		b := New(store, WithChanges(func(c *Changes) { changes = c }))
+
+		results, err := scout.Collect(b.Query(t.Context(), "example.com",
+			scout.WithSources(srcs), scout.WithDedupe(scout.NoDedupe())))
+		require.NoError(t, err)
+
+		// Each copy of the new value is yielded, but the value is added, and saved, once
+		assert.Equal(t, []string{"new.example.com", "new.example.com"}, values(results))
+		require.NotNil(t, changes)
+		assert.Len(t, changes.Added, 1)
+		assert.Equal(t, []int{1}, slices.Collect(maps.Values(changes.AddedBySource)))
+		assert.Equal(t, 1, changes.Unchanged)
+		assert.Empty(t, changes.Removed)
+		assert.Equal(t, []string{"api.example.com", "new.example.com"}, values(store.results))
+	})
+
+	t.Run("retains_reduced_urls", func(t *testing.T) {
+		store := &memoryStore{results: []sources.Result{
+			{Type: sources.URL, Value: "https://example.com/gone", Source: "wayback"},
+			{Type: sources.URL, Value: "https://example.com/logo.png", Source: "wayback"},
+			{Type: sources.URL, Value: "https://example.com/product?id=1", Source: "wayback"},
+		}}
+		var changes *Changes
+		b := New(store, WithChanges(func(c *Changes) { changes = c }))
+		src := sources.Source{
+			Name:   "wayback",
+			Yields: sources.URL,
+			Run: func(_ context.Context, _ *http.Client, _ string, _ string) iter.Seq2[sources.Result, error] {
+				return func(yield func(sources.Result, error) bool) {
+					for _, v := range []string{"https://example.com/product?id=2", "https://example.com/product?id=1", "https://example.com/logo.png"} {
+						if !yield(sources.Result{Type: sources.URL, Value: v, Source: "wayback"}, nil) {
+							return
+						}
+					}
+				}
+			},
+		}
+
+		results, err := scout.Collect(b.Query(t.Context(), "example.com", scout.WithSources([]sources.Source{src}),
+			scout.WithURLReduction(cluster.Options{Samples: 1})))
+		require.NoError(t, err)
+
+		// The excluded image and the product URL beyond the cluster's sample were found, only dropped
+		assert.Equal(t, []string{"https://example.com/product?id=2"}, values(results))
+		require.NotNil(t, changes)
+		assert.Equal(t, []string{"https://example.com/gone"}, values(changes.Removed))
+		assert.Equal(t, 2, changes.Retained)
+		assert.Equal(t, []string{
+			"https://example.com/logo.png", "https://example.com/product?id=1", "https://example.com/product?id=2",
+		}, values(store.results))
+	})
+
+	t.Run("compares_only_types", func(t *testing.T) {
+		store := &memoryStore{results: []sources.Result{
+			{Type: sources.URL, Value: "https://example.com/old", Source: "wayback"},
+			{Type: sources.Subdomain, Value: "old.example.com", Source: "wayback"},
+		}}
+		var changes *Changes
+		b := New(store, WithTypes(sources.Subdomain), WithChanges(func(c *Changes) { changes = c }))
+		src := sources.Source{
+			Name:   "wayback",
+			Yields: sources.Subdomain | sources.URL,
+			Run: func(_ context.Context, _ *http.Client, _ string, _ string) iter.Seq2[sources.Result, error] {
+				return func(yield func(sources.Result, error) bool) {
+					_ = yield(sources.Result{Type: sources.URL, Value: "https://example.com/new", Source: "wayback"}, nil) &&
+						yield(sources.Result{Type: sources.Subdomain, Value: "new.example.com", Source: "wayback"}, nil)
+				}
+			},
+		}
+
+		results, err := scout.Collect(b.Query(t.Context(), "example.com", scout.WithSources([]sources.Source{src})))
+		require.NoError(t, err)
+
+		// The new URL is not recorded, and the old URL is neither removed nor compared
+		assert.Equal(t, []string{"new.example.com"}, values(results))
+		require.NotNil(t, changes)
+		assert.Equal(t, []string{"old.example.com"}, values(changes.Removed))
+		assert.Equal(t, []string{"new.example.com", "https://example.com/old"}, values(store.results))
+	})
+
+	t.Run("normalizes_stored_urls", func(t *testing.T) {
+		stored := []sources.Result{
+			{Type: sources.URL, Value: "HTTPS://Example.com:443/a?b=2&a=1", Source: "wayback"},
+			{Type: sources.URL, Value: "https://example.com/gone", Source: "wayback"},
+		}
+		src := sources.Source{
+			Name:   "wayback",
+			Yields: sources.URL,
+			Run: func(_ context.Context, _ *http.Client, _ string, _ string) iter.Seq2[sources.Result, error] {
+				return func(yield func(sources.Result, error) bool) {
+					yield(sources.Result{Type: sources.URL, Value: "https://example.com/a?a=1&b=2", Source: "wayback"}, nil)
+				}
+			},
+		}
+
+		var changes *Changes
+		store := &memoryStore{results: slices.Clone(stored)}
+		results, err := scout.Collect(New(store, WithChanges(func(c *Changes) { changes = c })).Query(t.Context(), "example.com",
+			scout.WithSources([]sources.Source{src})))
+		require.NoError(t, err)
+
+		// A baseline written before normalization compares equal to the normalized run
+		assert.Empty(t, results)
+		require.NotNil(t, changes)
+		assert.Equal(t, 1, changes.Unchanged)
+		assert.Equal(t, []string{"https://example.com/gone"}, values(changes.Removed))
+		assert.Equal(t, []string{"https://example.com/a?a=1&b=2"}, values(store.results))
+
+		// With raw URLs the stored values are compared as written
+		store = &memoryStore{results: slices.Clone(stored)}
+		results, err = scout.Collect(New(store).Query(t.Context(), "example.com",
+			scout.WithSources([]sources.Source{src}), scout.WithRawURLs()))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"https://example.com/a?a=1&b=2"}, values(results))
+	})
+
+	t.Run("forwards_summary", func(t *testing.T) {
+		var summary *scout.Summary
+		b := New(&memoryStore{})
+
+		_, err := scout.Collect(b.Query(t.Context(), "example.com",
+			scout.WithSources([]sources.Source{mockSource("crtsh", []string{"a.example.com"}, nil)}),
+			scout.WithSummary(func(s *scout.Summary) { summary = s }),
+		))
+		require.NoError(t, err)
+
+		require.NotNil(t, summary)
+		assert.Equal(t, int64(1), summary.Results)
+	})
+
+	t.Run("early_stop_keeps_baseline", func(t *testing.T) {
+		store := &memoryStore{}
+		var called bool
+		b := New(store, WithChanges(func(*Changes) { called = true }))
+
+		for range b.Query(t.Context(), "example.com",
+			scout.WithSources([]sources.Source{mockSource("crtsh", []string{"a.example.com", "b.example.com"}, nil)})) {
+			break
+		}
+
+		assert.Zero(t, store.saves)
+		assert.False(t, called)
+	})
+
+	t.Run("save_error", func(t *testing.T) {
+		saveErr := errors.New("baseline: disk full")
+		b := New(&memoryStore{saveErr: saveErr})
+
+		results, err := scout.Collect(b.Query(t.Context(), "example.com",
+			scout.WithSources([]sources.Source{mockSource("crtsh", []string{"a.example.com"}, nil)})))
+
+		assert.Len(t, results, 1)
+		assert.ErrorIs(t, err, saveErr)
+	})
+	t.Run("before_save_error", func(t *testing.T) {
+		store := &memoryStore{}
+		var delivered *Changes
+		var called bool
+		deliverErr := errors.New("webhook: unexpected status 503")
+		b := New(store,
+			WithBeforeSave(func(_ context.Context, c *Changes) error {
+				delivered = c
+				return deliverErr
+			}),
+			WithChanges(func(*Changes) { called = true }))
+
+		results, err := scout.Collect(b.Query(t.Context(), "example.com",
+			scout.WithSources([]sources.Source{mockSource("crtsh", []string{"a.example.com"}, nil)})))
+
+		// The changes are reported again by the next run
+		assert.Len(t, results, 1)
+		assert.ErrorIs(t, err, deliverErr)
+		require.NotNil(t, delivered)
+		assert.Equal(t, []string{"a.example.com"}, values(delivered.Added))
+		assert.Zero(t, store.saves)
+		assert.False(t, called)
+	})
+}
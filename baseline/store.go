@@ -0,0 +1,103 @@
+package baseline
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-appsec/scout"
+	"github.com/go-appsec/scout/output"
+	"github.com/go-appsec/scout/sources"
+)
+
+// Store persists the results of a previous run.
+// A store holds the baseline for a single queried domain.
+type Store interface {
+	// Load returns the baseline results, or none if no baseline has been saved.
+	Load() ([]sources.Result, error)
+	// Save replaces the baseline with the results.
+	Save(results []sources.Result) error
+}
+
+// File stores the baseline as JSON Lines, the same format written by the output JSONL encoder.
+// Output saved from a previous run with the JSONL encoder can be used as a baseline directly.
+type File struct {
+	path string
+}
+
+// NewFile returns a store using the file at path, which does not need to exist yet.
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+// Path returns the path of the baseline file.
+func (f *File) Path() string {
+	return f.path
+}
+
+// Modified returns when the baseline was last saved, or the zero time if it does not exist yet.
+func (f *File) Modified() (time.Time, error) {
+	info, err := os.Stat(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return time.Time{}, nil
+	} else if err != nil {
+		return time.Time{}, fmt.Errorf("baseline: %w", err)
+	}
+	return info.ModTime(), nil
+}
+
+// Load reads the baseline file, a missing file is an empty baseline.
+func (f *File) Load() ([]sources.Result, error) {
+	file, err := os.Open(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("baseline: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	results, err := scout.Collect(output.ReadJSONL(file))
+	if err != nil {
+		return nil, fmt.Errorf("baseline: %s: %w", f.path, err)
+	}
+	return results, nil
+}
+
+// Save atomically replaces the baseline file, writing to a temporary file in the same directory then renaming it.
+// Readers never observe a partially written baseline.
+func (f *File) Save(results []sources.Result) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), "."+filepath.Base(f.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("baseline: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	w := bufio.NewWriter(tmp)
+	enc := output.NewJSONL(w)
+	for _, r := range results {
+		if err = enc.Encode(r); err != nil {
+			return fmt.Errorf("baseline: %w", err)
+		}
+	}
+	if err = w.Flush(); err != nil {
+		return fmt.Errorf("baseline: %w", err)
+	} else if err = tmp.Sync(); err != nil {
+		return fmt.Errorf("baseline: %w", err)
+	} else if err = tmp.Close(); err != nil {
+		return fmt.Errorf("baseline: %w", err)
+	} else if err = os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("baseline: %w", err)
+	} else if err = os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("baseline: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package baseline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-appsec/scout/sources"
+)
+
+func TestFile(t *testing.T) {
+	t.Parallel()
+
+	t.Run("missing_is_empty", func(t *testing.T) {
+		store := NewFile(filepath.Join(t.TempDir(), "baseline.jsonl"))
+
+		results, err := store.Load()
+		require.NoError(t, err)
+		assert.Empty(t, results)
+		modified, err := store.Modified()
+		require.NoError(t, err)
+		assert.True(t, modified.IsZero())
+	})
+
+	t.Run("save_and_load", func(t *testing.T) {
+		dir := t.TempDir()
+		store := NewFile(filepath.Join(dir, "baseline.jsonl"))
+		want := []sources.Result{
+			{Type: sources.Subdomain, Value: "api.example.com", Source: "crtsh"},
+			{Type: sources.URL, Value: "https://example.com/", Source: "wayback", Parents: []string{"corp.example.com"}},
+		}
+
+		require.NoError(t, store.Save(want))
+		modified, err := store.Modified()
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now(), modified, time.Minute)
+		got, err := store.Load()
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Len(t, entries, 1) // temporary file renamed into place
+		assert.Equal(t, "baseline.jsonl", entries[0].Name())
+	})
+
+	t.Run("save_replaces", func(t *testing.T) {
+		store := NewFile(filepath.Join(t.TempDir(), "baseline.jsonl"))
+		require.NoError(t, store.Save([]sources.Result{{Type: sources.Subdomain, Value: "old.example.com", Source: "crtsh"}}))
+
+		require.NoError(t, store.Save([]sources.Result{{Type: sources.Subdomain, Value: "new.example.com", Source: "crtsh"}}))
+		got, err := store.Load()
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, "new.example.com", got[0].Value)
+	})
+
+	t.Run("reads_jsonl_output", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "results.jsonl")
+		require.NoError(t, os.WriteFile(path, []byte(
+			`{"type":"subdomain","value":"api.example.com","source":"crtsh"}`+"\n"), 0o600))
+
+		got, err := NewFile(path).Load()
+		require.NoError(t, err)
+		assert.Equal(t, []sources.Result{{Type: sources.Subdomain, Value: "api.example.com", Source: "crtsh"}}, got)
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "results.jsonl")
+		require.NoError(t, os.WriteFile(path, []byte("api.example.com\n"), 0o600))
+
+		_, err := NewFile(path).Load()
+		assert.ErrorContains(t, err, "line 1")
+	})
+
+	t.Run("save_missing_dir", func(t *testing.T) {
+		store := NewFile(filepath.Join(t.TempDir(), "missing", "baseline.jsonl"))
+
+		assert.Error(t, store.Save(nil))
+	})
+}
@@ -0,0 +1,230 @@
+package scout
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned for sources skipped because their circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit open")
+
+// CircuitState indicates whether a source is allowed to run.
+type CircuitState uint8
+
+const (
+	CircuitClosed   CircuitState = iota // Source runs normally
+	CircuitOpen                         // Source is skipped after repeated failures
+	CircuitHalfOpen                     // A single probe run is allowed to test recovery
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerOptions configures when a source circuit trips and recovers.
+type CircuitBreakerOptions struct {
+	// ConsecutiveFailures trips the circuit after this many failed runs in a row. Default is 3.
+	ConsecutiveFailures int
+
+	// ErrorRate trips the circuit when the ratio of failed runs within Window reaches this value. Default is 0 (disabled).
+	ErrorRate float64
+
+	// Window is the number of recent runs considered for ErrorRate. The rate is only evaluated once the window is full.
+	// Default is 10.
+	Window int
+
+	// Cooldown is how long a circuit stays open before a probe run is allowed. Default is 5 minutes.
+	Cooldown time.Duration
+}
+
+// CircuitStatus is a snapshot of a source circuit.
+type CircuitStatus struct {
+	State               CircuitState // Current circuit state
+	ConsecutiveFailures int          // Failed runs in a row
+	FailureRate         float64      // Ratio of failed runs within the window
+	OpenedAt            time.Time    // When the circuit last tripped, zero if never
+	LastError           error        // Error from the most recent failed run
+}
+
+// CircuitBreaker tracks source health and skips sources which keep failing.
+// Sources in the same quota group share a circuit, as failures such as an exhausted quota affect all of them.
+// A single CircuitBreaker is safe for concurrent use and should be shared across Query calls.
+type CircuitBreaker struct {
+	opts     CircuitBreakerOptions
+	now      func() time.Time
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+// circuit holds the health of a single source.
+type circuit struct {
+	state       CircuitState
+	consecutive int
+	outcomes    []bool // recent run outcomes, true for failure
+	next        int    // next outcomes index to overwrite once full
+	openedAt    time.Time
+	probing     bool
+	lastErr     error
+}
+
+// NewCircuitBreaker creates a circuit breaker, zero option values are replaced with defaults.
+func NewCircuitBreaker(opts CircuitBreakerOptions) *CircuitBreaker {
+	if opts.ConsecutiveFailures <= 0 {
+		opts.ConsecutiveFailures = 3
+	}
+	if opts.Window <= 0 {
+		opts.Window = 10
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = 5 * time.Minute
+	}
+	return &CircuitBreaker{
+		opts:     opts,
+		now:      time.Now,
+		circuits: make(map[string]*circuit),
+	}
+}
+
+// get returns the circuit for a source, creating it if needed. Caller must hold the lock.
+func (b *CircuitBreaker) get(name string) *circuit {
+	c, ok := b.circuits[name]
+	if !ok {
+		c = &circuit{}
+		b.circuits[name] = c
+	}
+	return c
+}
+
+// allow reports whether the source may run. Once the cooldown elapses a single probe run is allowed.
+func (b *CircuitBreaker) allow(name string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.get(name)
+	switch c.state {
+	case CircuitOpen:
+		if b.now().Sub(c.openedAt) < b.opts.Cooldown {
+			return false
+		}
+		c.state = CircuitHalfOpen
+		c.probing = true
+		return true
+	case CircuitHalfOpen:
+		if c.probing {
+			return false
+		}
+		c.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// record records the outcome of a source run, a nil error indicates success.
+func (b *CircuitBreaker) record(name string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.get(name)
+	c.probing = false
+	failed := err != nil
+	if len(c.outcomes) < b.opts.Window {
+		c.outcomes = append(c.outcomes, failed)
+	} else {
+		c.outcomes[c.next] = failed
+		c.next = (c.next + 1) % b.opts.Window
+	}
+
+	if !failed {
+		c.consecutive = 0
+		if c.state == CircuitHalfOpen {
+			c.state = CircuitClosed
+			c.outcomes = c.outcomes[:0]
+			c.next = 0
+		}
+		return
+	}
+
+	c.consecutive++
+	c.lastErr = err
+	if c.state == CircuitHalfOpen || c.consecutive >= b.opts.ConsecutiveFailures ||
+		(b.opts.ErrorRate > 0 && len(c.outcomes) == b.opts.Window && c.failureRate() >= b.opts.ErrorRate) {
+		c.state = CircuitOpen
+		c.openedAt = b.now()
+	}
+}
+
+// release frees a probe slot for a run which ended without a meaningful outcome, such as a cancelled query
+// or one which spent the request budget.
+func (b *CircuitBreaker) release(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.get(name)
+	if c.probing {
+		c.probing = false
+		c.state = CircuitOpen // allow the next query to probe again without waiting
+	}
+}
+
+// failureRate returns the ratio of failed outcomes. Caller must hold the lock.
+func (c *circuit) failureRate() float64 {
+	if len(c.outcomes) == 0 {
+		return 0
+	}
+	var failures int
+	for _, failed := range c.outcomes {
+		if failed {
+			failures++
+		}
+	}
+	return float64(failures) / float64(len(c.outcomes))
+}
+
+// State returns the current circuit state for a source.
+// Circuits are kept per quota group, name is the group for sources which declare one.
+func (b *CircuitBreaker) State(name string) CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if c, ok := b.circuits[name]; ok {
+		return c.state
+	}
+	return CircuitClosed
+}
+
+// Statuses returns a snapshot of all sources which have run, keyed by quota group.
+func (b *CircuitBreaker) Statuses() map[string]CircuitStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	result := make(map[string]CircuitStatus, len(b.circuits))
+	for name, c := range b.circuits {
+		result[name] = CircuitStatus{
+			State:               c.state,
+			ConsecutiveFailures: c.consecutive,
+			FailureRate:         c.failureRate(),
+			OpenedAt:            c.openedAt,
+			LastError:           c.lastErr,
+		}
+	}
+	return result
+}
+
+// Reset closes the circuit for a source or quota group and clears its history.
+func (b *CircuitBreaker) Reset(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.circuits, name)
+}
@@ -0,0 +1,281 @@
+package scout
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-appsec/scout/sources"
+)
+
+// newTestCircuitBreaker creates a circuit breaker with a controllable clock.
+func newTestCircuitBreaker(opts CircuitBreakerOptions) (*CircuitBreaker, *time.Time) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := NewCircuitBreaker(opts)
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("test error")
+
+	t.Run("defaults", func(t *testing.T) {
+		b := NewCircuitBreaker(CircuitBreakerOptions{})
+
+		assert.Equal(t, 3, b.opts.ConsecutiveFailures)
+		assert.Equal(t, 10, b.opts.Window)
+		assert.Equal(t, 5*time.Minute, b.opts.Cooldown)
+	})
+
+	t.Run("trips_after_consecutive_failures", func(t *testing.T) {
+		b, _ := newTestCircuitBreaker(CircuitBreakerOptions{ConsecutiveFailures: 2})
+
+		b.record("src", errTest)
+		assert.Equal(t, CircuitClosed, b.State("src"))
+		b.record("src", errTest)
+		assert.Equal(t, CircuitOpen, b.State("src"))
+		assert.False(t, b.allow("src"))
+	})
+
+	t.Run("success_resets_consecutive", func(t *testing.T) {
+		b, _ := newTestCircuitBreaker(CircuitBreakerOptions{ConsecutiveFailures: 2})
+
+		b.record("src", errTest)
+		b.record("src", nil)
+		b.record("src", errTest)
+
+		assert.Equal(t, CircuitClosed, b.State("src"))
+	})
+
+	t.Run("trips_on_error_rate", func(t *testing.T) {
+		b, _ := newTestCircuitBreaker(CircuitBreakerOptions{ConsecutiveFailures: 10, ErrorRate: 0.5, Window: 4})
+
+		b.record("src", errTest)
+		b.record("src", nil)
+		b.record("src", nil)
+		assert.Equal(t, CircuitClosed, b.State("src")) // window not yet full
+		b.record("src", errTest)
+
+		assert.Equal(t, CircuitOpen, b.State("src"))
+	})
+
+	t.Run("half_open_probe_closes", func(t *testing.T) {
+		b, now := newTestCircuitBreaker(CircuitBreakerOptions{ConsecutiveFailures: 1, Cooldown: time.Minute})
+
+		b.record("src", errTest)
+		assert.False(t, b.allow("src"))
+
+		*now = now.Add(time.Minute)
+		assert.True(t, b.allow("src"))
+		assert.Equal(t, CircuitHalfOpen, b.State("src"))
+		assert.False(t, b.allow("src")) // only a single probe
+
+		b.record("src", nil)
+		assert.Equal(t, CircuitClosed, b.State("src"))
+		assert.True(t, b.allow("src"))
+	})
+
+	t.Run("half_open_probe_reopens", func(t *testing.T) {
+		b, now := newTestCircuitBreaker(CircuitBreakerOptions{ConsecutiveFailures: 1, Cooldown: time.Minute})
+
+		b.record("src", errTest)
+		*now = now.Add(time.Minute)
+		require.True(t, b.allow("src"))
+
+		b.record("src", errTest)
+		assert.Equal(t, CircuitOpen, b.State("src"))
+		assert.False(t, b.allow("src"))
+	})
+
+	t.Run("release_allows_new_probe", func(t *testing.T) {
+		b, now := newTestCircuitBreaker(CircuitBreakerOptions{ConsecutiveFailures: 1, Cooldown: time.Minute})
+
+		b.record("src", errTest)
+		*now = now.Add(time.Minute)
+		require.True(t, b.allow("src"))
+
+		b.release("src")
+		assert.True(t, b.allow("src"))
+	})
+
+	t.Run("statuses", func(t *testing.T) {
+		b, now := newTestCircuitBreaker(CircuitBreakerOptions{ConsecutiveFailures: 2})
+
+		b.record("good", nil)
+		b.record("bad", errTest)
+		b.record("bad", errTest)
+
+		statuses := b.Statuses()
+		require.Len(t, statuses, 2)
+		assert.Equal(t, CircuitClosed, statuses["good"].State)
+		assert.Equal(t, CircuitOpen, statuses["bad"].State)
+		assert.Equal(t, 2, statuses["bad"].ConsecutiveFailures)
+		assert.InDelta(t, 1.0, statuses["bad"].FailureRate, 0.001)
+		assert.Equal(t, *now, statuses["bad"].OpenedAt)
+		assert.ErrorIs(t, statuses["bad"].LastError, errTest)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		b, _ := newTestCircuitBreaker(CircuitBreakerOptions{ConsecutiveFailures: 1})
+
+		b.record("src", errTest)
+		b.Reset("src")
+
+		assert.Equal(t, CircuitClosed, b.State("src"))
+		assert.Empty(t, b.Statuses())
+	})
+}
+
+func TestCircuitState(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "closed", CircuitClosed.String())
+	assert.Equal(t, "open", CircuitOpen.String())
+	assert.Equal(t, "half-open", CircuitHalfOpen.String())
+}
+
+func TestQueryCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	t.Run("skips_open_source", func(t *testing.T) {
+		var runs atomic.Int32
+		failing := sources.Source{
+			Name:   "failing",
+			Yields: sources.Subdomain,
+			Run: func(_ context.Context, _ *http.Client, _ string, _ string) iter.Seq2[sources.Result, error] {
+				runs.Add(1)
+				return func(yield func(sources.Result, error) bool) {
+					yield(sources.Result{}, errors.New("failing: unexpected status 503"))
+				}
+			},
+		}
+		breaker := NewCircuitBreaker(CircuitBreakerOptions{ConsecutiveFailures: 2})
+
+		for range 2 {
+			_, err := Collect(Query(t.Context(), "example.com", WithSources([]sources.Source{failing}), WithCircuitBreaker(breaker)))
+			require.Error(t, err)
+			assert.NotErrorIs(t, err, ErrCircuitOpen)
+		}
+		_, err := Collect(Query(t.Context(), "example.com", WithSources([]sources.Source{failing}), WithCircuitBreaker(breaker)))
+
+		require.ErrorIs(t, err, ErrCircuitOpen)
+		assert.Contains(t, err.Error(), "failing: circuit open")
+		assert.Equal(t, int32(2), runs.Load())
+		assert.Equal(t, CircuitOpen, breaker.State("failing"))
+	})
+
+	t.Run("timeout_counts_as_failure", func(t *testing.T) {
+		slow := sources.Source{
+			Name:   "slow",
+			Yields: sources.Subdomain,
+			Run: func(ctx context.Context, _ *http.Client, _ string, _ string) iter.Seq2[sources.Result, error] {
+				return func(_ func(sources.Result, error) bool) {
+					<-ctx.Done() // return silently like paging sources do
+				}
+			},
+		}
+		breaker := NewCircuitBreaker(CircuitBreakerOptions{ConsecutiveFailures: 1})
+
+		_, err := Collect(Query(t.Context(), "example.com",
+			WithSources([]sources.Source{slow}),
+			WithTimeout(10*time.Millisecond),
+			WithCircuitBreaker(breaker),
+		))
+		require.NoError(t, err)
+
+		status := breaker.Statuses()["slow"]
+		assert.Equal(t, CircuitOpen, status.State)
+		assert.ErrorIs(t, status.LastError, context.DeadlineExceeded)
+	})
+
+	t.Run("shared_by_group", func(t *testing.T) {
+		var runs atomic.Int32
+		newSource := func(name string) sources.Source {
+			return sources.Source{
+				Name:   name,
+				Yields: sources.Subdomain,
+				Group:  "otx",
+				Run: func(_ context.Context, _ *http.Client, _ string, _ string) iter.Seq2[sources.Result, error] {
+					runs.Add(1)
+					return func(yield func(sources.Result, error) bool) {
+						yield(sources.Result{}, errors.New(name+": unexpected status 429"))
+					}
+				},
+			}
+		}
+		breaker := NewCircuitBreaker(CircuitBreakerOptions{ConsecutiveFailures: 1})
+
+		_, err := Collect(Query(t.Context(), "example.com", WithSources([]sources.Source{newSource("otx-urls")}), WithCircuitBreaker(breaker)))
+		require.Error(t, err)
+		_, err = Collect(Query(t.Context(), "example.com", WithSources([]sources.Source{newSource("otx-dns")}), WithCircuitBreaker(breaker)))
+
+		require.ErrorIs(t, err, ErrCircuitOpen)
+		assert.Equal(t, int32(1), runs.Load())
+		assert.Equal(t, CircuitOpen, breaker.State("otx"))
+	})
+
+	t.Run("budget_not_counted", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+		t.Cleanup(server.Close)
+		breaker := NewCircuitBreaker(CircuitBreakerOptions{ConsecutiveFailures: 1})
+
+		_, err := Collect(Query(t.Context(), "example.com",
+			WithSources([]sources.Source{requestingSource("budgeted", server.URL, 2)}),
+			WithMaxRequests(1),
+			WithCircuitBreaker(breaker),
+		))
+		require.ErrorIs(t, err, ErrRequestBudgetExceeded)
+
+		status := breaker.Statuses()["budgeted"]
+		assert.Equal(t, CircuitClosed, status.State)
+		assert.NoError(t, status.LastError)
+	})
+
+	t.Run("success_keeps_closed", func(t *testing.T) {
+		src := mockSource("ok", sources.Subdomain, []sources.Result{
+			{Type: sources.Subdomain, Value: "api.example.com", Source: "ok"},
+		}, nil)
+		breaker := NewCircuitBreaker(CircuitBreakerOptions{ConsecutiveFailures: 1})
+
+		results, err := Collect(Query(t.Context(), "example.com", WithSources([]sources.Source{src}), WithCircuitBreaker(breaker)))
+		require.NoError(t, err)
+
+		assert.Len(t, results, 1)
+		assert.Equal(t, CircuitClosed, breaker.State("ok"))
+	})
+}
+
+// requestingSource creates a test source that makes the given number of requests to url.
+func requestingSource(name, url string, requests int) sources.Source {
+	return sources.Source{
+		Name:   name,
+		Yields: sources.Subdomain,
+		Run: func(ctx context.Context, client *http.Client, _ string, _ string) iter.Seq2[sources.Result, error] {
+			return func(yield func(sources.Result, error) bool) {
+				for range requests {
+					req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+					if err != nil {
+						yield(sources.Result{}, err)
+						return
+					}
+					resp, err := client.Do(req)
+					if err != nil {
+						yield(sources.Result{}, err)
+						return
+					}
+					_ = resp.Body.Close()
+				}
+			}
+		},
+	}
+}
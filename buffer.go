@@ -0,0 +1,395 @@
+package scout
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-appsec/scout/sources"
+)
+
+// defaultBufferSize is the number of results buffered in memory per source by default.
+const defaultBufferSize = 1024
+
+// BufferOptions configures the buffer between each source and the consumer of a Query.
+// A buffered source keeps reading its responses while the consumer is busy, rather than stalling
+// the connection until a result is taken, which some servers time out mid-stream.
+type BufferOptions struct {
+	// Size is the number of results buffered in memory per source. Zero hands each result directly to the consumer.
+	Size int
+
+	// Spill writes results beyond Size to a temporary file rather than blocking the source,
+	// so its reads never wait on the consumer. The file is removed once the source's results are yielded.
+	Spill bool
+
+	// SpillDir is the directory of spill files, or the default temporary directory if empty.
+	SpillDir string
+}
+
+// sourceSink forwards the items of a source run to the query goroutine, directly or through a resultBuffer.
+// While the source is blocked on the consumer its semaphore slot is released, so another source can make requests.
+type sourceSink struct {
+	q       *query
+	run     *sourceRun
+	buf     *resultBuffer // nil when items are handed directly to the consumer
+	holding bool          // whether the run holds a semaphore slot
+}
+
+// newSink creates the sink of a source run, starting the goroutine forwarding its buffer if buffered.
+func (q *query) newSink(run *sourceRun) *sourceSink {
+	s := &sourceSink{q: q, run: run}
+	if opts := q.cfg.Buffer; opts != nil && (opts.Size > 0 || opts.Spill) {
+		s.buf = newResultBuffer(*opts)
+		go s.forward()
+	}
+	return s
+}
+
+// acquire takes a semaphore slot, reporting false if the query was cancelled first.
+func (s *sourceSink) acquire() bool {
+	select {
+	case <-s.q.ctx.Done():
+		return false
+	case s.q.sem <- struct{}{}:
+		s.holding = true
+		return true
+	}
+}
+
+// release frees the run's semaphore slot, if held.
+func (s *sourceSink) release() {
+	if s.holding {
+		<-s.q.sem
+		s.holding = false
+	}
+}
+
+// send passes an item on, reporting false if the query was cancelled.
+func (s *sourceSink) send(item resultItem) bool {
+	if s.buf != nil {
+		return s.buf.push(s.q.ctx, item, s.block)
+	}
+	select {
+	case s.q.results <- item:
+		return true
+	default:
+	}
+	return s.block(func() bool {
+		select {
+		case <-s.q.ctx.Done():
+			return false
+		case s.q.results <- item:
+			return true
+		}
+	})
+}
+
+// block runs wait, which blocks until the consumer makes room, recording the time blocked.
+// The semaphore slot is released meanwhile and taken again before returning.
+func (s *sourceSink) block(wait func() bool) bool {
+	held := s.holding
+	s.release()
+	start := time.Now()
+	ok := wait()
+	s.run.blocked.Add(int64(time.Since(start)))
+	if !ok {
+		return false
+	} else if held {
+		return s.acquire()
+	}
+	return true
+}
+
+// close ends the run, the done item follows any results still buffered.
+func (s *sourceSink) close() {
+	if s.buf != nil {
+		s.buf.close()
+		return
+	}
+	select {
+	case <-s.q.ctx.Done():
+	case s.q.results <- resultItem{run: s.run, done: true}:
+	}
+}
+
+// forward sends buffered items to the query goroutine until the buffer is closed and drained.
+func (s *sourceSink) forward() {
+	defer s.buf.discard()
+	ctx := s.q.ctx
+	for {
+		item, ok, err := s.buf.pop(ctx)
+		if err != nil {
+			item = resultItem{err: fmt.Errorf("%s: %w", s.run.source, err)}
+		} else if !ok {
+			if ctx.Err() == nil {
+				item = resultItem{done: true}
+			} else {
+				return
+			}
+		}
+		item.run = s.run
+		select {
+		case <-ctx.Done():
+			return
+		case s.q.results <- item:
+		}
+		if item.done {
+			return
+		}
+	}
+}
+
+// spillChunk is the size of reads from, and pending writes to, a spill file.
+const spillChunk = 64 * 1024
+
+// spillRecord is the encoding of a spilled item, errors are kept in memory and referenced by index.
+type spillRecord struct {
+	Result sources.Result `json:"r"`
+	Err    int            `json:"e,omitempty"` // index in resultBuffer.errs plus one, zero for a result
+}
+
+// resultBuffer is a FIFO queue of a source run's items, held in memory up to a size and then optionally
+// spilled to a file. Every item in memory is older than every spilled item, so order is kept.
+type resultBuffer struct {
+	mu        sync.Mutex
+	opts      BufferOptions
+	mem       []resultItem
+	head      int // index of the oldest item in mem
+	errs      []error
+	file      *os.File // spill file, created on first use
+	pending   []byte   // encoded records not yet written
+	readOff   int64
+	writeOff  int64
+	spilled   int   // items in the file or pending
+	spillErr  error // set when spilling failed, the buffer then blocks when full
+	reported  bool  // whether spillErr has been popped
+	closed    bool  // no more items will be pushed
+	discarded bool  // the consumer is gone, items are dropped
+	ready     chan struct{}
+	space     chan struct{}
+}
+
+// newResultBuffer creates a buffer, one item is held in memory when spilling so the buffer drains
+// if spilling fails.
+func newResultBuffer(opts BufferOptions) *resultBuffer {
+	if opts.Spill {
+		opts.Size = max(opts.Size, 1)
+	}
+	return &resultBuffer{
+		opts:  opts,
+		ready: make(chan struct{}, 1),
+		space: make(chan struct{}, 1),
+	}
+}
+
+// len returns the number of items held in memory.
+func (b *resultBuffer) len() int {
+	return len(b.mem) - b.head
+}
+
+// push adds an item, calling block with a wait for room if the buffer is full and cannot spill.
+// It reports false if the context is cancelled or the buffer discarded.
+func (b *resultBuffer) push(ctx context.Context, item resultItem, block func(wait func() bool) bool) bool {
+	b.mu.Lock()
+	for {
+		if b.discarded {
+			b.mu.Unlock()
+			return false
+		} else if b.spilled == 0 && b.len() < b.opts.Size {
+			b.mem = append(b.mem, item)
+			break
+		} else if b.opts.Spill && b.spillErr == nil {
+			stored, err := b.spill(item)
+			b.spillErr = err
+			if stored {
+				break
+			} else if b.spilled == 0 && b.len() < b.opts.Size {
+				continue
+			}
+		}
+		b.mu.Unlock()
+		if !block(func() bool { return b.waitSpace(ctx) }) {
+			return false
+		}
+		b.mu.Lock()
+	}
+	b.mu.Unlock()
+	notify(b.ready)
+	return true
+}
+
+// waitSpace waits until an item is popped, reporting false if the context is cancelled.
+func (b *resultBuffer) waitSpace(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-b.space:
+		return true
+	}
+}
+
+// pop removes the oldest item, waiting for one unless the buffer is closed and drained, reported by ok.
+// A failure to spill is returned once, after the items buffered before it.
+func (b *resultBuffer) pop(ctx context.Context) (item resultItem, ok bool, err error) {
+	b.mu.Lock()
+	for {
+		if b.len() == 0 && b.spilled > 0 {
+			if err := b.refill(); err != nil {
+				// The spilled items are lost, the source continues in memory
+				if b.spillErr == nil {
+					b.spillErr = err
+				}
+				b.dropSpill()
+				notify(b.space)
+				continue
+			}
+		}
+		if b.len() > 0 {
+			item = b.mem[b.head]
+			b.mem[b.head] = resultItem{}
+			if b.head++; b.head == len(b.mem) {
+				b.mem, b.head = b.mem[:0], 0
+			}
+			b.mu.Unlock()
+			notify(b.space)
+			return item, true, nil
+		} else if b.spillErr != nil && !b.reported {
+			b.reported = true
+			b.mu.Unlock()
+			return resultItem{}, false, fmt.Errorf("spilling results: %w", b.spillErr)
+		} else if b.closed {
+			b.mu.Unlock()
+			return resultItem{}, false, nil
+		}
+		b.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return resultItem{}, false, nil
+		case <-b.ready:
+		}
+		b.mu.Lock()
+	}
+}
+
+// close marks the end of the items pushed.
+func (b *resultBuffer) close() {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	notify(b.ready)
+}
+
+// discard drops any remaining items and removes the spill file, pushes then fail.
+func (b *resultBuffer) discard() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.discarded = true
+	b.mem, b.head, b.errs = nil, 0, nil
+	b.dropSpill()
+	notify(b.space)
+}
+
+// spill appends an item to the spill file, creating it if needed, reporting whether it was stored.
+// Records are written in chunks, an item may be stored with the error of writing the chunk it completed.
+func (b *resultBuffer) spill(item resultItem) (bool, error) {
+	if b.file == nil {
+		file, err := os.CreateTemp(b.opts.SpillDir, "scout-spill-*")
+		if err != nil {
+			return false, err
+		}
+		b.file = file
+	}
+	record := spillRecord{Result: item.result}
+	if item.err != nil {
+		b.errs = append(b.errs, item.err)
+		record.Err = len(b.errs)
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return false, err
+	}
+	b.pending = binary.LittleEndian.AppendUint32(b.pending, uint32(len(data)))
+	b.pending = append(b.pending, data...)
+	b.spilled++
+	if len(b.pending) >= spillChunk {
+		return true, b.flush()
+	}
+	return true, nil
+}
+
+// flush writes the pending records to the spill file.
+func (b *resultBuffer) flush() error {
+	if len(b.pending) == 0 {
+		return nil
+	}
+	n, err := b.file.WriteAt(b.pending, b.writeOff)
+	b.writeOff += int64(n)
+	b.pending = b.pending[n:]
+	if len(b.pending) == 0 {
+		b.pending = b.pending[:0:cap(b.pending)]
+	}
+	return err
+}
+
+// refill reads the oldest spilled records into memory, the file is reused once drained.
+func (b *resultBuffer) refill() error {
+	if err := b.flush(); err != nil {
+		return err
+	}
+	chunk := make([]byte, min(spillChunk, b.writeOff-b.readOff))
+	for len(b.mem) == 0 {
+		n, err := b.file.ReadAt(chunk, b.readOff)
+		if n < len(chunk) {
+			return err
+		}
+		for data := chunk; len(data) >= 4; {
+			size := int(binary.LittleEndian.Uint32(data))
+			if len(data) < 4+size {
+				if len(b.mem) == 0 {
+					chunk = make([]byte, 4+size) // a record larger than the chunk
+				}
+				break
+			}
+			var record spillRecord
+			if err := json.Unmarshal(data[4:4+size], &record); err != nil {
+				return err
+			}
+			item := resultItem{result: record.Result}
+			if record.Err > 0 {
+				item = resultItem{err: b.errs[record.Err-1]}
+				b.errs[record.Err-1] = nil
+			}
+			b.mem = append(b.mem, item)
+			b.readOff += int64(4 + size)
+			b.spilled--
+			data = data[4+size:]
+		}
+	}
+	if b.spilled == 0 {
+		b.readOff, b.writeOff, b.errs = 0, 0, b.errs[:0]
+		return b.file.Truncate(0)
+	}
+	return nil
+}
+
+// dropSpill closes and removes the spill file, losing any items in it.
+func (b *resultBuffer) dropSpill() {
+	if b.file != nil {
+		_ = b.file.Close()
+		_ = os.Remove(b.file.Name())
+		b.file = nil
+	}
+	b.pending, b.spilled, b.readOff, b.writeOff = nil, 0, 0, 0
+}
+
+// notify signals a channel of capacity one without blocking.
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,263 @@
+package scout
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-appsec/scout/sources"
+)
+
+// subdomainItem returns a buffered result for host<i>.example.com.
+func subdomainItem(i int) resultItem {
+	return resultItem{result: sources.Result{Type: sources.Subdomain, Value: "host" + strconv.Itoa(i) + ".example.com", Source: "src"}}
+}
+
+// neverBlock is the block function of pushes which must not block.
+func neverBlock(t *testing.T) func(func() bool) bool {
+	return func(func() bool) bool {
+		t.Error("push blocked")
+		return false
+	}
+}
+
+// drain pops items until the buffer is closed and drained.
+func drain(t *testing.T, b *resultBuffer) ([]resultItem, []error) {
+	var items []resultItem
+	var errs []error
+	for {
+		item, ok, err := b.pop(t.Context())
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		} else if !ok {
+			return items, errs
+		}
+		items = append(items, item)
+	}
+}
+
+func TestResultBuffer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("memory", func(t *testing.T) {
+		b := newResultBuffer(BufferOptions{Size: 3})
+		for i := range 3 {
+			require.True(t, b.push(t.Context(), subdomainItem(i), neverBlock(t)))
+		}
+		b.close()
+
+		items, errs := drain(t, b)
+		assert.Empty(t, errs)
+		assert.Equal(t, []resultItem{subdomainItem(0), subdomainItem(1), subdomainItem(2)}, items)
+	})
+
+	t.Run("spill_keeps_order", func(t *testing.T) {
+		dir := t.TempDir()
+		b := newResultBuffer(BufferOptions{Size: 10, Spill: true, SpillDir: dir})
+		srcErr := errors.New("src: unexpected status 503")
+		var want []resultItem
+		for i := range 5000 {
+			item := subdomainItem(i)
+			if i == 2500 {
+				item = resultItem{err: srcErr}
+			}
+			want = append(want, item)
+			require.True(t, b.push(t.Context(), item, neverBlock(t)))
+		}
+		// A record larger than a read chunk
+		large := resultItem{result: sources.Result{Type: sources.URL, Value: "https://example.com/?q=" + strings.Repeat("a", 2*spillChunk)}}
+		want = append(want, large)
+		require.True(t, b.push(t.Context(), large, neverBlock(t)))
+		b.close()
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+
+		items, errs := drain(t, b)
+		assert.Empty(t, errs)
+		require.Len(t, items, len(want))
+		assert.Equal(t, want, items)
+		assert.ErrorIs(t, items[2500].err, srcErr)
+
+		b.discard()
+		entries, err = os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("spill_reused", func(t *testing.T) {
+		b := newResultBuffer(BufferOptions{Size: 1, Spill: true, SpillDir: t.TempDir()})
+		defer b.discard()
+		for round := range 3 {
+			for i := range 3 {
+				require.True(t, b.push(t.Context(), subdomainItem(i), neverBlock(t)))
+			}
+			for i := range 3 {
+				item, ok, err := b.pop(t.Context())
+				require.NoError(t, err)
+				require.True(t, ok)
+				assert.Equal(t, subdomainItem(i), item, round)
+			}
+			assert.Zero(t, b.writeOff, "the drained file is truncated")
+		}
+	})
+
+	t.Run("blocks_when_full", func(t *testing.T) {
+		b := newResultBuffer(BufferOptions{Size: 1})
+		require.True(t, b.push(t.Context(), subdomainItem(0), neverBlock(t)))
+
+		var blocked bool
+		popped := make(chan resultItem)
+		ok := b.push(t.Context(), subdomainItem(1), func(wait func() bool) bool {
+			blocked = true
+			go func() {
+				item, _, _ := b.pop(t.Context())
+				popped <- item
+			}()
+			return wait()
+		})
+		require.True(t, ok)
+		assert.True(t, blocked)
+		assert.Equal(t, subdomainItem(0), <-popped)
+	})
+
+	t.Run("cancelled_while_blocked", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		b := newResultBuffer(BufferOptions{Size: 1})
+		require.True(t, b.push(ctx, subdomainItem(0), neverBlock(t)))
+		ok := b.push(ctx, subdomainItem(1), func(wait func() bool) bool {
+			cancel()
+			return wait()
+		})
+		assert.False(t, ok)
+	})
+
+	t.Run("spill_failure", func(t *testing.T) {
+		b := newResultBuffer(BufferOptions{Spill: true, SpillDir: filepath.Join(t.TempDir(), "missing")})
+		require.True(t, b.push(t.Context(), subdomainItem(0), neverBlock(t)))
+
+		// The spill fails, the push waits for the item in memory to be taken instead
+		ok := b.push(t.Context(), subdomainItem(1), func(wait func() bool) bool {
+			go func() {
+				item, ok, err := b.pop(t.Context())
+				assert.NoError(t, err)
+				assert.True(t, ok)
+				assert.Equal(t, subdomainItem(0), item)
+			}()
+			return wait()
+		})
+		require.True(t, ok)
+		b.close()
+
+		items, errs := drain(t, b)
+		require.Len(t, errs, 1)
+		assert.ErrorContains(t, errs[0], "spilling results:")
+		assert.Equal(t, []resultItem{subdomainItem(1)}, items)
+	})
+
+	t.Run("discarded", func(t *testing.T) {
+		b := newResultBuffer(BufferOptions{Size: 1})
+		b.discard()
+		assert.False(t, b.push(t.Context(), subdomainItem(0), neverBlock(t)))
+	})
+}
+
+// streamSource yields n results, signalling started when it runs.
+func streamSource(name string, n int, started chan<- string) sources.Source {
+	return sources.Source{
+		Name:   name,
+		Yields: sources.Subdomain,
+		Run: func(context.Context, *http.Client, string, string) iter.Seq2[sources.Result, error] {
+			return func(yield func(sources.Result, error) bool) {
+				if started != nil {
+					started <- name
+				}
+				for i := range n {
+					if !yield(sources.Result{Type: sources.Subdomain, Value: name + strconv.Itoa(i) + ".example.com", Source: name}, nil) {
+						return
+					}
+				}
+			}
+		},
+	}
+}
+
+func TestQueryBuffer(t *testing.T) {
+	t.Parallel()
+
+	// slowConsumer collects results pausing after each, returning them with the summary.
+	slowConsumer := func(t *testing.T, opts ...Option) ([]sources.Result, *Summary) {
+		t.Helper()
+
+		var summary *Summary
+		opts = append(opts, WithSummary(func(s *Summary) { summary = s }))
+		var results []sources.Result
+		for r, err := range Query(t.Context(), "example.com", opts...) {
+			require.NoError(t, err)
+			results = append(results, r)
+			time.Sleep(2 * time.Millisecond)
+		}
+		require.NotNil(t, summary)
+		return results, summary
+	}
+
+	t.Run("unbuffered_blocks", func(t *testing.T) {
+		results, summary := slowConsumer(t, WithSources([]sources.Source{streamSource("src", 10, nil)}),
+			WithBuffer(BufferOptions{}))
+		assert.Len(t, results, 10)
+		assert.Positive(t, summary.Source("src").Blocked)
+	})
+
+	t.Run("spill_never_blocks", func(t *testing.T) {
+		dir := t.TempDir()
+		results, summary := slowConsumer(t, WithSources([]sources.Source{streamSource("src", 10, nil)}),
+			WithBuffer(BufferOptions{Size: 2, Spill: true, SpillDir: dir}))
+		require.Len(t, results, 10)
+		for i, r := range results {
+			assert.Equal(t, "src"+strconv.Itoa(i)+".example.com", r.Value)
+		}
+		assert.Zero(t, summary.Source("src").Blocked)
+		assert.Equal(t, StatusRan, summary.Source("src").Status)
+
+		assert.Eventually(t, func() bool {
+			entries, err := os.ReadDir(dir)
+			return err == nil && len(entries) == 0
+		}, time.Second, 5*time.Millisecond, "spill file removed")
+	})
+
+	for name, buffer := range map[string]BufferOptions{"unbuffered": {}, "full_buffer": {Size: 1}} {
+		t.Run("frees_slot_"+name, func(t *testing.T) {
+			// The consumer waits on the first result until the second source starts, which needs the slot
+			// of the first, blocked on the consumer
+			started := make(chan string, 2)
+			srcs := []sources.Source{streamSource("first", 5, started), streamSource("second", 5, started)}
+
+			var n int
+			for _, err := range Query(t.Context(), "example.com", WithSources(srcs), WithParallelism(1), WithBuffer(buffer)) {
+				require.NoError(t, err)
+				if n++; n == 1 {
+					for range 2 {
+						select {
+						case <-started:
+						case <-time.After(5 * time.Second):
+							t.Fatal("second source did not start")
+						}
+					}
+				}
+			}
+			assert.Equal(t, 10, n)
+		})
+	}
+}
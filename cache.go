@@ -0,0 +1,185 @@
+package scout
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// CacheOptions configures a ResponseCache.
+type CacheOptions struct {
+	// TTL is how long a response is reused. Default is 1 hour.
+	TTL time.Duration
+
+	// MaxEntries is the number of responses kept, the least recently used are evicted. Default is 1000.
+	MaxEntries int
+
+	// MaxBodyBytes is the largest response body cached, larger responses are streamed uncached. Default is 8 MiB.
+	MaxBodyBytes int64
+}
+
+// ResponseCache keeps successful GET responses in memory, keyed by URL and request headers, so repeated queries
+// of a domain reuse them rather than requesting again. It is safe for concurrent use.
+// Share a ResponseCache across Query calls, such as in a long running process, so they reuse each other's responses.
+type ResponseCache struct {
+	opts CacheOptions
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // of *cachedResponse
+	order   *list.List               // most recently used first
+}
+
+// cachedResponse is a response held by a ResponseCache.
+type cachedResponse struct {
+	key     string
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// NewResponseCache creates an empty cache configured by opts.
+func NewResponseCache(opts CacheOptions) *ResponseCache {
+	if opts.TTL <= 0 {
+		opts.TTL = time.Hour
+	}
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = 1000
+	}
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = 8 << 20
+	}
+	return &ResponseCache{opts: opts, now: time.Now, entries: make(map[string]*list.Element), order: list.New()}
+}
+
+// Middleware returns middleware answering GET requests from the cache, and caching 200 responses.
+// Requests with a Range or Cache-Control: no-cache header bypass the cache.
+func (c *ResponseCache) Middleware() Middleware {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Method != http.MethodGet || req.Header.Get("Range") != "" || req.Header.Get("Cache-Control") == "no-cache" {
+				return rt.RoundTrip(req)
+			}
+			key := cacheKey(req)
+			if cached, ok := c.get(key); ok {
+				return cached.response(req), nil
+			}
+
+			resp, err := rt.RoundTrip(req)
+			if err != nil || resp.StatusCode != http.StatusOK {
+				return resp, err
+			}
+			body, err := io.ReadAll(io.LimitReader(resp.Body, c.opts.MaxBodyBytes+1))
+			if err != nil || int64(len(body)) > c.opts.MaxBodyBytes {
+				// Return what was read followed by the rest, or the read error
+				resp.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.MultiReader(bytes.NewReader(body), &errorReader{err: err, rest: resp.Body}), resp.Body}
+				return resp, nil
+			}
+			_ = resp.Body.Close()
+
+			cached := &cachedResponse{key: key, status: resp.StatusCode, header: resp.Header.Clone(), body: body}
+			c.put(cached)
+			return cached.response(req), nil
+		})
+	}
+}
+
+// cacheKey identifies a request by its method, URL, and headers other than User-Agent, which rotates.
+// Headers are included as sources may send credentials in any header, so a response is only reused
+// for a request made with the same API key. The key is hashed, keeping credentials in URLs and headers out of it.
+func cacheKey(req *http.Request) string {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%s %s\n", req.Method, req.URL)
+	for _, name := range slices.Sorted(maps.Keys(req.Header)) {
+		if name == "User-Agent" {
+			continue
+		}
+		for _, value := range req.Header[name] {
+			_, _ = fmt.Fprintf(h, "%s: %s\n", name, value)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// get returns the unexpired response for a key, marking it recently used.
+func (c *ResponseCache) get(key string) (*cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	cached := elem.Value.(*cachedResponse)
+	if !c.now().Before(cached.expires) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return cached, true
+}
+
+// put adds a response, evicting the least recently used beyond MaxEntries.
+func (c *ResponseCache) put(cached *cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached.expires = c.now().Add(c.opts.TTL)
+	if elem, ok := c.entries[cached.key]; ok {
+		elem.Value = cached
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[cached.key] = c.order.PushFront(cached)
+	for c.order.Len() > c.opts.MaxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedResponse).key)
+	}
+}
+
+// Len returns the number of cached responses, including expired ones not yet evicted.
+func (c *ResponseCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// response returns a new response for the request with the cached status, headers, and body.
+func (r *cachedResponse) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.status, http.StatusText(r.status)),
+		StatusCode:    r.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        r.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(r.body)),
+		ContentLength: int64(len(r.body)),
+		Request:       req,
+	}
+}
+
+// errorReader returns err if set, else reads from rest.
+type errorReader struct {
+	err  error
+	rest io.Reader
+}
+
+func (r *errorReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	return r.rest.Read(p)
+}
@@ -0,0 +1,177 @@
+package scout
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-appsec/scout/sources"
+)
+
+// countingServer starts a server responding to each request with its path and count, and the status of the
+// status query parameter if set.
+func countingServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		if status, err := strconv.Atoi(r.URL.Query().Get("status")); err == nil {
+			w.WriteHeader(status)
+		}
+		_, _ = io.WriteString(w, r.URL.Path+" "+strconv.Itoa(int(n)))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// fetch sends a request through the client, returning the status and body.
+func fetch(t *testing.T, client *http.Client, method, url string) (int, string) {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(t.Context(), method, url, nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
+
+func TestResponseCache(t *testing.T) {
+	t.Parallel()
+
+	cachedClient := func(cache *ResponseCache) *http.Client {
+		return &http.Client{Transport: cache.Middleware()(http.DefaultTransport)}
+	}
+
+	t.Run("reuses_get", func(t *testing.T) {
+		server, requests := countingServer(t)
+		client := cachedClient(NewResponseCache(CacheOptions{}))
+
+		status, body := fetch(t, client, http.MethodGet, server.URL+"/a")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "/a 1", body)
+		status, body = fetch(t, client, http.MethodGet, server.URL+"/a")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "/a 1", body)
+		_, body = fetch(t, client, http.MethodGet, server.URL+"/b")
+		assert.Equal(t, "/b 2", body)
+		assert.Equal(t, int32(2), requests.Load())
+	})
+
+	t.Run("keyed_by_headers", func(t *testing.T) {
+		server, requests := countingServer(t)
+		cache := NewResponseCache(CacheOptions{})
+		keyed := func(key, agent string) *http.Client {
+			return &http.Client{Transport: Chain(UserAgent(agent), Headers(http.Header{"X-Api-Key": {key}}), cache.Middleware())(http.DefaultTransport)}
+		}
+
+		_, body := fetch(t, keyed("first", "agent/1"), http.MethodGet, server.URL)
+		assert.Equal(t, "/ 1", body)
+		_, body = fetch(t, keyed("first", "agent/2"), http.MethodGet, server.URL)
+		assert.Equal(t, "/ 1", body, "user agent is not part of the key")
+		_, body = fetch(t, keyed("second", "agent/1"), http.MethodGet, server.URL)
+		assert.Equal(t, "/ 2", body, "response fetched with another key is not reused")
+		assert.Equal(t, int32(2), requests.Load())
+	})
+
+	t.Run("skips_other_methods", func(t *testing.T) {
+		server, requests := countingServer(t)
+		client := cachedClient(NewResponseCache(CacheOptions{}))
+
+		fetch(t, client, http.MethodPost, server.URL)
+		fetch(t, client, http.MethodPost, server.URL)
+		assert.Equal(t, int32(2), requests.Load())
+	})
+
+	t.Run("skips_errors", func(t *testing.T) {
+		server, requests := countingServer(t)
+		client := cachedClient(NewResponseCache(CacheOptions{}))
+
+		status, _ := fetch(t, client, http.MethodGet, server.URL+"?status=503")
+		assert.Equal(t, http.StatusServiceUnavailable, status)
+		fetch(t, client, http.MethodGet, server.URL+"?status=503")
+		assert.Equal(t, int32(2), requests.Load())
+	})
+
+	t.Run("skips_large_bodies", func(t *testing.T) {
+		server, requests := countingServer(t)
+		client := cachedClient(NewResponseCache(CacheOptions{MaxBodyBytes: 3}))
+
+		_, body := fetch(t, client, http.MethodGet, server.URL+"/large")
+		assert.Equal(t, "/large 1", body)
+		_, body = fetch(t, client, http.MethodGet, server.URL+"/large")
+		assert.Equal(t, "/large 2", body)
+		assert.Equal(t, int32(2), requests.Load())
+	})
+
+	t.Run("expires", func(t *testing.T) {
+		server, requests := countingServer(t)
+		cache := NewResponseCache(CacheOptions{TTL: time.Minute})
+		now := time.Now()
+		cache.now = func() time.Time { return now }
+		client := cachedClient(cache)
+
+		fetch(t, client, http.MethodGet, server.URL)
+		now = now.Add(59 * time.Second)
+		fetch(t, client, http.MethodGet, server.URL)
+		assert.Equal(t, int32(1), requests.Load())
+
+		now = now.Add(time.Second)
+		_, body := fetch(t, client, http.MethodGet, server.URL)
+		assert.Equal(t, "/ 2", body)
+	})
+
+	t.Run("evicts_least_recent", func(t *testing.T) {
+		server, requests := countingServer(t)
+		cache := NewResponseCache(CacheOptions{MaxEntries: 2})
+		client := cachedClient(cache)
+
+		fetch(t, client, http.MethodGet, server.URL+"/a")
+		fetch(t, client, http.MethodGet, server.URL+"/b")
+		fetch(t, client, http.MethodGet, server.URL+"/a") // hit, b is now least recent
+		fetch(t, client, http.MethodGet, server.URL+"/c")
+		assert.Equal(t, 2, cache.Len())
+		assert.Equal(t, int32(3), requests.Load())
+
+		_, body := fetch(t, client, http.MethodGet, server.URL+"/a")
+		assert.Equal(t, "/a 1", body)
+		_, body = fetch(t, client, http.MethodGet, server.URL+"/b")
+		assert.True(t, strings.HasPrefix(body, "/b "))
+		assert.Equal(t, int32(4), requests.Load())
+	})
+}
+
+func TestQueryCache(t *testing.T) {
+	t.Parallel()
+
+	server, requests := countingServer(t)
+	cache := NewResponseCache(CacheOptions{})
+
+	var summaries []*Summary
+	for range 2 {
+		_, err := Collect(Query(t.Context(), "example.com",
+			WithSources([]sources.Source{requestingSource("src", server.URL, 2)}),
+			WithCache(cache),
+			WithMaxRequests(1),
+			WithSummary(func(s *Summary) { summaries = append(summaries, s) }),
+		))
+		require.NoError(t, err)
+	}
+
+	// Cached responses skip the request budget and are not counted as requests
+	assert.Equal(t, int32(1), requests.Load())
+	require.Len(t, summaries, 2)
+	assert.Equal(t, int64(1), summaries[0].Source("src").Requests)
+	assert.Equal(t, int64(0), summaries[1].Source("src").Requests)
+}
@@ -0,0 +1,196 @@
+// Package cluster reduces large URL lists by grouping near-identical URLs, keeping a few samples of each group.
+//
+// URLs are grouped by host, path template, and the set of query parameter names, so /product?id=1 through
+// /product?id=99999 form a single cluster. The path template replaces numeric, UUID, and hash segments with
+// placeholders, such as /blog/{int}/{int}/ for /blog/2021/05/.
+package cluster
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Path template placeholders replacing variable segments.
+const (
+	Int  = "{int}"
+	UUID = "{uuid}"
+	Hash = "{hash}"
+)
+
+// DefaultExcludedExtensions are static assets excluded unless Options sets its own extensions:
+// images, fonts, stylesheets, and media.
+var DefaultExcludedExtensions = []string{
+	"png", "jpg", "jpeg", "gif", "bmp", "ico", "svg", "webp", "avif", "tif", "tiff",
+	"woff", "woff2", "ttf", "otf", "eot",
+	"css", "scss", "less",
+	"mp3", "mp4", "m4a", "wav", "ogg", "webm", "avi", "mov", "flv",
+}
+
+// Options configures a Reducer.
+type Options struct {
+	// Samples is the number of URLs kept per cluster, the first seen. Default is 1.
+	Samples int
+
+	// ExcludeExtensions lists file extensions, without the dot, of URLs excluded entirely.
+	// If nil, DefaultExcludedExtensions is used, an empty slice excludes none.
+	ExcludeExtensions []string
+}
+
+// Cluster is a group of URLs sharing host, path template, and query parameter names.
+type Cluster struct {
+	Key      string   `json:"key"`      // Host, template, and parameter names, such as "example.com/product/{int}?id&ref"
+	Host     string   `json:"host"`     // Lowercased host, with port if not the default
+	Template string   `json:"template"` // Path with variable segments replaced by placeholders
+	Params   []string `json:"params"`   // Sorted unique query parameter names
+	Size     int      `json:"size"`     // URLs added to the cluster, including those not kept
+	Samples  []string `json:"samples"`  // URLs kept, in the order added
+}
+
+// Reducer groups URLs into clusters, keeping samples of each. It is safe for concurrent use.
+type Reducer struct {
+	samples  int
+	excluded map[string]bool
+
+	mu       sync.Mutex
+	clusters map[string]*Cluster
+	skipped  int
+}
+
+// NewReducer returns a Reducer configured by opts.
+func NewReducer(opts Options) *Reducer {
+	if opts.Samples <= 0 {
+		opts.Samples = 1
+	}
+	if opts.ExcludeExtensions == nil {
+		opts.ExcludeExtensions = DefaultExcludedExtensions
+	}
+	excluded := make(map[string]bool, len(opts.ExcludeExtensions))
+	for _, ext := range opts.ExcludeExtensions {
+		excluded[strings.ToLower(strings.TrimPrefix(ext, "."))] = true
+	}
+	return &Reducer{samples: opts.Samples, excluded: excluded, clusters: make(map[string]*Cluster)}
+}
+
+// Add adds the URL to its cluster, reporting whether it was kept as a sample.
+// URLs with an excluded extension are not added, URLs which fail to parse are kept in a cluster of their own.
+func (r *Reducer) Add(rawURL string) bool {
+	c, err := Parse(rawURL)
+	if err != nil {
+		c = Cluster{Key: rawURL, Template: rawURL}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.excludes(c.Template) {
+		r.skipped++
+		return false
+	}
+	existing, ok := r.clusters[c.Key]
+	if !ok {
+		existing = &c
+		r.clusters[c.Key] = existing
+	}
+	existing.Size++
+	if len(existing.Samples) >= r.samples {
+		return false
+	}
+	existing.Samples = append(existing.Samples, rawURL)
+	return true
+}
+
+// Excludes reports whether the URL has an excluded extension, so Add would not add it.
+func (r *Reducer) Excludes(rawURL string) bool {
+	c, err := Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	return r.excludes(c.Template)
+}
+
+// excludes reports whether a path template has an excluded extension.
+func (r *Reducer) excludes(template string) bool {
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(template), "."))
+	return ext != "" && r.excluded[ext]
+}
+
+// Clusters returns the clusters, largest first, then by key.
+func (r *Reducer) Clusters() []Cluster {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clusters := make([]Cluster, 0, len(r.clusters))
+	for _, c := range r.clusters {
+		c := *c
+		c.Params = slices.Clone(c.Params)
+		c.Samples = slices.Clone(c.Samples)
+		clusters = append(clusters, c)
+	}
+	slices.SortFunc(clusters, func(a, b Cluster) int {
+		if a.Size != b.Size {
+			return b.Size - a.Size
+		}
+		return strings.Compare(a.Key, b.Key)
+	})
+	return clusters
+}
+
+// Excluded returns the number of URLs excluded by extension.
+func (r *Reducer) Excluded() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.skipped
+}
+
+// Parse returns the empty cluster the URL belongs to, without samples.
+func Parse(rawURL string) (Cluster, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return Cluster{}, err
+	}
+	c := Cluster{Host: strings.ToLower(u.Host), Template: Template(u.EscapedPath())}
+	for name := range u.Query() {
+		c.Params = append(c.Params, name)
+	}
+	slices.Sort(c.Params)
+
+	c.Key = c.Host + c.Template
+	if len(c.Params) > 0 {
+		c.Key += "?" + strings.Join(c.Params, "&")
+	}
+	return c, nil
+}
+
+var (
+	intSegment  = regexp.MustCompile(`^[0-9]+$`)
+	uuidSegment = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	hashSegment = regexp.MustCompile(`^(?:[0-9a-f]{16,}|[0-9A-F]{16,})$`)
+)
+
+// Template returns the path with numeric, UUID, and hash segments replaced by placeholders.
+// A segment's file extension is kept, so /img/1234.html becomes /img/{int}.html. An empty path becomes "/".
+func Template(p string) string {
+	if p == "" {
+		return "/"
+	}
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		stem, ext := segment, path.Ext(segment)
+		if ext != "" && ext != segment {
+			stem = strings.TrimSuffix(segment, ext)
+		} else {
+			ext = ""
+		}
+		switch {
+		case stem == "":
+		case intSegment.MatchString(stem):
+			segments[i] = Int + ext
+		case uuidSegment.MatchString(stem):
+			segments[i] = UUID + ext
+		case hashSegment.MatchString(stem) && strings.ContainsAny(stem, "0123456789"):
+			segments[i] = Hash + ext
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,132 @@
+package cluster
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{name: "empty", path: "", want: "/"},
+		{name: "static", path: "/about/team", want: "/about/team"},
+		{name: "numeric", path: "/blog/2021/05/", want: "/blog/{int}/{int}/"},
+		{name: "numeric_with_extension", path: "/img/1234.html", want: "/img/{int}.html"},
+		{name: "uuid", path: "/orders/3f2c1a9e-8b7d-4c6e-9f10-2a3b4c5d6e7f/items",
+			want: "/orders/{uuid}/items"},
+		{name: "md5", path: "/files/d41d8cd98f00b204e9800998ecf8427e", want: "/files/{hash}"},
+		{name: "sha1_uppercase", path: "/c/DA39A3EE5E6B4B0D3255BFEF95601890AFD80709.js", want: "/c/{hash}.js"},
+		{name: "hex_word_kept", path: "/deadbeefcafebabe", want: "/deadbeefcafebabe"},
+		{name: "short_hex_kept", path: "/v1/abc123", want: "/v1/abc123"},
+		{name: "mixed_kept", path: "/item-123", want: "/item-123"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Template(tt.path))
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	c, err := Parse("https://Example.com/product/42?ref=a&id=1&id=2")
+	require.NoError(t, err)
+	assert.Equal(t, Cluster{
+		Key:      "example.com/product/{int}?id&ref",
+		Host:     "example.com",
+		Template: "/product/{int}",
+		Params:   []string{"id", "ref"},
+	}, c)
+
+	t.Run("scheme_ignored", func(t *testing.T) {
+		other, err := Parse("http://example.com/product/7?id=9&ref=b")
+		require.NoError(t, err)
+		assert.Equal(t, c.Key, other.Key)
+	})
+
+	t.Run("param_set_distinguishes", func(t *testing.T) {
+		other, err := Parse("https://example.com/product/42?id=1")
+		require.NoError(t, err)
+		assert.NotEqual(t, c.Key, other.Key)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := Parse("http://[::1")
+		assert.Error(t, err)
+	})
+}
+
+func TestReducer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("keeps_samples", func(t *testing.T) {
+		r := NewReducer(Options{Samples: 2})
+		for i := range 100 {
+			kept := r.Add(fmt.Sprintf("https://example.com/product?id=%d", i))
+			assert.Equal(t, i < 2, kept)
+		}
+		assert.True(t, r.Add("https://example.com/cart"))
+
+		clusters := r.Clusters()
+		require.Len(t, clusters, 2)
+		assert.Equal(t, 100, clusters[0].Size)
+		assert.Equal(t, []string{"https://example.com/product?id=0", "https://example.com/product?id=1"},
+			clusters[0].Samples)
+		assert.Equal(t, "example.com/cart", clusters[1].Key)
+		assert.Equal(t, 1, clusters[1].Size)
+	})
+
+	t.Run("default_samples", func(t *testing.T) {
+		r := NewReducer(Options{})
+		assert.True(t, r.Add("https://example.com/u/1"))
+		assert.False(t, r.Add("https://example.com/u/2"))
+	})
+
+	t.Run("default_excluded_extensions", func(t *testing.T) {
+		r := NewReducer(Options{})
+		assert.False(t, r.Add("https://example.com/logo.PNG"))
+		assert.False(t, r.Add("https://example.com/fonts/a.woff2?v=3"))
+		assert.False(t, r.Add("https://example.com/site.css"))
+		assert.True(t, r.Add("https://example.com/app.js"))
+		assert.Equal(t, 3, r.Excluded())
+		assert.Len(t, r.Clusters(), 1)
+		assert.True(t, r.Excludes("https://example.com/img/banner.jpg?w=100"))
+		assert.False(t, r.Excludes("https://example.com/app.js"))
+		assert.Equal(t, 3, r.Excluded(), "Excludes does not count")
+	})
+
+	t.Run("custom_excluded_extensions", func(t *testing.T) {
+		r := NewReducer(Options{ExcludeExtensions: []string{".js"}})
+		assert.True(t, r.Add("https://example.com/logo.png"))
+		assert.False(t, r.Add("https://example.com/app.js"))
+	})
+
+	t.Run("no_excluded_extensions", func(t *testing.T) {
+		r := NewReducer(Options{ExcludeExtensions: []string{}})
+		assert.True(t, r.Add("https://example.com/logo.png"))
+		assert.Zero(t, r.Excluded())
+	})
+
+	t.Run("invalid_url", func(t *testing.T) {
+		r := NewReducer(Options{})
+		assert.True(t, r.Add("http://[::1"))
+		assert.False(t, r.Add("http://[::1"))
+		assert.Equal(t, 2, r.Clusters()[0].Size)
+	})
+
+	t.Run("clusters_are_copies", func(t *testing.T) {
+		r := NewReducer(Options{})
+		r.Add("https://example.com/a")
+		r.Clusters()[0].Samples[0] = "changed"
+		assert.Equal(t, "https://example.com/a", r.Clusters()[0].Samples[0])
+	})
+}
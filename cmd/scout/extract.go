@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+
+	"github.com/go-appsec/scout"
+	"github.com/go-appsec/scout/output"
+	"github.com/go-appsec/scout/sources"
+	"github.com/go-appsec/scout/wordlist"
+)
+
+// runExtract executes the extract subcommand, printing a list extracted from URLs, and returns the exit code.
+// URLs are read from the files, or stdin without any, as plain lines or JSONL records, or queried with -d.
+func runExtract(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("scout extract", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		kind     = fs.String("kind", "params", "list to print: params, segments, extensions, endpoints, files")
+		counts   = fs.Bool("counts", false, "prefix each value with the number of URLs it appeared in")
+		minCount = fs.Int("min-count", 1, "only print values appearing in at least this many URLs")
+		asJSON   = fs.Bool("json", false, "print every list with counts as a JSON object")
+		domain   = fs.String("d", "", "query URL sources for this domain rather than reading URLs, configured by the query flags")
+		verbose  = fs.Bool("v", false, "print source and input errors to stderr")
+		flags    queryFlags
+	)
+	flags.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	k, err := wordlist.ParseKind(*kind)
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, "scout extract:", err)
+		return 2
+	}
+
+	extractor := wordlist.New()
+	var readErr error
+	if *domain != "" {
+		if err := registerSpecs(flags.specs); err != nil {
+			_, _ = fmt.Fprintln(stderr, "scout extract:", err)
+			return 1
+		}
+		opts, err := flags.options(sources.URL)
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, "scout extract:", err)
+			return 2
+		}
+		readErr = extractor.AddResults(scout.Query(ctx, *domain, opts...))
+	} else if fs.NArg() == 0 {
+		readErr = extractor.AddResults(readURLs(stdin))
+	} else {
+		for _, path := range fs.Args() {
+			f, err := os.Open(path)
+			if err != nil {
+				_, _ = fmt.Fprintln(stderr, "scout extract:", err)
+				return 1
+			}
+			readErr = errors.Join(readErr, extractor.AddResults(readURLs(f)))
+			_ = f.Close()
+		}
+	}
+	if readErr != nil && *verbose {
+		_, _ = fmt.Fprintln(stderr, "scout extract:", readErr)
+	}
+
+	if *asJSON {
+		err = writeExtractJSON(stdout, extractor, *minCount)
+	} else {
+		err = writeExtractList(stdout, extractor.List(k), *minCount, *counts)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, "scout extract:", err)
+		return 1
+	} else if readErr != nil && extractor.URLs() == 0 {
+		_, _ = fmt.Fprintln(stderr, "scout extract: no URLs read:", readErr)
+		return 1
+	}
+	return 0
+}
+
+// readURLs reads URLs one per line, either plain or as JSONL records written with -format jsonl.
+// Blank lines are skipped.
+func readURLs(r io.Reader) iter.Seq2[sources.Result, error] {
+	return func(yield func(sources.Result, error) bool) {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+		var line int
+		for scanner.Scan() {
+			line++
+			data := bytes.TrimSpace(scanner.Bytes())
+			if len(data) == 0 {
+				continue
+			} else if data[0] != '{' {
+				if !yield(sources.Result{Type: sources.URL, Value: string(data)}, nil) {
+					return
+				}
+				continue
+			}
+			for r, err := range output.ReadJSONL(bytes.NewReader(data)) {
+				if err != nil {
+					err = fmt.Errorf("line %d: %w", line, err)
+				}
+				if !yield(r, err) {
+					return
+				}
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			yield(sources.Result{}, err)
+		}
+	}
+}
+
+// writeExtractList writes one value per line, optionally prefixed by its count.
+func writeExtractList(w io.Writer, entries []wordlist.Entry, minCount int, counts bool) error {
+	bw := bufio.NewWriter(w)
+	for _, e := range entries {
+		if e.Count < minCount {
+			break // entries are sorted by count
+		}
+		if counts {
+			_, _ = fmt.Fprintf(bw, "%d\t%s\n", e.Count, e.Value)
+		} else {
+			_, _ = fmt.Fprintln(bw, e.Value)
+		}
+	}
+	return bw.Flush()
+}
+
+// writeExtractJSON writes every list as a JSON object keyed by kind, with the number of URLs read.
+func writeExtractJSON(w io.Writer, e *wordlist.Extractor, minCount int) error {
+	lists := map[string]any{"urls": e.URLs()}
+	for _, k := range wordlist.Kinds() {
+		entries := e.List(k)
+		for i, entry := range entries {
+			if entry.Count < minCount {
+				entries = entries[:i]
+				break
+			}
+		}
+		lists[k.String()] = entries
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(lists)
+}
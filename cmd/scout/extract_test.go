@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-appsec/scout/sources"
+)
+
+const extractInput = `https://example.com/search?q=a&page=1
+{"type":"url","value":"https://example.com/api/v1/users/42?q=b","source":"commoncrawl"}
+{"type":"subdomain","value":"api.example.com","source":"crtsh"}
+
+https://example.com/static/app.js?v=3
+`
+
+func TestRunExtract(t *testing.T) {
+	t.Parallel()
+
+	t.Run("stdin_params", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := runExtract(t.Context(), nil, strings.NewReader(extractInput), &stdout, &stderr)
+		require.Equal(t, 0, code, stderr.String())
+		assert.Equal(t, "q\npage\nv\n", stdout.String())
+	})
+
+	t.Run("counts_and_min_count", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := runExtract(t.Context(), []string{"-counts", "-min-count", "2"}, strings.NewReader(extractInput), &stdout, &stderr)
+		require.Equal(t, 0, code, stderr.String())
+		assert.Equal(t, "2\tq\n", stdout.String())
+	})
+
+	t.Run("files", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "urls.txt")
+		require.NoError(t, os.WriteFile(path, []byte(extractInput), 0o600))
+
+		var stdout, stderr bytes.Buffer
+		code := runExtract(t.Context(), []string{"-kind", "endpoints", path}, nil, &stdout, &stderr)
+		require.Equal(t, 0, code, stderr.String())
+		assert.Equal(t, "https://example.com/api/v1/users/{int}\n", stdout.String())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := runExtract(t.Context(), []string{"-json"}, strings.NewReader(extractInput), &stdout, &stderr)
+		require.Equal(t, 0, code, stderr.String())
+
+		var decoded struct {
+			URLs  int `json:"urls"`
+			Files []struct {
+				Value string `json:"value"`
+				Count int    `json:"count"`
+			} `json:"files"`
+			Extensions []struct {
+				Value string `json:"value"`
+			} `json:"extensions"`
+		}
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &decoded))
+		assert.Equal(t, 3, decoded.URLs)
+		require.Len(t, decoded.Files, 1)
+		assert.Equal(t, "https://example.com/static/app.js", decoded.Files[0].Value)
+		require.Len(t, decoded.Extensions, 1)
+		assert.Equal(t, "js", decoded.Extensions[0].Value)
+	})
+
+	t.Run("query_flags", func(t *testing.T) {
+		var subdomainRequests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/hosts" {
+				subdomainRequests.Add(1)
+				return
+			} else if r.URL.Query().Get("key") != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = fmt.Fprint(w, `["https://example.com/search?q=1"]`)
+		}))
+		t.Cleanup(server.Close)
+		path := filepath.Join(t.TempDir(), "specs.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(`
+name: extract-keyed-test
+yields: [url]
+auth: {required: true, in: query, name: key}
+request: {url: "`+server.URL+`/urls?q={domain}"}
+response: {results: [{type: url, path: "*"}]}
+---
+name: extract-hosts-test
+yields: [subdomain]
+request: {url: "`+server.URL+`/hosts"}
+response: {results: [{type: subdomain, path: "*"}]}
+`), 0o600))
+
+		// The keyed source runs with -k
+		var stdout, stderr bytes.Buffer
+		code := runExtract(t.Context(), []string{"-spec", path, "-s", "extract-keyed-test",
+			"-k", "extract-keyed-test=secret", "-d", "example.com"}, nil, &stdout, &stderr)
+		require.Equal(t, 0, code, stderr.String())
+		assert.Equal(t, "q\n", stdout.String())
+
+		// A source not yielding URLs can't be named with -s
+		stdout.Reset()
+		stderr.Reset()
+		code = runExtract(t.Context(), []string{"-spec", path, "-s", "extract-keyed-test,extract-hosts-test",
+			"-d", "example.com"}, nil, &stdout, &stderr)
+		assert.Equal(t, 2, code)
+		assert.Contains(t, stderr.String(), `sources "extract-hosts-test" do not yield the requested result type`)
+		assert.Zero(t, subdomainRequests.Load())
+	})
+
+	t.Run("unknown_kind", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		assert.Equal(t, 2, runExtract(t.Context(), []string{"-kind", "words"}, nil, &stdout, &stderr))
+		assert.Contains(t, stderr.String(), `unknown kind "words"`)
+	})
+
+	t.Run("missing_file", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		assert.Equal(t, 1, runExtract(t.Context(), []string{filepath.Join(t.TempDir(), "none")}, nil, &stdout, &stderr))
+	})
+
+	t.Run("only_invalid_lines", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := runExtract(t.Context(), nil, strings.NewReader("{not json\n"), &stdout, &stderr)
+		assert.Equal(t, 1, code)
+		assert.Contains(t, stderr.String(), "no URLs read: line 1")
+	})
+}
+
+func TestReadURLs(t *testing.T) {
+	t.Parallel()
+
+	var values []string
+	var types []sources.ResultType
+	for r, err := range readURLs(strings.NewReader(extractInput)) {
+		require.NoError(t, err)
+		values = append(values, r.Value)
+		types = append(types, r.Type)
+	}
+	assert.Equal(t, []string{
+		"https://example.com/search?q=a&page=1",
+		"https://example.com/api/v1/users/42?q=b",
+		"api.example.com",
+		"https://example.com/static/app.js?v=3",
+	}, values)
+	assert.Equal(t, []sources.ResultType{sources.URL, sources.URL, sources.Subdomain, sources.URL}, types)
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-appsec/scout"
+	"github.com/go-appsec/scout/cluster"
+	"github.com/go-appsec/scout/normalize"
+	"github.com/go-appsec/scout/sources"
+	"github.com/go-appsec/scout/spec"
+)
+
+// queryFlags are the flags configuring queries, shared by the commands running them.
+type queryFlags struct {
+	sources     string
+	timeout     time.Duration
+	parallelism int
+	recursion   int
+	minChildren int
+	maxRequests int
+	adaptive    bool
+	globalRate  float64
+	rawURLs     bool
+	stripParams string
+	reduce      int
+	excludeExt  string
+	dedupe      string
+	dedupeFP    float64
+	buffer      int
+	spill       bool
+	spillDir    string
+	keys        keyFlags
+	rates       rateFlags
+	specs       specFlags
+	proxies     proxyFlags
+	srcProxies  sourceProxyFlags
+	userAgents  userAgentFlags
+	srcAgents   sourceUserAgentFlags
+	headers     headerFlags
+}
+
+// register defines the query flags on fs.
+func (f *queryFlags) register(fs *flag.FlagSet) {
+	f.keys, f.rates, f.srcProxies, f.srcAgents, f.headers = keyFlags{}, rateFlags{}, sourceProxyFlags{}, sourceUserAgentFlags{}, headerFlags{}
+	fs.StringVar(&f.sources, "s", "", "comma separated sources to query (default all)")
+	fs.DurationVar(&f.timeout, "timeout", 30*time.Second, "per-source timeout")
+	fs.IntVar(&f.parallelism, "p", 0, "number of sources to run concurrently (default NumCPU*2)")
+	fs.IntVar(&f.recursion, "recursive", 0, "query discovered subdomains up to this many labels deep")
+	fs.IntVar(&f.minChildren, "min-children", 2, "subdomains required beneath a recursive target")
+	fs.IntVar(&f.maxRequests, "max-requests", 0, "hard budget on total HTTP requests of each query")
+	fs.BoolVar(&f.adaptive, "adaptive", false, "adjust source rate limits from rate limit headers and throttled responses")
+	fs.Float64Var(&f.globalRate, "global-rate", 0, "requests per second across all sources, shared by every query")
+	fs.BoolVar(&f.rawURLs, "raw-urls", false, "output URLs as sources produce them rather than normalized")
+	fs.StringVar(&f.stripParams, "strip-params", "", `comma separated query parameters removed from URLs, "name*" for a prefix, "tracking" for common tracking parameters`)
+	fs.IntVar(&f.reduce, "reduce", 0, "keep this many URLs per cluster of near-identical URLs, cluster sizes are in the summary")
+	fs.StringVar(&f.excludeExt, "exclude-ext", "", `comma separated extensions excluded with -reduce, "none" to keep all (default images, fonts, styles, and media)`)
+	fs.StringVar(&f.dedupe, "dedupe", "exact", "duplicate filtering: exact, sharded, disk (bounded memory), bloom (probabilistic), none (every result with its source)")
+	fs.Float64Var(&f.dedupeFP, "dedupe-fp-rate", 0.001, "with -dedupe bloom, rate at which new results are mistaken for duplicates")
+	fs.IntVar(&f.buffer, "buffer", 1024, "results buffered per source while output is slow, 0 to hand results over directly")
+	fs.BoolVar(&f.spill, "spill", false, "write results beyond -buffer to temporary files rather than pausing sources")
+	fs.StringVar(&f.spillDir, "spill-dir", "", "with -spill, directory of the temporary files (default system temp dir)")
+	fs.Var(f.keys, "k", "API key as source=key, may be repeated, several keys for a source or quota group are rotated")
+	fs.Var(f.rates, "rate", "source rate limit as source=rps overriding its recommended rate, 0 for none, shared by every query, may be repeated")
+	fs.Var(&f.specs, "spec", "YAML or JSON file of declarative sources to register, may be repeated")
+	fs.Var(&f.proxies, "proxy", "proxy URL (http, https, socks5) requests rotate through, may be repeated")
+	fs.Var(f.srcProxies, "source-proxy", "source proxy as source=url replacing -proxy for the source, empty url for direct, may be repeated")
+	fs.Var(&f.userAgents, "user-agent", "User-Agent header replacing each source's preferred one, rotated when repeated")
+	fs.Var(f.srcAgents, "source-user-agent", "source User-Agent as source=user-agent replacing -user-agent for the source, rotated when repeated")
+	fs.Var(f.headers, "header", `extra request header as "source=Name: value", may be repeated`)
+}
+
+// options returns the query options set by the flags, querying the sources yielding the wanted types
+// or those -s names, each of which must yield them. Sources declared with -spec must be registered first, see registerSpecs.
+func (f *queryFlags) options(want sources.ResultType) ([]scout.Option, error) {
+	strategy, err := dedupeStrategy(f.dedupe, f.dedupeFP)
+	if err != nil {
+		return nil, err
+	}
+	opts := []scout.Option{scout.WithTimeout(f.timeout), scout.WithSources(sources.ByType(want)), scout.WithDedupe(strategy),
+		scout.WithBuffer(scout.BufferOptions{Size: max(f.buffer, 0), Spill: f.spill, SpillDir: f.spillDir})}
+	if f.sources != "" {
+		named, err := namedSources(f.sources, want)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, scout.WithSources(named))
+	}
+	if f.parallelism > 0 {
+		opts = append(opts, scout.WithParallelism(f.parallelism))
+	}
+	if f.recursion > 0 {
+		opts = append(opts, scout.WithRecursion(f.recursion, f.minChildren))
+	}
+	if f.maxRequests > 0 {
+		opts = append(opts, scout.WithMaxRequests(f.maxRequests))
+	}
+	if f.adaptive {
+		opts = append(opts, scout.WithAdaptiveRateLimit(scout.AdaptiveRateLimitOptions{}))
+	}
+	if f.globalRate > 0 {
+		opts = append(opts, scout.WithGlobalRateLimit(f.globalRate))
+	}
+	for name, rps := range f.rates {
+		opts = append(opts, scout.WithSourceRateLimit(name, rps))
+	}
+	opts = append(opts, proxyOptions(f.proxies, f.srcProxies)...)
+	opts = append(opts, headerOptions(f.userAgents, f.srcAgents, f.headers)...)
+	opts = append(opts, urlOptions(f.rawURLs, f.stripParams)...)
+	opts = append(opts, reductionOptions(f.reduce, f.excludeExt)...)
+	opts = append(opts, f.keys.options()...)
+	return opts, nil
+}
+
+// namedSources returns the registered sources of a comma separated list, or an error naming any unknown,
+// or any which cannot yield the wanted result types.
+func namedSources(list string, want sources.ResultType) ([]sources.Source, error) {
+	var named []sources.Source
+	var unknown, unwanted []string
+	for name := range strings.SplitSeq(list, ",") {
+		if src := sources.ByName(name); src == nil {
+			unknown = append(unknown, strconv.Quote(name))
+		} else if src.Yields&want == 0 {
+			unwanted = append(unwanted, strconv.Quote(name))
+		} else {
+			named = append(named, *src)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown sources %s, run `scout sources` to list them", strings.Join(unknown, ", "))
+	} else if len(unwanted) > 0 {
+		return nil, fmt.Errorf("sources %s do not yield the requested result type", strings.Join(unwanted, ", "))
+	}
+	return named, nil
+}
+
+// keyFlags collects repeated source=key flags, several keys for a source or group form a pool.
+type keyFlags map[string][]string
+
+func (k keyFlags) String() string {
+	return strconv.Itoa(len(k)) + " keys"
+}
+
+func (k keyFlags) Set(value string) error {
+	name, key, ok := strings.Cut(value, "=")
+	if !ok || name == "" || key == "" {
+		return errors.New("expected source=key")
+	}
+	k[name] = append(k[name], key)
+	return nil
+}
+
+// options returns the options setting the keys, a pool where a source or group has several.
+func (k keyFlags) options() []scout.Option {
+	var opts []scout.Option
+	for name, keys := range k {
+		if len(keys) == 1 {
+			opts = append(opts, scout.WithAPIKey(name, keys[0]))
+		} else {
+			opts = append(opts, scout.WithAPIKeys(name, keys...))
+		}
+	}
+	return opts
+}
+
+// specFlags collects repeated source spec file paths.
+type specFlags []string
+
+func (s *specFlags) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *specFlags) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// proxyFlags collects repeated proxy URLs.
+type proxyFlags []*url.URL
+
+func (p *proxyFlags) String() string {
+	return strconv.Itoa(len(*p)) + " proxies"
+}
+
+func (p *proxyFlags) Set(value string) error {
+	u, err := scout.ParseProxy(value)
+	if err != nil {
+		return err
+	}
+	*p = append(*p, u)
+	return nil
+}
+
+// sourceProxyFlags collects repeated source=url flags, an empty url connects the source directly.
+type sourceProxyFlags map[string][]*url.URL
+
+func (s sourceProxyFlags) String() string {
+	return strconv.Itoa(len(s)) + " source proxies"
+}
+
+func (s sourceProxyFlags) Set(value string) error {
+	name, raw, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return errors.New("expected source=url")
+	}
+	if _, ok := s[name]; !ok {
+		s[name] = []*url.URL{}
+	}
+	if raw == "" {
+		return nil
+	}
+	u, err := scout.ParseProxy(raw)
+	if err != nil {
+		return err
+	}
+	s[name] = append(s[name], u)
+	return nil
+}
+
+// proxyOptions returns the query options routing requests through the flagged proxies.
+func proxyOptions(proxies proxyFlags, sourceProxies sourceProxyFlags) []scout.Option {
+	var opts []scout.Option
+	if len(proxies) > 0 {
+		opts = append(opts, scout.WithProxy(proxies...))
+	}
+	for name, urls := range sourceProxies {
+		opts = append(opts, scout.WithSourceProxy(name, urls...))
+	}
+	return opts
+}
+
+// userAgentFlags collects repeated user agents.
+type userAgentFlags []string
+
+func (u *userAgentFlags) String() string {
+	return strings.Join(*u, ",")
+}
+
+func (u *userAgentFlags) Set(value string) error {
+	if value == "" {
+		return errors.New("expected a user agent")
+	}
+	*u = append(*u, value)
+	return nil
+}
+
+// sourceUserAgentFlags collects repeated source=user-agent flags.
+type sourceUserAgentFlags map[string][]string
+
+func (s sourceUserAgentFlags) String() string {
+	return strconv.Itoa(len(s)) + " source user agents"
+}
+
+func (s sourceUserAgentFlags) Set(value string) error {
+	name, agent, ok := strings.Cut(value, "=")
+	if !ok || name == "" || agent == "" {
+		return errors.New("expected source=user-agent")
+	}
+	s[name] = append(s[name], agent)
+	return nil
+}
+
+// headerFlags collects repeated "source=Name: value" flags.
+type headerFlags map[string]http.Header
+
+func (h headerFlags) String() string {
+	return strconv.Itoa(len(h)) + " source headers"
+}
+
+func (h headerFlags) Set(value string) error {
+	source, header, _ := strings.Cut(value, "=")
+	name, val, ok := strings.Cut(header, ":")
+	name = strings.TrimSpace(name)
+	if !ok || source == "" || name == "" {
+		return errors.New(`expected "source=Name: value"`)
+	}
+	if h[source] == nil {
+		h[source] = make(http.Header)
+	}
+	h[source].Add(name, strings.TrimSpace(val))
+	return nil
+}
+
+// headerOptions returns the query options setting the flagged user agents and headers.
+func headerOptions(userAgents userAgentFlags, sourceUserAgents sourceUserAgentFlags, headers headerFlags) []scout.Option {
+	var opts []scout.Option
+	if len(userAgents) > 0 {
+		opts = append(opts, scout.WithUserAgent(userAgents...))
+	}
+	for name, agents := range sourceUserAgents {
+		opts = append(opts, scout.WithSourceUserAgent(name, agents...))
+	}
+	for source, header := range headers {
+		for name, values := range header {
+			for _, value := range values {
+				opts = append(opts, scout.WithSourceHeader(source, name, value))
+			}
+		}
+	}
+	return opts
+}
+
+// urlOptions returns the query options for URL normalization, stripping the comma separated parameters.
+func urlOptions(raw bool, stripParams string) []scout.Option {
+	if raw {
+		return []scout.Option{scout.WithRawURLs()}
+	} else if stripParams == "" {
+		return nil
+	}
+	var opts normalize.URLOptions
+	for name := range strings.SplitSeq(stripParams, ",") {
+		switch name = strings.TrimSpace(name); name {
+		case "":
+		case "tracking":
+			opts.StripParams = append(opts.StripParams, normalize.TrackingParams...)
+		default:
+			opts.StripParams = append(opts.StripParams, name)
+		}
+	}
+	return []scout.Option{scout.WithURLNormalization(opts)}
+}
+
+// reductionOptions returns the query options keeping samples URLs per cluster, excluding the comma separated extensions.
+func reductionOptions(samples int, excludeExt string) []scout.Option {
+	if samples <= 0 {
+		return nil
+	}
+	opts := cluster.Options{Samples: samples}
+	if excludeExt == "none" {
+		opts.ExcludeExtensions = []string{}
+	} else if excludeExt != "" {
+		for ext := range strings.SplitSeq(excludeExt, ",") {
+			if ext = strings.TrimSpace(ext); ext != "" {
+				opts.ExcludeExtensions = append(opts.ExcludeExtensions, ext)
+			}
+		}
+	}
+	return []scout.Option{scout.WithURLReduction(opts)}
+}
+
+// dedupeStrategy returns the named dedupe strategy, the Bloom filter with the false-positive rate.
+func dedupeStrategy(name string, fpRate float64) (scout.DedupeStrategy, error) {
+	switch name {
+	case "exact":
+		return scout.ExactDedupe(), nil
+	case "sharded":
+		return scout.ShardedDedupe(0), nil
+	case "disk":
+		return scout.DiskDedupe(""), nil
+	case "bloom":
+		if fpRate <= 0 || fpRate >= 1 {
+			return nil, fmt.Errorf("dedupe false-positive rate must be between 0 and 1, got %g", fpRate)
+		}
+		return scout.BloomDedupe(0, fpRate), nil
+	case "none":
+		return scout.NoDedupe(), nil
+	default:
+		return nil, fmt.Errorf("unknown dedupe strategy %q", name)
+	}
+}
+
+// registerSpecs registers the sources declared in each spec file, replacing built-in sources of the same name.
+func registerSpecs(paths specFlags) error {
+	for _, path := range paths {
+		if _, err := spec.RegisterFile(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rateFlags collects repeated source=rps flags.
+type rateFlags map[string]float64
+
+func (r rateFlags) String() string {
+	return strconv.Itoa(len(r)) + " rates"
+}
+
+func (r rateFlags) Set(value string) error {
+	name, rps, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return errors.New("expected source=rps")
+	}
+	limit, err := strconv.ParseFloat(rps, 64)
+	if err != nil || limit < 0 {
+		return errors.New("expected requests per second, 0 for no limit")
+	}
+	r[name] = limit
+	return nil
+}
@@ -0,0 +1,205 @@
+// Command scout queries passive reconnaissance sources for subdomains and URLs of a domain.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-appsec/scout"
+	"github.com/go-appsec/scout/baseline"
+	"github.com/go-appsec/scout/output"
+	"github.com/go-appsec/scout/sources"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "monitor" {
+		os.Exit(runMonitor(ctx, args[1:], os.Stdout, os.Stderr))
+	} else if len(args) > 0 && args[0] == "serve" {
+		os.Exit(runServe(ctx, args[1:], os.Stderr))
+	} else if len(args) > 0 && args[0] == "sources" {
+		os.Exit(runSources(args[1:], os.Stdout, os.Stderr))
+	} else if len(args) > 0 && args[0] == "extract" {
+		os.Exit(runExtract(ctx, args[1:], os.Stdin, os.Stdout, os.Stderr))
+	}
+	os.Exit(run(ctx, args, os.Stdout, os.Stderr))
+}
+
+// run executes the CLI and returns the exit code.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("scout", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		domain       = fs.String("d", "", "domain to query")
+		resultType   = fs.String("type", "all", "result type to output: all, subdomain, url")
+		format       = fs.String("format", string(output.Text), "output format: text, jsonl, csv, report")
+		summary      = fs.String("summary", "", "print a run summary to stderr: table or json")
+		baselineFile = fs.String("baseline", "", "JSONL baseline file, only results not in the baseline are output and the file is updated")
+		removed      = fs.Bool("removed", false, "with -baseline, print results missing since the baseline to stderr")
+		verbose      = fs.Bool("v", false, "print source errors to stderr")
+		debug        = fs.Bool("debug", false, "print debug logs from sources to stderr")
+		flags        queryFlags
+	)
+	flags.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *domain == "" && fs.NArg() > 0 {
+		*domain = fs.Arg(0)
+	}
+	if *domain == "" {
+		_, _ = fmt.Fprintln(stderr, "scout: domain is required")
+		fs.Usage()
+		return 2
+	}
+
+	want := sources.Subdomain | sources.URL
+	switch *resultType {
+	case "all":
+	case "subdomain":
+		want = sources.Subdomain
+	case "url":
+		want = sources.URL
+	default:
+		_, _ = fmt.Fprintf(stderr, "scout: unknown result type %q\n", *resultType)
+		return 2
+	}
+	enc, err := output.New(output.Format(*format), stdout)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "scout: unknown output format %q\n", *format)
+		return 2
+	}
+	if *summary != "" && *summary != "table" && *summary != "json" {
+		_, _ = fmt.Fprintf(stderr, "scout: unknown summary format %q\n", *summary)
+		return 2
+	}
+	if err := registerSpecs(flags.specs); err != nil {
+		_, _ = fmt.Fprintln(stderr, "scout:", err)
+		return 1
+	}
+	opts, err := flags.options(want)
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, "scout:", err)
+		return 2
+	}
+	if *debug {
+		opts = append(opts, scout.WithLogger(slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+	}
+	var runSummary *scout.Summary
+	if *summary != "" {
+		opts = append(opts, scout.WithSummary(func(s *scout.Summary) { runSummary = s }))
+	}
+
+	var onErr func(error)
+	if *verbose {
+		onErr = output.ErrorLines(stderr)
+	}
+	var changes *baseline.Changes
+	query := scout.Query
+	if *baselineFile != "" {
+		query = baseline.New(baseline.NewFile(*baselineFile), baseline.WithTypes(want), baseline.WithChanges(func(c *baseline.Changes) {
+			changes = c
+		})).Query
+	}
+	if err := output.Write(enc, filterType(query(ctx, *domain, opts...), want), onErr); err != nil {
+		_, _ = fmt.Fprintln(stderr, "scout:", err)
+		return 1
+	}
+	if *removed && changes != nil {
+		if err := writeRemoved(stderr, changes); err != nil {
+			_, _ = fmt.Fprintln(stderr, "scout:", err)
+			return 1
+		}
+	}
+
+	if runSummary != nil {
+		if *summary == "json" {
+			err = writeSummaryJSON(stderr, runSummary)
+		} else {
+			err = writeSummaryTable(stderr, runSummary)
+		}
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, "scout:", err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// filterType drops results which are not of the wanted types, such as URLs from sources yielding both.
+func filterType(seq iter.Seq2[sources.Result, error], want sources.ResultType) iter.Seq2[sources.Result, error] {
+	return func(yield func(sources.Result, error) bool) {
+		for result, err := range seq {
+			if err == nil && result.Type&want == 0 {
+				continue
+			} else if !yield(result, err) {
+				return
+			}
+		}
+	}
+}
+
+// writeRemoved writes the results missing since the baseline, then added and removed counts per source.
+func writeRemoved(w io.Writer, c *baseline.Changes) error {
+	for _, r := range c.Removed {
+		_, _ = fmt.Fprintf(w, "removed: %s (%s)\n", r.Value, r.Source)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "SOURCE\tADDED\tREMOVED")
+	for _, name := range c.Sources() {
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\n", name, c.AddedBySource[name], c.RemovedBySource[name])
+	}
+	_, _ = fmt.Fprintf(tw, "TOTAL\t%d\t%d\n", len(c.Added), len(c.Removed))
+	return tw.Flush()
+}
+
+// writeSummaryJSON writes the summary as indented JSON.
+func writeSummaryJSON(w io.Writer, s *scout.Summary) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// writeSummaryTable writes the summary as an aligned table with one row per source.
+func writeSummaryTable(w io.Writer, s *scout.Summary) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "SOURCE\tSTATUS\tRUNS\tREQUESTS\tBYTES\tDURATION\tBLOCKED\tRATE\tRAW\tUNIQUE\tERRORS")
+	for _, src := range s.Sources {
+		rate := "-"
+		if src.RateLimit > 0 {
+			rate = strconv.FormatFloat(src.RateLimit, 'g', 3, 64) + "/s"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%s\t%s\t%s\t%d\t%d\t%d\n",
+			src.Name, src.Status, src.Runs, src.Requests, src.Bytes,
+			src.Duration.Round(time.Millisecond), src.Blocked.Round(time.Millisecond), rate, src.RawResults, src.UniqueResults, len(src.Errors))
+	}
+	_, _ = fmt.Fprintf(tw, "TOTAL\t\t\t\t\t%s\t\t\t\t%d\t%d\n", s.Duration.Round(time.Millisecond), s.Results, s.Errors)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(s.Clusters) == 0 && s.ExcludedURLs == 0 {
+		return nil
+	}
+
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "\nCLUSTER\tSIZE\tKEPT")
+	for _, c := range s.Clusters {
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\n", c.Key, c.Size, len(c.Samples))
+	}
+	_, _ = fmt.Fprintf(tw, "EXCLUDED\t%d\t\n", s.ExcludedURLs)
+	return tw.Flush()
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-appsec/scout"
+	"github.com/go-appsec/scout/baseline"
+	"github.com/go-appsec/scout/cluster"
+	"github.com/go-appsec/scout/sources"
+)
+
+func TestRunArgs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "missing_domain", args: nil, wantErr: "domain is required"},
+		{name: "unknown_type", args: []string{"-type", "ip", "example.com"}, wantErr: "unknown result type"},
+		{name: "unknown_format", args: []string{"-format", "xml", "example.com"}, wantErr: "unknown output format"},
+		{name: "unknown_summary", args: []string{"-summary", "xml", "example.com"}, wantErr: "unknown summary format"},
+		{name: "bad_key", args: []string{"-k", "shodan", "example.com"}, wantErr: "expected source=key"},
+		{name: "bad_proxy", args: []string{"-proxy", "ftp://127.0.0.1:21", "example.com"}, wantErr: `unsupported scheme "ftp"`},
+		{name: "bad_source_proxy", args: []string{"-source-proxy", "rapiddns", "example.com"}, wantErr: "expected source=url"},
+		{name: "bad_source_user_agent", args: []string{"-source-user-agent", "rapiddns=", "example.com"}, wantErr: "expected source=user-agent"},
+		{name: "bad_header", args: []string{"-header", "crtsh=Accept", "example.com"}, wantErr: `expected "source=Name: value"`},
+		{name: "bad_rate", args: []string{"-rate", "crtsh=fast", "example.com"}, wantErr: "expected requests per second"},
+		{name: "bad_dedupe", args: []string{"-dedupe", "nope", "example.com"}, wantErr: `unknown dedupe strategy "nope"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(t.Context(), tt.args, &stdout, &stderr)
+
+			assert.Equal(t, 2, code)
+			assert.Contains(t, stderr.String(), tt.wantErr)
+			assert.Empty(t, stdout.String())
+		})
+	}
+}
+
+func TestFilterType(t *testing.T) {
+	t.Parallel()
+
+	srcErr := errors.New("wayback: unexpected status 503")
+	seq := func(yield func(sources.Result, error) bool) {
+		_ = yield(sources.Result{Type: sources.Subdomain, Value: "api.example.com"}, nil) &&
+			yield(sources.Result{Type: sources.URL, Value: "https://example.com/"}, nil) &&
+			yield(sources.Result{}, srcErr)
+	}
+
+	results, err := scout.Collect(filterType(seq, sources.Subdomain))
+
+	assert.ErrorIs(t, err, srcErr)
+	require.Len(t, results, 1)
+	assert.Equal(t, "api.example.com", results[0].Value)
+}
+
+func TestWriteRemoved(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	require.NoError(t, writeRemoved(&buf, &baseline.Changes{
+		Added:           []sources.Result{{Value: "new.example.com", Source: "crtsh"}},
+		Removed:         []sources.Result{{Value: "old.example.com", Source: "anubis"}},
+		AddedBySource:   map[string]int{"crtsh": 1},
+		RemovedBySource: map[string]int{"anubis": 1},
+	}))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 5)
+	assert.Equal(t, "removed: old.example.com (anubis)", lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], "SOURCE"))
+	assert.Equal(t, []string{"anubis", "0", "1"}, strings.Fields(lines[2]))
+	assert.Equal(t, []string{"crtsh", "1", "0"}, strings.Fields(lines[3]))
+	assert.Equal(t, []string{"TOTAL", "1", "1"}, strings.Fields(lines[4]))
+}
+
+func testSummary() *scout.Summary {
+	return &scout.Summary{
+		Domain:   "example.com",
+		Duration: 2 * time.Second,
+		Results:  3,
+		Errors:   1,
+		Sources: []scout.SourceStats{
+			{Name: "crtsh", Status: scout.StatusRan, Runs: 1, Requests: 1, Bytes: 512, RawResults: 4, UniqueResults: 3, RateLimit: 0.25,
+				Blocked: 150 * time.Millisecond},
+			{Name: "shodan", Status: scout.StatusSkippedNoKey},
+			{Name: "rapiddns", Status: scout.StatusFailed, Runs: 1, Errors: []error{errors.New("rapiddns: unexpected status 503")}},
+		},
+	}
+}
+
+func TestWriteSummaryTable(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	require.NoError(t, writeSummaryTable(&buf, testSummary()))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 5)
+	assert.True(t, strings.HasPrefix(lines[0], "SOURCE"))
+	assert.Contains(t, lines[1], "crtsh")
+	assert.Contains(t, lines[1], "0.25/s")
+	assert.Contains(t, lines[1], "150ms")
+	assert.Contains(t, lines[2], "skipped-no-key")
+	assert.Contains(t, lines[3], "failed")
+	assert.True(t, strings.HasPrefix(lines[4], "TOTAL"))
+
+	t.Run("clusters", func(t *testing.T) {
+		summary := testSummary()
+		summary.Clusters = []cluster.Cluster{{Key: "example.com/product?id", Size: 120, Samples: []string{"a", "b"}}}
+		summary.ExcludedURLs = 7
+
+		var buf bytes.Buffer
+		require.NoError(t, writeSummaryTable(&buf, summary))
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Len(t, lines, 9)
+		assert.True(t, strings.HasPrefix(lines[6], "CLUSTER"))
+		assert.Equal(t, []string{"example.com/product?id", "120", "2"}, strings.Fields(lines[7]))
+		assert.Equal(t, []string{"EXCLUDED", "7"}, strings.Fields(lines[8]))
+	})
+}
+
+func TestWriteSummaryJSON(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	require.NoError(t, writeSummaryJSON(&buf, testSummary()))
+
+	var decoded struct {
+		Domain  string `json:"domain"`
+		Sources []struct {
+			Name   string   `json:"name"`
+			Status string   `json:"status"`
+			Errors []string `json:"errors"`
+		} `json:"sources"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, "example.com", decoded.Domain)
+	require.Len(t, decoded.Sources, 3)
+	assert.Equal(t, "skipped-no-key", decoded.Sources[1].Status)
+	assert.Equal(t, []string{"rapiddns: unexpected status 503"}, decoded.Sources[2].Errors)
+}
+
+func TestRunSpec(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"hosts":["api.%s","www.%s"]}`, r.URL.Path[1:], r.URL.Path[1:])
+	}))
+	t.Cleanup(server.Close)
+	path := filepath.Join(t.TempDir(), "specs.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+name: cli-spec-test
+yields: [subdomain]
+request: {url: "`+server.URL+`/{domain}"}
+response: {results: [{type: subdomain, path: hosts.*}]}
+`), 0o600))
+
+	t.Run("queries_spec_source", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := run(t.Context(), []string{"-spec", path, "-s", "cli-spec-test", "example.com"}, &stdout, &stderr)
+
+		require.Equal(t, 0, code, stderr.String())
+		assert.Equal(t, "api.example.com\nwww.example.com\n", stdout.String())
+	})
+
+	t.Run("invalid_spec", func(t *testing.T) {
+		bad := filepath.Join(t.TempDir(), "bad.yaml")
+		require.NoError(t, os.WriteFile(bad, []byte("name: bad\n"), 0o600))
+
+		var stdout, stderr bytes.Buffer
+		assert.Equal(t, 1, run(t.Context(), []string{"-spec", bad, "example.com"}, &stdout, &stderr))
+		assert.Contains(t, stderr.String(), "spec: bad: yields is required")
+	})
+}
+
+func TestRunBaselineType(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"hosts":["api.%s"],"urls":["https://%s/login"]}`, r.URL.Path[1:], r.URL.Path[1:])
+	}))
+	t.Cleanup(server.Close)
+	specPath := filepath.Join(t.TempDir(), "specs.yaml")
+	require.NoError(t, os.WriteFile(specPath, []byte(`
+name: cli-baseline-type-test
+yields: [subdomain, url]
+request: {url: "`+server.URL+`/{domain}"}
+response: {results: [{type: subdomain, path: hosts.*}, {type: url, path: urls.*}]}
+`), 0o600))
+	baselinePath := filepath.Join(t.TempDir(), "baseline.jsonl")
+	args := []string{"-spec", specPath, "-s", "cli-baseline-type-test", "-baseline", baselinePath}
+
+	var stdout, stderr bytes.Buffer
+	code := run(t.Context(), append(args, "-type", "subdomain", "example.com"), &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Equal(t, "api.example.com\n", stdout.String())
+
+	// URLs were not asked for by the first run, so they are still new
+	stdout.Reset()
+	code = run(t.Context(), append(args, "-type", "url", "example.com"), &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Equal(t, "https://example.com/login\n", stdout.String())
+}
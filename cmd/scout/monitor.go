@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/go-appsec/scout"
+	"github.com/go-appsec/scout/monitor"
+	"github.com/go-appsec/scout/notify"
+	"github.com/go-appsec/scout/output"
+	"github.com/go-appsec/scout/sources"
+)
+
+// runMonitor executes the monitor subcommand and returns the exit code.
+func runMonitor(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("scout monitor", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		targetsFile     = fs.String("targets", "", "file listing one domain per line, optionally followed by an interval")
+		stateDir        = fs.String("state", "scout-state", "directory holding the baseline of each target")
+		interval        = fs.Duration("interval", 24*time.Hour, "time between runs of targets without their own interval")
+		jitter          = fs.Duration("jitter", 5*time.Minute, "maximum random delay added to each run")
+		concurrency     = fs.Int("concurrency", 1, "number of targets run at the same time")
+		shutdownTimeout = fs.Duration("shutdown-timeout", 0, "bound on waiting for in-flight runs at shutdown (default wait for completion)")
+		once            = fs.Bool("once", false, "run every target once then exit")
+		initial         = fs.Bool("initial", false, "output results of the first run of a target, rather than only recording them")
+		format          = fs.String("format", string(output.Text), "output format for new results: text, jsonl, csv")
+		debug           = fs.Bool("debug", false, "print debug logs from sources to stderr")
+		webhook         = fs.String("webhook", "", "URL receiving new findings as signed JSON")
+		webhookSecret   = fs.String("webhook-secret", "", "HMAC secret for -webhook deliveries (default $SCOUT_WEBHOOK_SECRET)")
+		slack           = fs.String("slack", "", "Slack incoming webhook URL receiving new findings")
+		discord         = fs.String("discord", "", "Discord webhook URL receiving new findings")
+		flags           queryFlags
+	)
+	flags.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *targetsFile == "" {
+		_, _ = fmt.Fprintln(stderr, "scout monitor: -targets is required")
+		fs.Usage()
+		return 2
+	} else if output.Format(*format) == output.Report {
+		_, _ = fmt.Fprintln(stderr, "scout monitor: report format is not supported")
+		return 2
+	}
+	enc, err := output.New(output.Format(*format), stdout)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "scout monitor: unknown output format %q\n", *format)
+		return 2
+	}
+
+	file, err := os.Open(*targetsFile)
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, "scout monitor:", err)
+		return 1
+	}
+	targets, err := monitor.ParseTargets(file)
+	_ = file.Close()
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, "scout monitor:", err)
+		return 1
+	} else if err = registerSpecs(flags.specs); err != nil {
+		_, _ = fmt.Fprintln(stderr, "scout monitor:", err)
+		return 1
+	}
+
+	level := slog.LevelInfo
+	if *debug {
+		level = slog.LevelDebug
+	}
+	logger := slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: level}))
+
+	queryOpts, err := flags.options(sources.Subdomain | sources.URL)
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, "scout monitor:", err)
+		return 2
+	}
+	if *debug {
+		queryOpts = append(queryOpts, scout.WithLogger(logger))
+	}
+
+	opts := []monitor.Option{
+		monitor.WithStateDir(*stateDir),
+		monitor.WithInterval(*interval),
+		monitor.WithJitter(*jitter),
+		monitor.WithConcurrency(*concurrency),
+		monitor.WithShutdownTimeout(*shutdownTimeout),
+		monitor.WithQueryOptions(queryOpts...),
+		monitor.WithSink(monitor.EncoderSink(enc)),
+		monitor.WithLogger(logger),
+	}
+	if *initial {
+		opts = append(opts, monitor.WithInitialResults())
+	}
+	if *webhook != "" {
+		secret := *webhookSecret
+		if secret == "" {
+			secret = os.Getenv("SCOUT_WEBHOOK_SECRET")
+		}
+		opts = append(opts, monitor.WithSink(notify.NewWebhook(*webhook, secret)))
+	}
+	if *slack != "" {
+		opts = append(opts, monitor.WithSink(notify.NewSlack(*slack)))
+	}
+	if *discord != "" {
+		opts = append(opts, monitor.WithSink(notify.NewDiscord(*discord)))
+	}
+	m := monitor.New(targets, opts...)
+
+	if *once {
+		err = m.RunOnce(ctx)
+	} else {
+		err = m.Run(ctx)
+	}
+	if err = errors.Join(err, enc.Close()); err != nil {
+		_, _ = fmt.Fprintln(stderr, "scout monitor:", err)
+		return 1
+	}
+	return 0
+}
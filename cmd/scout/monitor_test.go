@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunMonitorArgs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "missing_targets", args: nil, wantErr: "-targets is required"},
+		{name: "report_format", args: []string{"-targets", "t.txt", "-format", "report"}, wantErr: "report format is not supported"},
+		{name: "unknown_format", args: []string{"-targets", "t.txt", "-format", "xml"}, wantErr: "unknown output format"},
+		{name: "bad_rate", args: []string{"-rate", "crtsh=fast"}, wantErr: "expected requests per second"},
+		{name: "bad_proxy", args: []string{"-proxy", "127.0.0.1:8080"}, wantErr: "scout: proxy:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := runMonitor(t.Context(), tt.args, &stdout, &stderr)
+
+			assert.Equal(t, 2, code)
+			assert.Contains(t, stderr.String(), tt.wantErr)
+		})
+	}
+}
+
+func TestRunMonitorOnce(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	targets := filepath.Join(dir, "targets.txt")
+	require.NoError(t, os.WriteFile(targets, []byte("example.com\n"), 0o600))
+	state := filepath.Join(dir, "state")
+
+	var stdout, stderr bytes.Buffer
+	code := runMonitor(t.Context(), []string{
+		"-targets", targets, "-state", state, "-once", "-s", "crtsh", "-timeout", "1ns",
+	}, &stdout, &stderr)
+
+	require.Equal(t, 0, code, stderr.String())
+	assert.FileExists(t, filepath.Join(state, "example.com.jsonl"))
+	assert.Contains(t, stderr.String(), "run finished")
+	assert.Empty(t, stdout.String())
+}
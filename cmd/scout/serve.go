@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/go-appsec/scout"
+	"github.com/go-appsec/scout/server"
+	"github.com/go-appsec/scout/sources"
+)
+
+// runServe executes the serve subcommand and returns the exit code.
+func runServe(ctx context.Context, args []string, stderr io.Writer) int {
+	fs := flag.NewFlagSet("scout serve", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		addr            = fs.String("addr", "localhost:8080", "address to listen on")
+		clientsFile     = fs.String("clients", "", "file listing one client per line as name, token, and optional concurrency (default no authentication)")
+		concurrency     = fs.Int("concurrency", 2, "concurrent queries per client without its own limit")
+		maxRuns         = fs.Int("max-runs", 100, "finished runs kept for fetching summaries")
+		shutdownTimeout = fs.Duration("shutdown-timeout", 30*time.Second, "bound on waiting for in-flight queries at shutdown")
+		debug           = fs.Bool("debug", false, "print debug logs from sources to stderr")
+		flags           queryFlags
+	)
+	flags.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	} else if err := registerSpecs(flags.specs); err != nil {
+		_, _ = fmt.Fprintln(stderr, "scout serve:", err)
+		return 1
+	}
+
+	level := slog.LevelInfo
+	if *debug {
+		level = slog.LevelDebug
+	}
+	logger := slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: level}))
+
+	queryOpts, err := flags.options(sources.Subdomain | sources.URL)
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, "scout serve:", err)
+		return 2
+	}
+	if *debug {
+		queryOpts = append(queryOpts, scout.WithLogger(logger))
+	}
+
+	opts := []server.Option{
+		server.WithQueryOptions(queryOpts...),
+		server.WithConcurrency(*concurrency),
+		server.WithMaxRuns(*maxRuns),
+		server.WithLogger(logger),
+	}
+	if *clientsFile != "" {
+		file, err := os.Open(*clientsFile)
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, "scout serve:", err)
+			return 1
+		}
+		clients, err := server.ParseClients(file)
+		_ = file.Close()
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, "scout serve:", err)
+			return 1
+		}
+		for _, c := range clients {
+			opts = append(opts, server.WithClient(c))
+		}
+	} else {
+		logger.Warn("no -clients file, requests are not authenticated")
+	}
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, "scout serve:", err)
+		return 1
+	}
+	srv := &http.Server{
+		Handler:           server.New(opts...),
+		ReadHeaderTimeout: 10 * time.Second,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+	}
+	logger.Info("listening", "addr", ln.Addr().String())
+
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ln) }()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), *shutdownTimeout)
+		err = srv.Shutdown(shutdownCtx)
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = srv.Close()
+		}
+		<-done
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		_, _ = fmt.Fprintln(stderr, "scout serve:", err)
+		return 1
+	}
+	return 0
+}
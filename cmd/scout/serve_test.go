@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunServeArgs(t *testing.T) {
+	t.Parallel()
+
+	t.Run("bad_rate", func(t *testing.T) {
+		var stderr bytes.Buffer
+		assert.Equal(t, 2, runServe(t.Context(), []string{"-rate", "crtsh=fast"}, &stderr))
+		assert.Contains(t, stderr.String(), "expected requests per second")
+	})
+
+	t.Run("bad_source_proxy", func(t *testing.T) {
+		var stderr bytes.Buffer
+		assert.Equal(t, 2, runServe(t.Context(), []string{"-source-proxy", "rapiddns=socks4://127.0.0.1:1080"}, &stderr))
+		assert.Contains(t, stderr.String(), `unsupported scheme "socks4"`)
+	})
+
+	t.Run("invalid_clients", func(t *testing.T) {
+		clients := filepath.Join(t.TempDir(), "clients.txt")
+		require.NoError(t, os.WriteFile(clients, []byte("ci\n"), 0o600))
+
+		var stderr bytes.Buffer
+		assert.Equal(t, 1, runServe(t.Context(), []string{"-clients", clients}, &stderr))
+		assert.Contains(t, stderr.String(), "line 1: expected name, token")
+	})
+}
+
+func TestRunServeShutdown(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	var stderr bytes.Buffer
+	code := runServe(ctx, []string{"-addr", "127.0.0.1:0"}, &stderr)
+
+	require.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stderr.String(), "requests are not authenticated")
+	assert.Contains(t, stderr.String(), "listening")
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/go-appsec/scout/sources"
+)
+
+// sourceInfo is the JSON form of a source's metadata.
+type sourceInfo struct {
+	Name         string   `json:"name"`
+	Yields       []string `json:"yields"`
+	Group        string   `json:"group"`
+	AuthRequired bool     `json:"auth_required"`
+	KeyOptional  bool     `json:"key_optional"`
+	KeyFormat    string   `json:"key_format,omitempty"`
+	RateLimit    float64  `json:"rate_limit,omitempty"`
+	KeyedRate    float64  `json:"keyed_rate_limit,omitempty"`
+	Burst        int      `json:"burst"`
+	Quota        string   `json:"quota,omitempty"`
+	Homepage     string   `json:"homepage,omitempty"`
+	Collection   string   `json:"collection"`
+	UserAgent    string   `json:"user_agent"`
+}
+
+// runSources executes the sources subcommand, listing registered sources and their metadata, and returns the exit code.
+func runSources(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("scout sources", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		asJSON = fs.Bool("json", false, "print sources as a JSON array")
+		specs  specFlags
+	)
+	fs.Var(&specs, "spec", "YAML or JSON file of declarative sources to register, may be repeated")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	} else if err := registerSpecs(specs); err != nil {
+		_, _ = fmt.Fprintln(stderr, "scout sources:", err)
+		return 1
+	}
+
+	srcs := sources.All()
+	slices.SortFunc(srcs, func(a, b sources.Source) int { return strings.Compare(a.Name, b.Name) })
+	var err error
+	if *asJSON {
+		err = writeSourcesJSON(stdout, srcs)
+	} else {
+		err = writeSourcesTable(stdout, srcs)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, "scout sources:", err)
+		return 1
+	}
+	return 0
+}
+
+// writeSourcesTable writes an aligned table with one row per source.
+func writeSourcesTable(w io.Writer, srcs []sources.Source) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NAME\tGROUP\tYIELDS\tKEY\tRATE\tQUOTA\tCOLLECTION\tHOMEPAGE")
+	for _, s := range srcs {
+		key := "-"
+		if s.AuthRequired {
+			key = "required (" + s.KeyFormat + ")"
+		} else if s.KeyOptional {
+			key = "optional (" + s.KeyFormat + ")"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", s.Name, s.QuotaGroup(), strings.Join(typeNames(s.Yields), ","),
+			key, rateText(s), orDash(s.Quota.String()), s.Collection, orDash(s.Homepage))
+	}
+	return tw.Flush()
+}
+
+// rateText formats the recommended rate such as "1/s", followed by the keyed rate and burst when they differ.
+func rateText(s sources.Source) string {
+	if s.RateLimit <= 0 {
+		return "-"
+	}
+	text := strconv.FormatFloat(s.RateLimit, 'g', -1, 64) + "/s"
+	if keyed := s.RecommendedRate(true); keyed != s.RateLimit {
+		text += ", " + strconv.FormatFloat(keyed, 'g', -1, 64) + "/s keyed"
+	}
+	if burst := s.RecommendedBurst(); burst > 1 {
+		text += ", burst " + strconv.Itoa(burst)
+	}
+	return text
+}
+
+// writeSourcesJSON writes the sources as an indented JSON array.
+func writeSourcesJSON(w io.Writer, srcs []sources.Source) error {
+	infos := make([]sourceInfo, len(srcs))
+	for i, s := range srcs {
+		infos[i] = sourceInfo{
+			Name:         s.Name,
+			Yields:       typeNames(s.Yields),
+			Group:        s.QuotaGroup(),
+			AuthRequired: s.AuthRequired,
+			KeyOptional:  s.KeyOptional,
+			KeyFormat:    s.KeyFormat,
+			RateLimit:    s.RateLimit,
+			KeyedRate:    s.KeyedRateLimit,
+			Burst:        s.RecommendedBurst(),
+			Quota:        s.Quota.String(),
+			Homepage:     s.Homepage,
+			Collection:   s.Collection.String(),
+			UserAgent:    s.UserAgent.String(),
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(infos)
+}
+
+// typeNames returns the names of the result types set in t.
+func typeNames(t sources.ResultType) []string {
+	var names []string
+	for _, rt := range []sources.ResultType{sources.Subdomain, sources.URL} {
+		if t&rt != 0 {
+			names = append(names, rt.String())
+		}
+	}
+	return names
+}
+
+// orDash returns s, or "-" if s is empty, so table cells are never blank.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
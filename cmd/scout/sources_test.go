@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunSources(t *testing.T) {
+	t.Parallel()
+
+	t.Run("table", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, 0, runSources(nil, &stdout, &stderr), stderr.String())
+
+		lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+		require.Greater(t, len(lines), 1)
+		assert.Equal(t, []string{"NAME", "GROUP", "YIELDS", "KEY", "RATE", "QUOTA", "COLLECTION", "HOMEPAGE"}, strings.Fields(lines[0]))
+		var row string
+		for _, line := range lines {
+			if strings.HasPrefix(line, "hackertarget ") {
+				row = line
+			}
+		}
+		assert.Contains(t, row, "optional (key)")
+		assert.Contains(t, row, "1/s, 2/s keyed")
+		assert.Contains(t, row, "50/day")
+		assert.Contains(t, row, "https://hackertarget.com")
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, 0, runSources([]string{"-json"}, &stdout, &stderr), stderr.String())
+
+		var infos []sourceInfo
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &infos))
+		var found bool
+		for _, info := range infos {
+			if info.Name == "rapiddns" {
+				found = true
+				assert.Equal(t, []string{"subdomain"}, info.Yields)
+				assert.Equal(t, "rapiddns", info.Group)
+				assert.Equal(t, "scraping", info.Collection)
+				assert.Equal(t, "browser", info.UserAgent)
+				assert.Positive(t, info.RateLimit)
+				assert.Equal(t, 1, info.Burst)
+			}
+		}
+		assert.True(t, found)
+	})
+
+	t.Run("spec", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "specs.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(`
+name: cli-sources-test
+yields: [url]
+rate_limit: 0.5
+homepage: https://example.net
+request: {url: "https://api.example.net/{domain}"}
+response: {results: [{type: url, path: "*"}]}
+`), 0o600))
+
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, 0, runSources([]string{"-spec", path}, &stdout, &stderr), stderr.String())
+		assert.Contains(t, stdout.String(), "cli-sources-test")
+		assert.Contains(t, stdout.String(), "0.5/s")
+	})
+
+	t.Run("bad_flag", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		assert.Equal(t, 2, runSources([]string{"-table"}, &stdout, &stderr))
+	})
+}
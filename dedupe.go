@@ -0,0 +1,378 @@
+package scout
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/go-appsec/scout/normalize"
+	"github.com/go-appsec/scout/sources"
+)
+
+// Deduplicator records the keys of results already yielded, so duplicates are filtered.
+// Implementations must be safe for concurrent use.
+type Deduplicator interface {
+	// Seen reports whether the key was already seen, and marks it as seen.
+	Seen(key string) (bool, error)
+
+	// Close releases resources held, such as files. Seen is not called after Close.
+	Close() error
+}
+
+// DedupeStrategy creates the Deduplicator of a Query, each Query has its own.
+type DedupeStrategy func() (Deduplicator, error)
+
+// ExactDedupe keeps every key in a map. It is exact, and fastest while memory allows. This is the default.
+func ExactDedupe() DedupeStrategy {
+	return func() (Deduplicator, error) {
+		return &exactDedupe{keys: make(map[string]struct{})}, nil
+	}
+}
+
+// exactDedupe is a Deduplicator keeping every key in a map.
+type exactDedupe struct {
+	mu   sync.Mutex
+	keys map[string]struct{}
+}
+
+func (d *exactDedupe) Seen(key string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.keys[key]; ok {
+		return true, nil
+	}
+	d.keys[strings.Clone(key)] = struct{}{} // keys may be slices of large response bodies
+	return false, nil
+}
+
+func (d *exactDedupe) Close() error {
+	return nil
+}
+
+// ShardedDedupe spreads keys across shards, each with its own lock and map, and interns them into
+// shared blocks rather than allocating each. It is exact, and suits Deduplicators shared by concurrent
+// callers, while the garbage collector tracks a few large blocks rather than millions of small strings.
+// Shards defaults to 64 if not positive.
+func ShardedDedupe(shards int) DedupeStrategy {
+	if shards <= 0 {
+		shards = 64
+	}
+	return func() (Deduplicator, error) {
+		d := &shardedDedupe{seed: maphash.MakeSeed(), shards: make([]dedupeShard, shards)}
+		for i := range d.shards {
+			d.shards[i].keys = make(map[string]struct{})
+		}
+		return d, nil
+	}
+}
+
+// dedupeArenaSize is the size of each block keys are interned into.
+const dedupeArenaSize = 64 << 10
+
+// shardedDedupe is a Deduplicator keeping interned keys in sharded maps.
+type shardedDedupe struct {
+	seed   maphash.Seed
+	shards []dedupeShard
+}
+
+// dedupeShard is one lock and map of a shardedDedupe, with the arena its keys are interned into.
+type dedupeShard struct {
+	mu    sync.Mutex
+	keys  map[string]struct{}
+	arena strings.Builder
+}
+
+func (d *shardedDedupe) Seen(key string) (bool, error) {
+	shard := &d.shards[maphash.String(d.seed, key)%uint64(len(d.shards))]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if _, ok := shard.keys[key]; ok {
+		return true, nil
+	}
+	shard.keys[shard.intern(key)] = struct{}{}
+	return false, nil
+}
+
+// intern copies the key into the arena, starting a new block when it is full.
+// Interned keys share the block, which is never modified once written.
+func (s *dedupeShard) intern(key string) string {
+	if s.arena.Cap()-s.arena.Len() < len(key) {
+		s.arena = strings.Builder{}
+		s.arena.Grow(max(dedupeArenaSize, len(key)))
+	}
+	start := s.arena.Len()
+	s.arena.WriteString(key)
+	return s.arena.String()[start:]
+}
+
+func (d *shardedDedupe) Close() error {
+	return nil
+}
+
+// diskDedupeSlots is the initial number of slots of a disk-backed table, 1 MiB of disk.
+const diskDedupeSlots = 1 << 16
+
+// DiskDedupe keeps 128-bit fingerprints of keys in a hash table in a temporary file in dir,
+// or the default temporary directory if empty. Memory use is constant, lookups read the file,
+// so it is slower than in-memory strategies but bounded only by disk. Fingerprint collisions
+// are negligible, below one in 10^20 for a billion keys. The file is removed on Close.
+func DiskDedupe(dir string) DedupeStrategy {
+	return func() (Deduplicator, error) {
+		return newDiskDedupe(dir, diskDedupeSlots)
+	}
+}
+
+// diskDedupe is a Deduplicator with an open addressing hash table of fingerprints in a file.
+// An all-zero slot is empty, fingerprints are never zero.
+type diskDedupe struct {
+	mu     sync.Mutex
+	dir    string
+	seeds  [2]maphash.Seed
+	file   *os.File
+	slots  uint64 // power of two
+	count  uint64
+	buf    [16]byte
+	closed bool
+}
+
+func newDiskDedupe(dir string, slots uint64) (*diskDedupe, error) {
+	d := &diskDedupe{dir: dir, seeds: [2]maphash.Seed{maphash.MakeSeed(), maphash.MakeSeed()}}
+	file, err := d.createTable(slots)
+	if err != nil {
+		return nil, err
+	}
+	d.file, d.slots = file, slots
+	return d, nil
+}
+
+// createTable creates an empty table file, sparse where the filesystem allows.
+func (d *diskDedupe) createTable(slots uint64) (*os.File, error) {
+	file, err := os.CreateTemp(d.dir, "scout-dedupe-*")
+	if err != nil {
+		return nil, fmt.Errorf("scout: dedupe: %w", err)
+	}
+	if err := file.Truncate(int64(slots * 16)); err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return nil, fmt.Errorf("scout: dedupe: %w", err)
+	}
+	return file, nil
+}
+
+// fingerprint returns the key's 128-bit fingerprint, never zero.
+func (d *diskDedupe) fingerprint(key string) (uint64, uint64) {
+	hi, lo := maphash.String(d.seeds[0], key), maphash.String(d.seeds[1], key)
+	if hi == 0 && lo == 0 {
+		lo = 1
+	}
+	return hi, lo
+}
+
+func (d *diskDedupe) Seen(key string) (bool, error) {
+	hi, lo := d.fingerprint(key)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return false, errors.New("scout: dedupe: closed")
+	}
+
+	found, err := d.insert(d.file, d.slots, hi, lo)
+	if err != nil || found {
+		return found, err
+	}
+	d.count++
+	if d.count*2 > d.slots { // keep probe sequences short
+		return false, d.grow()
+	}
+	return false, nil
+}
+
+// insert probes the table for the fingerprint, writing it to the first empty slot if absent.
+func (d *diskDedupe) insert(file *os.File, slots, hi, lo uint64) (bool, error) {
+	for i := lo & (slots - 1); ; i = (i + 1) & (slots - 1) {
+		if _, err := file.ReadAt(d.buf[:], int64(i*16)); err != nil {
+			return false, fmt.Errorf("scout: dedupe: %w", err)
+		}
+		slotHi, slotLo := binary.LittleEndian.Uint64(d.buf[:8]), binary.LittleEndian.Uint64(d.buf[8:])
+		if slotHi == hi && slotLo == lo {
+			return true, nil
+		} else if slotHi == 0 && slotLo == 0 {
+			binary.LittleEndian.PutUint64(d.buf[:8], hi)
+			binary.LittleEndian.PutUint64(d.buf[8:], lo)
+			if _, err := file.WriteAt(d.buf[:], int64(i*16)); err != nil {
+				return false, fmt.Errorf("scout: dedupe: %w", err)
+			}
+			return false, nil
+		}
+	}
+}
+
+// grow rehashes the fingerprints into a new table of twice the slots, replacing the current file.
+func (d *diskDedupe) grow() error {
+	slots := d.slots * 2
+	file, err := d.createTable(slots)
+	if err != nil {
+		return err
+	}
+	r := bufio.NewReaderSize(io.NewSectionReader(d.file, 0, int64(d.slots*16)), 1<<20)
+	var slot [16]byte
+	for {
+		if _, err = io.ReadFull(r, slot[:]); err != nil {
+			break
+		}
+		hi, lo := binary.LittleEndian.Uint64(slot[:8]), binary.LittleEndian.Uint64(slot[8:])
+		if hi == 0 && lo == 0 {
+			continue
+		}
+		if _, err = d.insert(file, slots, hi, lo); err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, io.EOF) {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return fmt.Errorf("scout: dedupe: %w", err)
+	}
+
+	_ = d.file.Close()
+	_ = os.Remove(d.file.Name())
+	d.file, d.slots = file, slots
+	return nil
+}
+
+func (d *diskDedupe) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return nil
+	}
+	d.closed = true
+	err := d.file.Close()
+	if removeErr := os.Remove(d.file.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// BloomDedupe keeps keys in a scalable Bloom filter, using a small fixed number of bits per key.
+// It is probabilistic: a new key is mistaken for a duplicate, and dropped, with probability below fpRate.
+// Capacity is the expected number of keys, more are accommodated by adding filters with tighter
+// false-positive rates, so the bound holds. Capacity defaults to 100,000 and fpRate to 0.001 if out of range.
+func BloomDedupe(capacity int, fpRate float64) DedupeStrategy {
+	if capacity <= 0 {
+		capacity = 100_000
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = 0.001
+	}
+	return func() (Deduplicator, error) {
+		d := &bloomDedupe{seeds: [2]maphash.Seed{maphash.MakeSeed(), maphash.MakeSeed()}}
+		// Each filter has half the false-positive rate of the previous, so the rates sum below fpRate
+		d.filters = []*bloomFilter{newBloomFilter(capacity, fpRate/2)}
+		return d, nil
+	}
+}
+
+// bloomDedupe is a Deduplicator keeping keys in a scalable Bloom filter.
+type bloomDedupe struct {
+	mu      sync.Mutex
+	seeds   [2]maphash.Seed
+	filters []*bloomFilter
+}
+
+func (d *bloomDedupe) Seen(key string) (bool, error) {
+	h1, h2 := maphash.String(d.seeds[0], key), maphash.String(d.seeds[1], key)|1
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, f := range d.filters {
+		if f.contains(h1, h2) {
+			return true, nil
+		}
+	}
+	last := d.filters[len(d.filters)-1]
+	if last.count >= last.capacity {
+		last = newBloomFilter(last.capacity*2, last.fpRate/2)
+		d.filters = append(d.filters, last)
+	}
+	last.add(h1, h2)
+	return false, nil
+}
+
+func (d *bloomDedupe) Close() error {
+	return nil
+}
+
+// bloomFilter is a Bloom filter sized for capacity keys at the false-positive rate,
+// with bit positions from double hashing.
+type bloomFilter struct {
+	bits     []uint64
+	m        uint64 // number of bits
+	k        int    // bits set per key
+	capacity int
+	count    int
+	fpRate   float64
+}
+
+func newBloomFilter(capacity int, fpRate float64) *bloomFilter {
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	m = max(m, 64)
+	k := max(int(math.Round(float64(m)/float64(capacity)*math.Ln2)), 1)
+	return &bloomFilter{bits: make([]uint64, (m+63)/64), m: m, k: k, capacity: capacity, fpRate: fpRate}
+}
+
+func (f *bloomFilter) contains(h1, h2 uint64) bool {
+	for i := range uint64(f.k) {
+		bit := (h1 + i*h2) % f.m
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *bloomFilter) add(h1, h2 uint64) {
+	for i := range uint64(f.k) {
+		bit := (h1 + i*h2) % f.m
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+	f.count++
+}
+
+// NoDedupe disables deduplication, every result is yielded as produced, for collecting the provenance of
+// each value across sources.
+func NoDedupe() DedupeStrategy {
+	return func() (Deduplicator, error) {
+		return noDedupe{}, nil
+	}
+}
+
+// noDedupe is a Deduplicator which has never seen a key.
+type noDedupe struct{}
+
+func (noDedupe) Seen(string) (bool, error) {
+	return false, nil
+}
+
+func (noDedupe) Close() error {
+	return nil
+}
+
+// DedupeKey returns the key a result is deduplicated by, namespaced by type so equal values of different
+// types are both kept. Hostnames are compared by normalize.Hostname, URLs as normalized before deduplication,
+// exactly if normalization is disabled, and other types exactly.
+func DedupeKey(typ sources.ResultType, value string) string {
+	switch typ {
+	case sources.Subdomain:
+		value = normalize.Hostname(value)
+	default:
+		value = strings.TrimSpace(value)
+	}
+	return typ.String() + ":" + value
+}
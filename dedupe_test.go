@@ -0,0 +1,256 @@
+package scout
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-appsec/scout/sources"
+)
+
+func TestDedupeKey(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		typ   sources.ResultType
+		value string
+		want  string
+	}{
+		{name: "subdomain_lowercased", typ: sources.Subdomain, value: "API.Example.com", want: "subdomain:api.example.com"},
+		{name: "subdomain_trailing_dot", typ: sources.Subdomain, value: "api.example.com.", want: "subdomain:api.example.com"},
+		{name: "trims_whitespace", typ: sources.Subdomain, value: "  api.example.com\n", want: "subdomain:api.example.com"},
+		{name: "url_case_kept", typ: sources.URL, value: " https://example.com/Path ", want: "url:https://example.com/Path"},
+		{name: "url_namespace", typ: sources.URL, value: "api.example.com", want: "url:api.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, DedupeKey(tt.typ, tt.value))
+		})
+	}
+}
+
+// dedupeStrategies are the built-in strategies, with the disk table in dir.
+func dedupeStrategies(dir string) map[string]DedupeStrategy {
+	return map[string]DedupeStrategy{
+		"exact":   ExactDedupe(),
+		"sharded": ShardedDedupe(0),
+		"disk":    DiskDedupe(dir),
+		"bloom":   BloomDedupe(0, 0),
+	}
+}
+
+func TestDedupeStrategies(t *testing.T) {
+	t.Parallel()
+
+	for name, strategy := range dedupeStrategies(t.TempDir()) {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			d, err := strategy()
+			require.NoError(t, err)
+			t.Cleanup(func() { assert.NoError(t, d.Close()) })
+
+			seen, err := d.Seen("api.example.com")
+			require.NoError(t, err)
+			assert.False(t, seen)
+			seen, err = d.Seen("api.example.com")
+			require.NoError(t, err)
+			assert.True(t, seen)
+
+			// Every key added is reported seen, beyond the initial disk table and Bloom filter capacity
+			const keys = 150_000
+			var falsePositives int
+			for i := range keys {
+				seen, err := d.Seen("host" + strconv.Itoa(i) + ".example.com")
+				require.NoError(t, err)
+				if seen {
+					falsePositives++
+				}
+			}
+			for i := 0; i < keys; i += 997 {
+				seen, err := d.Seen("host" + strconv.Itoa(i) + ".example.com")
+				require.NoError(t, err)
+				require.True(t, seen, i)
+			}
+			if name == "bloom" {
+				assert.Less(t, float64(falsePositives)/keys, 0.001)
+			} else {
+				assert.Zero(t, falsePositives)
+			}
+		})
+	}
+}
+
+func TestDiskDedupe(t *testing.T) {
+	t.Parallel()
+
+	t.Run("grows", func(t *testing.T) {
+		d, err := newDiskDedupe(t.TempDir(), 4)
+		require.NoError(t, err)
+		defer func() { _ = d.Close() }()
+
+		for i := range 100 {
+			seen, err := d.Seen(strconv.Itoa(i))
+			require.NoError(t, err)
+			require.False(t, seen)
+		}
+		assert.Equal(t, uint64(256), d.slots)
+		for i := range 100 {
+			seen, err := d.Seen(strconv.Itoa(i))
+			require.NoError(t, err)
+			require.True(t, seen)
+		}
+	})
+
+	t.Run("close_removes_file", func(t *testing.T) {
+		dir := t.TempDir()
+		d, err := DiskDedupe(dir)()
+		require.NoError(t, err)
+		_, err = d.Seen("a")
+		require.NoError(t, err)
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+
+		require.NoError(t, d.Close())
+		require.NoError(t, d.Close())
+		entries, err = os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+
+		_, err = d.Seen("a")
+		assert.ErrorContains(t, err, "scout: dedupe: closed")
+	})
+
+	t.Run("missing_dir", func(t *testing.T) {
+		_, err := DiskDedupe(filepath.Join(t.TempDir(), "missing"))()
+		assert.ErrorContains(t, err, "scout: dedupe:")
+	})
+}
+
+// failingDedupe is a Deduplicator whose Seen fails, recording whether it was closed.
+type failingDedupe struct {
+	closed bool
+}
+
+func (d *failingDedupe) Seen(string) (bool, error) {
+	return false, errors.New("scout: dedupe: disk full")
+}
+
+func (d *failingDedupe) Close() error {
+	d.closed = true
+	return nil
+}
+
+func TestQueryDedupe(t *testing.T) {
+	t.Parallel()
+
+	src := mockSource("src", sources.Subdomain, []sources.Result{
+		{Type: sources.Subdomain, Value: "api.example.com", Source: "src"},
+		{Type: sources.Subdomain, Value: "API.example.com", Source: "src"},
+	}, nil)
+
+	for name, strategy := range dedupeStrategies(t.TempDir()) {
+		t.Run(name, func(t *testing.T) {
+			results, err := Collect(Query(t.Context(), "example.com", WithSources([]sources.Source{src}), WithDedupe(strategy)))
+			require.NoError(t, err)
+			assert.Len(t, results, 1)
+		})
+	}
+
+	t.Run("namespaced_by_type", func(t *testing.T) {
+		mixed := mockSource("mixed", sources.Subdomain|sources.URL, []sources.Result{
+			{Type: sources.Subdomain, Value: "api.example.com", Source: "mixed"},
+			{Type: sources.URL, Value: "api.example.com", Source: "mixed"},
+			{Type: sources.Subdomain, Value: "API.example.com.", Source: "mixed"},
+		}, nil)
+		results, err := Collect(Query(t.Context(), "example.com", WithSources([]sources.Source{mixed})))
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.Equal(t, sources.Subdomain, results[0].Type)
+		assert.Equal(t, sources.URL, results[1].Type)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		other := mockSource("other", sources.Subdomain, []sources.Result{
+			{Type: sources.Subdomain, Value: "api.example.com", Source: "other"},
+		}, nil)
+
+		var summary *Summary
+		results, err := Collect(Query(t.Context(), "example.com", WithSources([]sources.Source{src, other}),
+			WithDedupe(NoDedupe()), WithSummary(func(s *Summary) { summary = s })))
+		require.NoError(t, err)
+		assert.Len(t, results, 3)
+		assert.Equal(t, int64(3), summary.Results)
+		assert.Equal(t, int64(1), summary.Source("other").UniqueResults)
+	})
+
+	t.Run("seen_error", func(t *testing.T) {
+		d := &failingDedupe{}
+		observer := &recordingObserver{}
+		var buf bytes.Buffer
+		results, err := Collect(Query(t.Context(), "example.com", WithSources([]sources.Source{src}),
+			WithDedupe(func() (Deduplicator, error) { return d, nil }), WithObserver(observer),
+			WithLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))))
+		assert.ErrorContains(t, err, "disk full")
+		assert.Len(t, results, 2, "results are yielded rather than lost")
+		assert.True(t, d.closed)
+
+		// Reported with the source like errors of the source itself
+		require.Len(t, observer.errors, 2)
+		assert.Equal(t, "src", observer.errors[0].Source)
+		assert.ErrorContains(t, observer.errors[0].Err, "disk full")
+		assert.Contains(t, buf.String(), `msg="dedupe failed" source=src`)
+	})
+
+	t.Run("strategy_error", func(t *testing.T) {
+		var summary *Summary
+		_, err := Collect(Query(t.Context(), "example.com", WithSources([]sources.Source{src}),
+			WithDedupe(func() (Deduplicator, error) { return nil, errors.New("scout: dedupe: no space") }),
+			WithSummary(func(s *Summary) { summary = s })))
+		assert.ErrorContains(t, err, "no space")
+		assert.NotNil(t, summary)
+	})
+}
+
+// BenchmarkDedupe compares the strategies adding distinct URL-like keys, reporting heap bytes retained per key.
+// Disk-backed memory is constant, its table is in the file.
+func BenchmarkDedupe(b *testing.B) {
+	for name, strategy := range dedupeStrategies(b.TempDir()) {
+		b.Run(name, func(b *testing.B) {
+			keys := make([]string, b.N)
+			for i := range keys {
+				keys[i] = "https://example.com/product/" + strconv.Itoa(i) + "?ref=campaign"
+			}
+			var before, after runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+
+			d, err := strategy()
+			require.NoError(b, err)
+			b.ResetTimer()
+			for _, key := range keys {
+				if _, err := d.Seen(key); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+
+			runtime.GC()
+			runtime.ReadMemStats(&after)
+			b.ReportMetric(float64(int64(after.HeapAlloc)-int64(before.HeapAlloc))/float64(b.N), "heap-B/key")
+			runtime.KeepAlive(keys)
+			_ = d.Close()
+		})
+	}
+}
@@ -6,10 +6,10 @@ require (
 	github.com/go-analyze/bulk v0.1.3
 	github.com/stretchr/testify v1.11.1
 	golang.org/x/time v0.14.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
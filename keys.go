@@ -0,0 +1,58 @@
+package scout
+
+import "sync/atomic"
+
+// KeyPool is a set of API keys for a source or quota group, such as keys of several accounts.
+// Each source run takes the next key round robin, so runs of every source in a group spread across the keys.
+// A pool may be shared by queries, such as through reused options, so rotation continues across them.
+type KeyPool struct {
+	keys []string
+	next atomic.Uint64
+}
+
+// NewKeyPool returns a pool rotating through the keys, empty keys are dropped.
+func NewKeyPool(keys ...string) *KeyPool {
+	p := &KeyPool{}
+	for _, key := range keys {
+		if key != "" {
+			p.keys = append(p.keys, key)
+		}
+	}
+	return p
+}
+
+// Keys returns the keys of the pool.
+func (p *KeyPool) Keys() []string {
+	return append([]string(nil), p.keys...)
+}
+
+// Next returns the next key, or empty if the pool has none.
+func (p *KeyPool) Next() string {
+	if p == nil || len(p.keys) == 0 {
+		return ""
+	}
+	return p.keys[(p.next.Add(1)-1)%uint64(len(p.keys))]
+}
+
+// apiKey returns the API key for a run of a source: its own key before its quota group's,
+// a pool before a single key, taking the next key of a pool.
+func (q *query) apiKey(name, group string) string {
+	for _, n := range []string{name, group} {
+		if key := q.cfg.APIKeyPools[n].Next(); key != "" {
+			return key
+		} else if key := q.cfg.APIKeys[n]; key != "" {
+			return key
+		}
+	}
+	return ""
+}
+
+// hasAPIKey reports whether runs of a source have an API key, without taking a key from a pool.
+func (q *query) hasAPIKey(name, group string) bool {
+	for _, n := range []string{name, group} {
+		if pool := q.cfg.APIKeyPools[n]; (pool != nil && len(pool.keys) > 0) || q.cfg.APIKeys[n] != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package scout
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-appsec/scout/sources"
+)
+
+func TestKeyPool(t *testing.T) {
+	t.Parallel()
+
+	pool := NewKeyPool("a", "", "b")
+	assert.Equal(t, []string{"a", "b"}, pool.Keys())
+	assert.Equal(t, []string{"a", "b", "a"}, []string{pool.Next(), pool.Next(), pool.Next()})
+
+	assert.Empty(t, NewKeyPool().Next())
+	var nilPool *KeyPool
+	assert.Empty(t, nilPool.Next())
+}
+
+func TestQueryKeyPool(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	keys := make(map[string][]string)
+	newSource := func(name string) sources.Source {
+		return sources.Source{
+			Name:         name,
+			Yields:       sources.Subdomain,
+			Group:        "otx",
+			AuthRequired: true,
+			Run: func(_ context.Context, _ *http.Client, _ string, apiKey string) iter.Seq2[sources.Result, error] {
+				mu.Lock()
+				keys[name] = append(keys[name], apiKey)
+				mu.Unlock()
+				return func(func(sources.Result, error) bool) {}
+			},
+		}
+	}
+	srcs := []sources.Source{newSource("otx-urls"), newSource("otx-dns"), newSource("otx-own")}
+
+	// The option is reused so rotation continues across queries, as by a long running process
+	opts := []Option{
+		WithSources(srcs),
+		WithParallelism(1),
+		WithAPIKeys("otx", "k1", "k2"),
+		WithAPIKey("otx", "single-key"), // the group's pool is used first
+		WithAPIKey("otx-own", "own-key"),
+	}
+	for range 2 {
+		_, err := Collect(Query(t.Context(), "example.com", opts...))
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, []string{"own-key", "own-key"}, keys["otx-own"])
+	used := append(keys["otx-urls"], keys["otx-dns"]...)
+	assert.ElementsMatch(t, []string{"k1", "k2", "k1", "k2"}, used)
+}
@@ -0,0 +1,276 @@
+package scout
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-appsec/scout/sources"
+)
+
+// Middleware wraps an http.RoundTripper, such as to cache responses or add headers to requests.
+// Middleware must be safe for concurrent use, sources may send requests from multiple goroutines.
+//
+// Each source's requests pass through a chain, outermost first:
+//
+//  1. User agent and headers set with WithSourceHeader
+//  2. Response cache, if set with WithCache
+//  3. Retry, if set with WithRetry
+//  4. Source and global rate limits, and the request budget
+//  5. Middleware set with WithMiddleware, then WithSourceMiddleware
+//  6. Logging, if a logger is set
+//  7. Metrics for the summary and observer
+//
+// The cache runs outside retries and rate limiting so a cached response is returned without waiting on the
+// limiters or counting against the budget. Retries run outside rate limiting so every attempt waits on the
+// limiters and counts against the budget, while metrics are closest to the transport so they record each
+// request actually sent.
+type Middleware func(http.RoundTripper) http.RoundTripper
+
+// Chain composes middleware into one, the first middleware is outermost and sees each request first.
+// Nil middleware are skipped.
+func Chain(middleware ...Middleware) Middleware {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		for i := len(middleware) - 1; i >= 0; i-- {
+			if middleware[i] != nil {
+				rt = middleware[i](rt)
+			}
+		}
+		return rt
+	}
+}
+
+// RoundTripperFunc adapts a function to an http.RoundTripper, for writing middleware.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// defaultUserAgent is set on requests of sources preferring the default profile.
+const defaultUserAgent = "Mozilla/5.0 (compatible; go-appsec/scout-v" + Version + ")"
+
+// browserUserAgents are rotated on requests of sources preferring the browser profile.
+var browserUserAgents = []string{
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/141.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/141.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:144.0) Gecko/20100101 Firefox/144.0",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/26.0 Safari/605.1.15",
+	"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/141.0.0.0 Safari/537.36",
+}
+
+// profileUserAgents returns the user agents for a source's preferred profile.
+func profileUserAgents(profile sources.UserAgentProfile) []string {
+	if profile == sources.UserAgentBrowser {
+		return browserUserAgents
+	}
+	return []string{defaultUserAgent}
+}
+
+// userAgentPool rotates user agents round robin, safe for concurrent use.
+type userAgentPool struct {
+	agents []string
+	next   atomic.Uint64
+}
+
+func (p *userAgentPool) pick() string {
+	return p.agents[(p.next.Add(1)-1)%uint64(len(p.agents))]
+}
+
+// UserAgent returns middleware setting the User-Agent header of every request,
+// rotating round robin per request when given more than one. With none, requests are unchanged.
+func UserAgent(userAgents ...string) Middleware {
+	return userAgentMiddleware(&userAgentPool{agents: userAgents})
+}
+
+// userAgentMiddleware returns middleware setting User-Agent headers from the pool, which may be shared.
+func userAgentMiddleware(pool *userAgentPool) Middleware {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		if len(pool.agents) == 0 {
+			return rt
+		}
+		return &userAgentTransport{base: rt, pool: pool}
+	}
+}
+
+// userAgentTransport wraps an http.RoundTripper to set User-Agent header.
+type userAgentTransport struct {
+	base http.RoundTripper
+	pool *userAgentPool
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.pool.pick())
+	return t.base.RoundTrip(req)
+}
+
+// Headers returns middleware setting the headers on every request, replacing values of the same name.
+func Headers(header http.Header) Middleware {
+	header = header.Clone()
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			for name, values := range header {
+				req.Header[name] = slices.Clone(values)
+			}
+			return rt.RoundTrip(req)
+		})
+	}
+}
+
+// errNoRedirects is returned for redirected requests, sources query APIs which should not redirect.
+var errNoRedirects = errors.New("redirects not allowed")
+
+// noRedirects is the redirect policy of clients without their own.
+func noRedirects(*http.Request, []*http.Request) error {
+	return errNoRedirects
+}
+
+// RetryOptions configures retrying failed requests.
+type RetryOptions struct {
+	// MaxRetries is the number of retries after the first attempt. Default is 2.
+	MaxRetries int
+
+	// MinBackoff is the delay before the first retry, doubling for each retry after. Default is 1 second.
+	MinBackoff time.Duration
+
+	// MaxBackoff bounds the delay between attempts, including a delay requested by a Retry-After header.
+	// Default is 30 seconds.
+	MaxBackoff time.Duration
+
+	// RetryIf reports whether an attempt is retried. Default retries transport errors,
+	// 429 Too Many Requests, and 500, 502, 503, and 504 server errors.
+	RetryIf func(*http.Response, error) bool
+}
+
+// withDefaults returns the options with zero values replaced by defaults.
+func (o RetryOptions) withDefaults() RetryOptions {
+	if o.MaxRetries <= 0 {
+		o.MaxRetries = 2
+	}
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = time.Second
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = 30 * time.Second
+	}
+	if o.MaxBackoff < o.MinBackoff {
+		o.MaxBackoff = o.MinBackoff
+	}
+	if o.RetryIf == nil {
+		o.RetryIf = retryable
+	}
+	return o
+}
+
+// retryable reports whether an attempt failed transiently.
+// Cancellation and the request budget are final, retrying them cannot succeed.
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) &&
+			!errors.Is(err, ErrRequestBudgetExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Retry returns middleware retrying failed requests with exponential backoff and jitter.
+// A Retry-After header on the failed response sets the delay instead, bounded by MaxBackoff.
+// Requests with a body are only retried if it can be replayed through GetBody.
+func Retry(opts RetryOptions) Middleware {
+	return retryMiddleware(opts, nil)
+}
+
+// retryMiddleware returns Retry middleware reporting each retry to observer if non-nil.
+func retryMiddleware(opts RetryOptions, observer Observer) Middleware {
+	opts = opts.withDefaults()
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return &retryTransport{base: rt, opts: opts, observer: observer}
+	}
+}
+
+// retryTransport wraps an http.RoundTripper to retry failed requests.
+type retryTransport struct {
+	base     http.RoundTripper
+	opts     RetryOptions
+	observer Observer
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if attempt > t.opts.MaxRetries || !t.opts.RetryIf(resp, err) || (req.Body != nil && req.GetBody == nil) {
+			return resp, err
+		}
+
+		delay := min(t.opts.MinBackoff<<(attempt-1), t.opts.MaxBackoff)
+		delay = delay/2 + rand.N(delay/2+1) // jitter spreads retries from concurrent sources
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+			now := time.Now()
+			if until, ok := ParseRetryAfter(resp.Header.Get("Retry-After"), now); ok {
+				delay = min(max(until.Sub(now), 0), t.opts.MaxBackoff)
+			}
+			// Drain the body so the connection can be reused
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			_ = resp.Body.Close()
+		}
+
+		url := sources.RedactURL(req.URL.String())
+		sources.Logger(ctx).DebugContext(ctx, "retrying request",
+			"url", url, "attempt", attempt, "status", status, "error", err, "delay", delay)
+		if t.observer != nil {
+			source, _ := ctx.Value(sourceContextKey{}).(string)
+			t.observer.Retry(RetryEvent{Source: source, Method: req.Method, URL: url, Attempt: attempt,
+				StatusCode: status, Err: err, Delay: delay, Time: time.Now()})
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(ctx)
+			req.Body = body
+		}
+	}
+}
+
+// loggingMiddleware returns middleware logging each request to the logger of its context at debug level.
+func loggingMiddleware() Middleware {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			ctx := req.Context()
+			start := time.Now()
+			resp, err := rt.RoundTrip(req)
+			url := sources.RedactURL(req.URL.String())
+			if err != nil {
+				sources.Logger(ctx).DebugContext(ctx, "http request failed",
+					"method", req.Method, "url", url, "duration", time.Since(start), "error", err)
+				return nil, err
+			}
+			sources.Logger(ctx).DebugContext(ctx, "http request",
+				"method", req.Method, "url", url, "status", resp.StatusCode, "duration", time.Since(start))
+			return resp, nil
+		})
+	}
+}
@@ -0,0 +1,453 @@
+package scout
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-appsec/scout/sources"
+)
+
+// recordingMiddleware returns middleware appending its name and the request's User-Agent to calls.
+func recordingMiddleware(name string, mu *sync.Mutex, calls *[]string) Middleware {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			mu.Lock()
+			*calls = append(*calls, name+" "+req.Header.Get("User-Agent"))
+			mu.Unlock()
+			return rt.RoundTrip(req)
+		})
+	}
+}
+
+// statusSequence starts a server responding with each status in turn, then 200, and counts requests.
+func statusSequence(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1))
+		_, _ = io.Copy(io.Discard, r.Body)
+		if n <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestChain(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var calls []string
+	base := RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+		calls = append(calls, "base")
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+	rt := Chain(
+		recordingMiddleware("first", &mu, &calls),
+		nil,
+		UserAgent("agent/1"),
+		recordingMiddleware("second", &mu, &calls),
+	)(base)
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	require.NoError(t, err)
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, []string{"first ", "second agent/1", "base"}, calls)
+	assert.Empty(t, req.Header.Get("User-Agent"), "caller's request is not modified")
+}
+
+func TestUserAgent(t *testing.T) {
+	t.Parallel()
+
+	var agents []string
+	base := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		agents = append(agents, req.Header.Get("User-Agent"))
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+	req, err := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	require.NoError(t, err)
+	req.Header.Set("User-Agent", "caller/1")
+
+	t.Run("rotates", func(t *testing.T) {
+		agents = nil
+		rt := UserAgent("a", "b")(base)
+		for range 3 {
+			_, err := rt.RoundTrip(req)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, []string{"a", "b", "a"}, agents)
+	})
+
+	t.Run("none", func(t *testing.T) {
+		agents = nil
+		_, err := UserAgent()(base).RoundTrip(req)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"caller/1"}, agents)
+	})
+}
+
+func TestHeaders(t *testing.T) {
+	t.Parallel()
+
+	var got http.Header
+	base := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		got = req.Header
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+	header := http.Header{"Accept": {"application/json"}, "X-Token": {"one", "two"}}
+	rt := Headers(header)(base)
+	header.Set("Accept", "text/html") // later changes by the caller do not apply
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "*/*")
+	req.Header.Set("Cookie", "session=1")
+	_, err = rt.RoundTrip(req)
+	require.NoError(t, err)
+
+	assert.Equal(t, "application/json", got.Get("Accept"))
+	assert.Equal(t, []string{"one", "two"}, got.Values("X-Token"))
+	assert.Equal(t, "session=1", got.Get("Cookie"))
+	assert.Equal(t, "*/*", req.Header.Get("Accept"), "caller's request is not modified")
+}
+
+func TestQueryUserAgent(t *testing.T) {
+	t.Parallel()
+
+	// headerServer records the requests' headers by path
+	headerServer := func(t *testing.T) (*httptest.Server, func(path string) []http.Header) {
+		t.Helper()
+		var mu sync.Mutex
+		byPath := make(map[string][]http.Header)
+		server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			byPath[r.URL.Path] = append(byPath[r.URL.Path], r.Header.Clone())
+			mu.Unlock()
+		}))
+		t.Cleanup(server.Close)
+		return server, func(path string) []http.Header {
+			mu.Lock()
+			defer mu.Unlock()
+			return byPath[path]
+		}
+	}
+	agentsOf := func(headers []http.Header) []string {
+		agents := make([]string, len(headers))
+		for i, h := range headers {
+			agents[i] = h.Get("User-Agent")
+		}
+		return agents
+	}
+
+	t.Run("source_profile", func(t *testing.T) {
+		server, requests := headerServer(t)
+		scraper := requestingSource("scraper", server.URL+"/scraper", 2)
+		scraper.UserAgent = sources.UserAgentBrowser
+
+		_, err := Collect(Query(t.Context(), "example.com",
+			WithSources([]sources.Source{requestingSource("api", server.URL+"/api", 1), scraper})))
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{defaultUserAgent}, agentsOf(requests("/api")))
+		assert.Equal(t, browserUserAgents[:2], agentsOf(requests("/scraper")))
+	})
+
+	t.Run("configured", func(t *testing.T) {
+		server, requests := headerServer(t)
+		scraper := requestingSource("scraper", server.URL+"/scraper", 1)
+		scraper.UserAgent = sources.UserAgentBrowser
+		grouped := requestingSource("grouped", server.URL+"/grouped", 1)
+		grouped.Group = "provider"
+
+		_, err := Collect(Query(t.Context(), "example.com",
+			WithSources([]sources.Source{requestingSource("api", server.URL+"/api", 3), scraper, grouped}),
+			WithUserAgent("global/1", "global/2"),
+			WithSourceUserAgent("api", "api/1", "api/2"),
+			WithSourceUserAgent("provider", "provider/1"),
+		))
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"api/1", "api/2", "api/1"}, agentsOf(requests("/api")))
+		assert.Equal(t, []string{"global/1"}, agentsOf(requests("/scraper")))
+		assert.Equal(t, []string{"provider/1"}, agentsOf(requests("/grouped")))
+	})
+
+	t.Run("source_headers", func(t *testing.T) {
+		server, requests := headerServer(t)
+
+		_, err := Collect(Query(t.Context(), "example.com",
+			WithSources([]sources.Source{
+				requestingSource("one", server.URL+"/one", 1),
+				requestingSource("two", server.URL+"/two", 1),
+			}),
+			WithSourceHeader("one", "X-Api-Token", "secret"),
+			WithSourceHeader("one", "Accept", "application/json"),
+		))
+		require.NoError(t, err)
+
+		require.Len(t, requests("/one"), 1)
+		assert.Equal(t, "secret", requests("/one")[0].Get("X-Api-Token"))
+		assert.Equal(t, "application/json", requests("/one")[0].Get("Accept"))
+		require.Len(t, requests("/two"), 1)
+		assert.Empty(t, requests("/two")[0].Get("X-Api-Token"))
+	})
+}
+
+func TestRetry(t *testing.T) {
+	t.Parallel()
+
+	fast := RetryOptions{MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+
+	get := func(t *testing.T, client *http.Client, url string) (*http.Response, error) {
+		t.Helper()
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, url, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	t.Run("retries_until_success", func(t *testing.T) {
+		server, requests := statusSequence(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+		client := &http.Client{Transport: Retry(fast)(server.Client().Transport)}
+
+		resp, err := get(t, client, server.URL)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int32(3), requests.Load())
+	})
+
+	t.Run("max_retries", func(t *testing.T) {
+		server, requests := statusSequence(t, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+		opts := fast
+		opts.MaxRetries = 1
+		client := &http.Client{Transport: Retry(opts)(server.Client().Transport)}
+
+		resp, err := get(t, client, server.URL)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+		assert.Equal(t, int32(2), requests.Load())
+	})
+
+	t.Run("client_error_not_retried", func(t *testing.T) {
+		server, requests := statusSequence(t, http.StatusNotFound)
+		client := &http.Client{Transport: Retry(fast)(server.Client().Transport)}
+
+		resp, err := get(t, client, server.URL)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Equal(t, int32(1), requests.Load())
+	})
+
+	t.Run("custom_retry_if", func(t *testing.T) {
+		server, requests := statusSequence(t, http.StatusNotFound)
+		opts := fast
+		opts.RetryIf = func(resp *http.Response, err error) bool { return err == nil && resp.StatusCode == http.StatusNotFound }
+		client := &http.Client{Transport: Retry(opts)(server.Client().Transport)}
+
+		resp, err := get(t, client, server.URL)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int32(2), requests.Load())
+	})
+
+	t.Run("transport_error", func(t *testing.T) {
+		var attempts atomic.Int32
+		base := RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+			if attempts.Add(1) == 1 {
+				return nil, errors.New("connection reset")
+			}
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		})
+		client := &http.Client{Transport: Retry(fast)(base)}
+
+		resp, err := get(t, client, "http://example.com/")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, int32(2), attempts.Load())
+	})
+
+	t.Run("budget_exceeded_not_retried", func(t *testing.T) {
+		var attempts atomic.Int32
+		base := RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+			attempts.Add(1)
+			return nil, ErrRequestBudgetExceeded
+		})
+		client := &http.Client{Transport: Retry(fast)(base)}
+
+		_, err := get(t, client, "http://example.com/")
+		require.ErrorIs(t, err, ErrRequestBudgetExceeded)
+		assert.Equal(t, int32(1), attempts.Load())
+	})
+
+	t.Run("retry_after_bounded", func(t *testing.T) {
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			if requests.Add(1) == 1 {
+				w.Header().Set("Retry-After", "3600")
+				w.WriteHeader(http.StatusTooManyRequests)
+			}
+		}))
+		t.Cleanup(server.Close)
+		client := &http.Client{Transport: Retry(fast)(server.Client().Transport)}
+
+		start := time.Now()
+		resp, err := get(t, client, server.URL)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("replays_body", func(t *testing.T) {
+		var mu sync.Mutex
+		var bodies []string
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			bodies = append(bodies, string(body))
+			mu.Unlock()
+			if requests.Add(1) == 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}))
+		t.Cleanup(server.Close)
+		client := &http.Client{Transport: Retry(fast)(server.Client().Transport)}
+
+		resp, err := client.Post(server.URL, "text/plain", bytes.NewBufferString("payload"))
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, []string{"payload", "payload"}, bodies)
+	})
+
+	t.Run("body_without_get_body", func(t *testing.T) {
+		server, requests := statusSequence(t, http.StatusInternalServerError)
+		client := &http.Client{Transport: Retry(fast)(server.Client().Transport)}
+
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodPost, server.URL, io.NopCloser(bytes.NewBufferString("payload")))
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		assert.Equal(t, int32(1), requests.Load())
+	})
+
+	t.Run("cancelled_during_backoff", func(t *testing.T) {
+		server, _ := statusSequence(t, http.StatusServiceUnavailable)
+		client := &http.Client{Transport: Retry(RetryOptions{MinBackoff: time.Hour})(server.Client().Transport)}
+
+		ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+		_, err = client.Do(req)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestQueryMiddleware(t *testing.T) {
+	t.Parallel()
+
+	t.Run("order", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+		t.Cleanup(server.Close)
+
+		var mu sync.Mutex
+		var calls []string
+		_, err := Collect(Query(t.Context(), "example.com",
+			WithSources([]sources.Source{requestingSource("one", server.URL, 1)}),
+			WithParallelism(1),
+			WithMiddleware(recordingMiddleware("outer", &mu, &calls), recordingMiddleware("inner", &mu, &calls)),
+			WithSourceMiddleware("one", recordingMiddleware("source", &mu, &calls)),
+			WithSourceMiddleware("two", recordingMiddleware("other", &mu, &calls)),
+		))
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"outer " + defaultUserAgent, "inner " + defaultUserAgent, "source " + defaultUserAgent}, calls)
+	})
+
+	t.Run("custom_client", func(t *testing.T) {
+		var agent atomic.Value
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			agent.Store(r.Header.Get("User-Agent"))
+			http.Redirect(w, r, "/elsewhere", http.StatusFound)
+		}))
+		t.Cleanup(server.Close)
+
+		_, err := Collect(Query(t.Context(), "example.com",
+			WithSources([]sources.Source{requestingSource("src", server.URL, 1)}),
+			WithHTTPClient(server.Client()),
+		))
+		require.ErrorIs(t, err, errNoRedirects)
+		assert.Equal(t, int32(1), requests.Load())
+		assert.Equal(t, defaultUserAgent, agent.Load())
+	})
+
+	t.Run("custom_redirect_policy", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/elsewhere" {
+				http.Redirect(w, r, "/elsewhere", http.StatusFound)
+			}
+		}))
+		t.Cleanup(server.Close)
+		client := server.Client()
+		client.CheckRedirect = func(*http.Request, []*http.Request) error { return nil }
+
+		_, err := Collect(Query(t.Context(), "example.com",
+			WithSources([]sources.Source{requestingSource("src", server.URL, 1)}),
+			WithHTTPClient(client),
+		))
+		require.NoError(t, err)
+	})
+
+	t.Run("retry_counted_and_limited", func(t *testing.T) {
+		server, requests := statusSequence(t, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+
+		var summary *Summary
+		var retries atomic.Int32
+		_, err := Collect(Query(t.Context(), "example.com",
+			WithSources([]sources.Source{requestingSource("src", server.URL, 1)}),
+			WithRetry(RetryOptions{MinBackoff: time.Millisecond}),
+			WithMaxRequests(2),
+			WithObserver(retryCounter{count: &retries}),
+			WithSummary(func(s *Summary) { summary = s }),
+		))
+		require.ErrorIs(t, err, ErrRequestBudgetExceeded)
+
+		require.NotNil(t, summary)
+		assert.Equal(t, int32(2), requests.Load())
+		assert.Equal(t, int64(2), summary.Source("src").Requests)
+		assert.Equal(t, int32(2), retries.Load())
+	})
+}
+
+// retryCounter counts retry events.
+type retryCounter struct {
+	NopObserver
+	count *atomic.Int32
+}
+
+func (o retryCounter) Retry(RetryEvent) {
+	o.count.Add(1)
+}
@@ -0,0 +1,349 @@
+// Package monitor runs scout queries against a list of targets on a schedule, sending new findings to sinks.
+//
+// Each target keeps its state in a baseline, so only results not seen by a previous run are reported,
+// including across restarts. Rate limiters are shared by every run, so source rate limits apply across
+// the whole process rather than to each query.
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-appsec/scout"
+	"github.com/go-appsec/scout/baseline"
+	"github.com/go-appsec/scout/normalize"
+)
+
+const (
+	defaultInterval = 24 * time.Hour
+	defaultJitter   = 5 * time.Minute
+)
+
+// ErrRunIncomplete is returned when a run is cancelled before its baseline is saved.
+var ErrRunIncomplete = errors.New("run incomplete")
+
+// config holds the Monitor options.
+type config struct {
+	interval        time.Duration
+	jitter          time.Duration
+	concurrency     int
+	shutdownTimeout time.Duration
+	stateDir        string
+	store           func(domain string) baseline.Store
+	queryOpts       []scout.Option
+	sinks           []Sink
+	initial         bool
+	logger          *slog.Logger
+}
+
+// Option configures a Monitor.
+type Option func(*config)
+
+// WithInterval sets the time between runs of targets without their own interval. Default is 24 hours.
+func WithInterval(d time.Duration) Option {
+	return func(c *config) {
+		c.interval = d
+	}
+}
+
+// WithJitter sets the maximum random delay added to each scheduled run, spreading targets out. Default is 5 minutes.
+func WithJitter(d time.Duration) Option {
+	return func(c *config) {
+		c.jitter = d
+	}
+}
+
+// WithConcurrency sets how many targets may run at the same time. Default is 1.
+func WithConcurrency(n int) Option {
+	return func(c *config) {
+		c.concurrency = n
+	}
+}
+
+// WithShutdownTimeout bounds how long in-flight runs may continue once shutdown starts.
+// Runs cut short leave their baseline unchanged. Default is 0, waiting for runs to finish.
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.shutdownTimeout = d
+	}
+}
+
+// WithStateDir sets the directory holding a JSONL baseline file per target. Default is the working directory.
+func WithStateDir(dir string) Option {
+	return func(c *config) {
+		c.stateDir = dir
+	}
+}
+
+// WithStore sets the baseline store for each target, replacing the files in the state directory.
+// If the store has a Modified() (time.Time, error) method, it is used to schedule the first run after a restart.
+func WithStore(fn func(domain string) baseline.Store) Option {
+	return func(c *config) {
+		c.store = fn
+	}
+}
+
+// WithQueryOptions sets the options used for every query, such as sources, API keys, and rate limits.
+func WithQueryOptions(opts ...scout.Option) Option {
+	return func(c *config) {
+		c.queryOpts = opts
+	}
+}
+
+// WithSink adds a sink receiving the changes of every run which added or removed results.
+func WithSink(s Sink) Option {
+	return func(c *config) {
+		c.sinks = append(c.sinks, s)
+	}
+}
+
+// WithInitialResults sends the first run of a target to sinks.
+// By default the first run only records the baseline, as every result would be new.
+func WithInitialResults() Option {
+	return func(c *config) {
+		c.initial = true
+	}
+}
+
+// WithLogger sets the logger for run progress and errors. Default discards all records.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *config) {
+		c.logger = l
+	}
+}
+
+// Monitor runs scheduled queries for a set of targets.
+type Monitor struct {
+	targets  []Target
+	cfg      config
+	limiters *scout.RateLimiters
+	sem      chan struct{}
+	sinkMu   sync.Mutex
+}
+
+// New creates a Monitor for the targets.
+func New(targets []Target, opts ...Option) *Monitor {
+	cfg := config{
+		interval:    defaultInterval,
+		jitter:      defaultJitter,
+		concurrency: 1,
+		stateDir:    ".",
+		logger:      slog.New(slog.DiscardHandler),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.concurrency < 1 {
+		cfg.concurrency = 1
+	}
+	if cfg.store == nil {
+		dir := cfg.stateDir
+		cfg.store = func(domain string) baseline.Store {
+			return baseline.NewFile(filepath.Join(dir, domain+".jsonl"))
+		}
+	}
+	return &Monitor{
+		targets:  targets,
+		cfg:      cfg,
+		limiters: scout.NewRateLimiters(),
+		sem:      make(chan struct{}, cfg.concurrency),
+	}
+}
+
+// Run schedules the targets until ctx is done, then waits for in-flight runs to finish before returning.
+// The first run of each target is delayed by jitter, or until its interval has passed since the last saved baseline.
+func (m *Monitor) Run(ctx context.Context) error {
+	if len(m.targets) == 0 {
+		return errors.New("monitor: no targets")
+	} else if err := m.checkTargets(); err != nil {
+		return err
+	} else if err := os.MkdirAll(m.cfg.stateDir, 0o755); err != nil {
+		return fmt.Errorf("monitor: %w", err)
+	}
+
+	// Runs continue through shutdown so their baselines are saved, unless the shutdown timeout passes
+	runCtx, cancelRuns := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelRuns()
+
+	var wg sync.WaitGroup
+	for _, t := range m.targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.schedule(ctx, runCtx, t)
+		}()
+	}
+
+	<-ctx.Done()
+	m.cfg.logger.InfoContext(runCtx, "shutting down, waiting for in-flight runs")
+	if m.cfg.shutdownTimeout > 0 {
+		timer := time.AfterFunc(m.cfg.shutdownTimeout, cancelRuns)
+		defer timer.Stop()
+	}
+	wg.Wait()
+	return nil
+}
+
+// checkTargets returns an error for a target which is not a canonical hostname, as each names its state file.
+func (m *Monitor) checkTargets() error {
+	for _, t := range m.targets {
+		if !normalize.ValidHostname(t.Domain) {
+			return fmt.Errorf("monitor: invalid target domain %q", t.Domain)
+		}
+	}
+	return nil
+}
+
+// RunOnce runs every target immediately, respecting the concurrency limit, and returns once all runs finish.
+// Returned are the errors of runs which did not complete or could not be sent to a sink.
+func (m *Monitor) RunOnce(ctx context.Context) error {
+	if err := m.checkTargets(); err != nil {
+		return err
+	} else if err := os.MkdirAll(m.cfg.stateDir, 0o755); err != nil {
+		return fmt.Errorf("monitor: %w", err)
+	}
+
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	for _, t := range m.targets {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return errors.Join(append(errs, ctx.Err())...)
+		case m.sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-m.sem }()
+			if err := m.scan(ctx, t); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// schedule runs a target each interval until ctx is done. Runs use runCtx so they are not interrupted by shutdown.
+func (m *Monitor) schedule(ctx, runCtx context.Context, t Target) {
+	interval := t.Interval
+	if interval <= 0 {
+		interval = m.cfg.interval
+	}
+
+	next := time.Now().Add(m.randomJitter())
+	if last := m.lastRun(t.Domain); !last.IsZero() && last.Add(interval).After(next) {
+		next = last.Add(interval).Add(m.randomJitter())
+	}
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case m.sem <- struct{}{}:
+		}
+		if ctx.Err() == nil {
+			_ = m.scan(runCtx, t) // errors are logged
+		}
+		<-m.sem
+		timer.Reset(interval + m.randomJitter())
+	}
+}
+
+// randomJitter returns a random delay up to the configured jitter.
+func (m *Monitor) randomJitter() time.Duration {
+	if m.cfg.jitter <= 0 {
+		return 0
+	}
+	return rand.N(m.cfg.jitter)
+}
+
+// lastRun returns when the target's baseline was last saved, if the store reports it.
+func (m *Monitor) lastRun(domain string) time.Time {
+	store, ok := m.cfg.store(domain).(interface{ Modified() (time.Time, error) })
+	if !ok {
+		return time.Time{}
+	}
+	modified, err := store.Modified()
+	if err != nil {
+		m.cfg.logger.Warn("reading last run failed", "target", domain, "error", err)
+		return time.Time{}
+	}
+	return modified
+}
+
+// scan runs a single query for the target and sends the changes to the sinks.
+// Changes are sent before the baseline is saved, so changes a sink failed to receive are sent again by the next run.
+func (m *Monitor) scan(ctx context.Context, t Target) error {
+	log := m.cfg.logger.With("target", t.Domain)
+	start := time.Now()
+	log.InfoContext(ctx, "run started")
+
+	var changes *baseline.Changes
+	var sendErr error
+	b := baseline.New(m.cfg.store(t.Domain),
+		baseline.WithBeforeSave(func(ctx context.Context, c *baseline.Changes) error {
+			if c.Initial && !m.cfg.initial {
+				return nil
+			} else if len(c.Added) == 0 && len(c.Removed) == 0 {
+				return nil
+			}
+			sendErr = m.send(ctx, c)
+			return sendErr
+		}),
+		baseline.WithChanges(func(c *baseline.Changes) {
+			changes = c
+		}))
+	opts := append(slices.Clone(m.cfg.queryOpts), scout.WithRateLimiters(m.limiters))
+	var errCount int
+	for _, err := range b.Query(ctx, t.Domain, opts...) {
+		if err != nil && sendErr == nil { // a failed send is logged by send
+			errCount++
+			log.WarnContext(ctx, "run error", "error", err)
+		}
+	}
+	if sendErr != nil {
+		log.WarnContext(ctx, "run not delivered, baseline unchanged", "duration", time.Since(start))
+		return sendErr
+	} else if changes == nil {
+		log.WarnContext(ctx, "run incomplete, baseline unchanged", "duration", time.Since(start))
+		return fmt.Errorf("monitor: %s: %w", t.Domain, ErrRunIncomplete)
+	}
+	log.InfoContext(ctx, "run finished", "duration", time.Since(start),
+		"added", len(changes.Added), "removed", len(changes.Removed), "errors", errCount)
+	return nil
+}
+
+// send delivers changes to each sink in turn.
+func (m *Monitor) send(ctx context.Context, changes *baseline.Changes) error {
+	m.sinkMu.Lock()
+	defer m.sinkMu.Unlock()
+
+	var errs []error
+	for _, sink := range m.cfg.sinks {
+		if err := sink.Send(ctx, changes); err != nil {
+			m.cfg.logger.ErrorContext(ctx, "sink failed", "target", changes.Domain, "error", err)
+			errs = append(errs, fmt.Errorf("monitor: %s: %w", changes.Domain, err))
+		}
+	}
+	return errors.Join(errs...)
+}
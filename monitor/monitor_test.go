@@ -0,0 +1,319 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"iter"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-appsec/scout"
+	"github.com/go-appsec/scout/baseline"
+	"github.com/go-appsec/scout/output"
+	"github.com/go-appsec/scout/sources"
+)
+
+// countingSource yields one more subdomain on each run of a domain, so every run after the first adds a result.
+func countingSource() sources.Source {
+	var mu sync.Mutex
+	runs := make(map[string]int)
+	return sources.Source{
+		Name:   "counting",
+		Yields: sources.Subdomain,
+		Run: func(_ context.Context, _ *http.Client, domain string, _ string) iter.Seq2[sources.Result, error] {
+			mu.Lock()
+			runs[domain]++
+			n := runs[domain]
+			mu.Unlock()
+			return func(yield func(sources.Result, error) bool) {
+				for i := range n {
+					value := string(rune('a'+i)) + "." + domain
+					if !yield(sources.Result{Type: sources.Subdomain, Value: value, Source: "counting"}, nil) {
+						return
+					}
+				}
+			}
+		},
+	}
+}
+
+// blockingSource yields a result once released, or stops when its context is done.
+func blockingSource(started chan<- struct{}, release <-chan struct{}) sources.Source {
+	return sources.Source{
+		Name:   "blocking",
+		Yields: sources.Subdomain,
+		Run: func(ctx context.Context, _ *http.Client, domain string, _ string) iter.Seq2[sources.Result, error] {
+			return func(yield func(sources.Result, error) bool) {
+				started <- struct{}{}
+				select {
+				case <-ctx.Done():
+				case <-release:
+					yield(sources.Result{Type: sources.Subdomain, Value: "api." + domain, Source: "blocking"}, nil)
+				}
+			}
+		},
+	}
+}
+
+// recordingSink records the changes it receives.
+type recordingSink struct {
+	mu      sync.Mutex
+	changes []*baseline.Changes
+	sent    chan struct{}
+}
+
+func newRecordingSink() *recordingSink {
+	return &recordingSink{sent: make(chan struct{}, 100)}
+}
+
+func (s *recordingSink) Send(_ context.Context, c *baseline.Changes) error {
+	s.mu.Lock()
+	s.changes = append(s.changes, c)
+	s.mu.Unlock()
+	s.sent <- struct{}{}
+	return nil
+}
+
+func (s *recordingSink) received() []*baseline.Changes {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*baseline.Changes(nil), s.changes...)
+}
+
+func TestMonitorRunOnce(t *testing.T) {
+	t.Parallel()
+
+	t.Run("skips_initial_run", func(t *testing.T) {
+		dir := t.TempDir()
+		sink := newRecordingSink()
+		m := New([]Target{{Domain: "example.com"}, {Domain: "example.org"}},
+			WithStateDir(dir),
+			WithSink(sink),
+			WithConcurrency(2),
+			WithQueryOptions(scout.WithSources([]sources.Source{countingSource()})),
+		)
+
+		require.NoError(t, m.RunOnce(t.Context()))
+		assert.Empty(t, sink.received())
+		assert.FileExists(t, filepath.Join(dir, "example.com.jsonl"))
+		assert.FileExists(t, filepath.Join(dir, "example.org.jsonl"))
+
+		require.NoError(t, m.RunOnce(t.Context()))
+		received := sink.received()
+		require.Len(t, received, 2)
+		for _, c := range received {
+			assert.Len(t, c.Added, 1)
+			assert.False(t, c.Initial)
+		}
+	})
+
+	t.Run("initial_results", func(t *testing.T) {
+		sink := newRecordingSink()
+		m := New([]Target{{Domain: "example.com"}},
+			WithStateDir(t.TempDir()),
+			WithSink(sink),
+			WithInitialResults(),
+			WithQueryOptions(scout.WithSources([]sources.Source{countingSource()})),
+		)
+
+		require.NoError(t, m.RunOnce(t.Context()))
+
+		received := sink.received()
+		require.Len(t, received, 1)
+		assert.True(t, received[0].Initial)
+		assert.Equal(t, "a.example.com", received[0].Added[0].Value)
+	})
+
+	t.Run("sink_error", func(t *testing.T) {
+		m := New([]Target{{Domain: "example.com"}},
+			WithStateDir(t.TempDir()),
+			WithInitialResults(),
+			WithSink(SinkFunc(func(context.Context, *baseline.Changes) error {
+				return assert.AnError
+			})),
+			WithQueryOptions(scout.WithSources([]sources.Source{countingSource()})),
+		)
+
+		assert.ErrorIs(t, m.RunOnce(t.Context()), assert.AnError)
+	})
+
+	t.Run("resends_after_sink_error", func(t *testing.T) {
+		sink := newRecordingSink()
+		var fail bool
+		m := New([]Target{{Domain: "example.com"}},
+			WithStateDir(t.TempDir()),
+			WithSink(SinkFunc(func(ctx context.Context, c *baseline.Changes) error {
+				if fail {
+					return assert.AnError
+				}
+				return sink.Send(ctx, c)
+			})),
+			WithQueryOptions(scout.WithSources([]sources.Source{countingSource()})),
+		)
+
+		require.NoError(t, m.RunOnce(t.Context()))
+		fail = true
+		assert.ErrorIs(t, m.RunOnce(t.Context()), assert.AnError)
+		fail = false
+		require.NoError(t, m.RunOnce(t.Context()))
+
+		// The finding of the failed run is sent with those of the next
+		received := sink.received()
+		require.Len(t, received, 1)
+		require.Len(t, received[0].Added, 2)
+		assert.Equal(t, "b.example.com", received[0].Added[0].Value)
+		assert.Equal(t, "c.example.com", received[0].Added[1].Value)
+	})
+}
+
+func TestMonitorRun(t *testing.T) {
+	t.Parallel()
+
+	t.Run("runs_on_interval", func(t *testing.T) {
+		sink := newRecordingSink()
+		m := New([]Target{{Domain: "example.com", Interval: 10 * time.Millisecond}},
+			WithStateDir(t.TempDir()),
+			WithJitter(time.Millisecond),
+			WithSink(sink),
+			WithQueryOptions(scout.WithSources([]sources.Source{countingSource()})),
+		)
+		ctx, cancel := context.WithCancel(t.Context())
+		done := make(chan error)
+		go func() { done <- m.Run(ctx) }()
+
+		for range 2 {
+			select {
+			case <-sink.sent:
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for scheduled runs")
+			}
+		}
+		cancel()
+		require.NoError(t, <-done)
+
+		received := sink.received()
+		require.GreaterOrEqual(t, len(received), 2)
+		assert.Equal(t, "b.example.com", received[0].Added[0].Value) // first run recorded the baseline only
+	})
+
+	t.Run("finishes_in_flight_run", func(t *testing.T) {
+		dir := t.TempDir()
+		started, release := make(chan struct{}, 1), make(chan struct{})
+		m := New([]Target{{Domain: "example.com"}},
+			WithStateDir(dir),
+			WithJitter(0),
+			WithQueryOptions(scout.WithSources([]sources.Source{blockingSource(started, release)})),
+		)
+		ctx, cancel := context.WithCancel(t.Context())
+		done := make(chan error)
+		go func() { done <- m.Run(ctx) }()
+
+		<-started
+		cancel()
+		select {
+		case <-done:
+			t.Fatal("returned before in-flight run finished")
+		case <-time.After(20 * time.Millisecond):
+		}
+		close(release)
+		require.NoError(t, <-done)
+
+		saved, err := baseline.NewFile(filepath.Join(dir, "example.com.jsonl")).Load()
+		require.NoError(t, err)
+		require.Len(t, saved, 1)
+		assert.Equal(t, "api.example.com", saved[0].Value)
+	})
+
+	t.Run("shutdown_timeout", func(t *testing.T) {
+		dir := t.TempDir()
+		started := make(chan struct{}, 1)
+		m := New([]Target{{Domain: "example.com"}},
+			WithStateDir(dir),
+			WithJitter(0),
+			WithShutdownTimeout(10*time.Millisecond),
+			WithQueryOptions(scout.WithSources([]sources.Source{blockingSource(started, nil)})),
+		)
+		ctx, cancel := context.WithCancel(t.Context())
+		done := make(chan error)
+		go func() { done <- m.Run(ctx) }()
+
+		<-started
+		cancel()
+		require.NoError(t, <-done)
+
+		assert.NoFileExists(t, filepath.Join(dir, "example.com.jsonl"))
+	})
+
+	t.Run("resumes_from_saved_baseline", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, baseline.NewFile(filepath.Join(dir, "example.com.jsonl")).Save(nil))
+		var runs atomic.Int64
+		counted := sources.Source{
+			Name:   "counted",
+			Yields: sources.Subdomain,
+			Run: func(context.Context, *http.Client, string, string) iter.Seq2[sources.Result, error] {
+				runs.Add(1)
+				return func(func(sources.Result, error) bool) {}
+			},
+		}
+		m := New([]Target{{Domain: "example.com"}},
+			WithStateDir(dir),
+			WithInterval(time.Hour),
+			WithJitter(0),
+			WithQueryOptions(scout.WithSources([]sources.Source{counted})),
+		)
+		ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+		defer cancel()
+
+		require.NoError(t, m.Run(ctx))
+		assert.Zero(t, runs.Load())
+	})
+
+	t.Run("no_targets", func(t *testing.T) {
+		assert.Error(t, New(nil).Run(t.Context()))
+	})
+
+	t.Run("invalid_target", func(t *testing.T) {
+		dir := t.TempDir()
+		m := New([]Target{{Domain: "example.com"}, {Domain: "../x"}}, WithStateDir(filepath.Join(dir, "state")))
+		assert.ErrorContains(t, m.Run(t.Context()), `invalid target domain "../x"`)
+		assert.ErrorContains(t, m.RunOnce(t.Context()), `invalid target domain "../x"`)
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, entries, "nothing is written")
+	})
+}
+
+func TestEncoderSink(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	sink := EncoderSink(output.NewText(&buf))
+
+	require.NoError(t, sink.Send(t.Context(), &baseline.Changes{Added: []sources.Result{
+		{Type: sources.Subdomain, Value: "a.example.com"},
+		{Type: sources.Subdomain, Value: "b.example.com"},
+	}}))
+
+	assert.Equal(t, "a.example.com\nb.example.com\n", buf.String())
+}
+
+func TestDefaultStateDir(t *testing.T) {
+	t.Parallel()
+
+	m := New([]Target{{Domain: "example.com"}})
+
+	store, ok := m.cfg.store("example.com").(*baseline.File)
+	require.True(t, ok)
+	assert.Equal(t, "example.com.jsonl", store.Path())
+	_, err := os.Stat(m.cfg.stateDir)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,42 @@
+package monitor
+
+import (
+	"context"
+
+	"github.com/go-appsec/scout/baseline"
+	"github.com/go-appsec/scout/output"
+)
+
+// Sink receives the changes found by each completed run.
+// The monitor does not call sinks concurrently. An error leaves the target's baseline unchanged,
+// so the next run sends the changes again, including to sinks which did receive them.
+type Sink interface {
+	Send(ctx context.Context, changes *baseline.Changes) error
+}
+
+// SinkFunc adapts a function to Sink.
+type SinkFunc func(ctx context.Context, changes *baseline.Changes) error
+
+func (f SinkFunc) Send(ctx context.Context, changes *baseline.Changes) error {
+	return f(ctx, changes)
+}
+
+// encoderSink writes added results to an output encoder.
+type encoderSink struct {
+	enc output.Encoder
+}
+
+// EncoderSink returns a sink writing the added results of each run to the encoder.
+// Use a streaming encoder, the report encoder only writes when closed.
+func EncoderSink(enc output.Encoder) Sink {
+	return &encoderSink{enc: enc}
+}
+
+func (s *encoderSink) Send(_ context.Context, changes *baseline.Changes) error {
+	for _, r := range changes.Added {
+		if err := s.enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package monitor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/go-appsec/scout/normalize"
+)
+
+// Target is a domain scanned on a schedule.
+type Target struct {
+	Domain   string        // Domain to query
+	Interval time.Duration // Time between runs, if zero the monitor interval is used
+}
+
+// ParseTargets reads a target list with one domain per line, optionally followed by a run interval such as "6h".
+// Blank lines and lines starting with # are ignored. Domains must be valid hostnames, as each names its state file.
+func ParseTargets(r io.Reader) ([]Target, error) {
+	var targets []Target
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	var line int
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("monitor: line %d: expected domain and optional interval", line)
+		}
+		target := Target{Domain: normalize.Hostname(fields[0])}
+		if !normalize.ValidHostname(target.Domain) {
+			return nil, fmt.Errorf("monitor: line %d: invalid domain %q", line, fields[0])
+		}
+		if len(fields) == 2 {
+			interval, err := time.ParseDuration(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("monitor: line %d: %w", line, err)
+			} else if interval <= 0 {
+				return nil, fmt.Errorf("monitor: line %d: interval must be positive", line)
+			}
+			target.Interval = interval
+		}
+		if seen[target.Domain] {
+			return nil, fmt.Errorf("monitor: line %d: duplicate target %s", line, target.Domain)
+		}
+		seen[target.Domain] = true
+		targets = append(targets, target)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("monitor: %w", err)
+	}
+	return targets, nil
+}
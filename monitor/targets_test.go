@@ -0,0 +1,43 @@
+package monitor
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTargets(t *testing.T) {
+	t.Parallel()
+
+	t.Run("valid", func(t *testing.T) {
+		targets, err := ParseTargets(strings.NewReader("# production\nexample.com\n\n  Corp.Example.org.   6h\n"))
+		require.NoError(t, err)
+		assert.Equal(t, []Target{
+			{Domain: "example.com"},
+			{Domain: "corp.example.org", Interval: 6 * time.Hour},
+		}, targets)
+	})
+
+	errTests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{name: "extra_fields", input: "example.com 6h # nightly\n", wantErr: "line 1: expected domain"},
+		{name: "bad_interval", input: "example.com soon\n", wantErr: "line 1"},
+		{name: "negative_interval", input: "example.com\nexample.org -1h\n", wantErr: "line 2: interval must be positive"},
+		{name: "duplicate", input: "example.com\nEXAMPLE.com\n", wantErr: "duplicate target example.com"},
+		{name: "path_traversal", input: "example.com\n../x\n", wantErr: `line 2: invalid domain "../x"`},
+		{name: "path_separator", input: "a/b 1h\n", wantErr: `line 1: invalid domain "a/b"`},
+		{name: "url", input: "https://example.com\n", wantErr: "line 1: invalid domain"},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTargets(strings.NewReader(tt.input))
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package normalize
+
+import "strings"
+
+// Hostname returns the canonical form of a hostname: surrounding whitespace removed, lowercased,
+// and without a trailing dot, as "API.Example.com." and "api.example.com" name the same host.
+func Hostname(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
+
+// ValidHostname reports whether name, in canonical form, is a DNS hostname: dot-separated labels of
+// letters, digits, and hyphens, neither starting nor ending with a hyphen, at most 63 bytes each and 253 in total.
+func ValidHostname(name string) bool {
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for label := range strings.SplitSeq(name, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for i := range len(label) {
+			c := label[i]
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+				return false
+			}
+		}
+	}
+	return true
+}
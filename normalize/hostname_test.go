@@ -0,0 +1,59 @@
+package normalize
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostname(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		host string
+		want string
+	}{
+		{name: "canonical", host: "api.example.com", want: "api.example.com"},
+		{name: "lowercased", host: "API.Example.COM", want: "api.example.com"},
+		{name: "trailing_dot", host: "api.example.com.", want: "api.example.com"},
+		{name: "whitespace", host: " api.example.com.\n", want: "api.example.com"},
+		{name: "wildcard_kept", host: "*.Example.com", want: "*.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Hostname(tt.host))
+		})
+	}
+}
+
+func TestValidHostname(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		host string
+		want bool
+	}{
+		{name: "domain", host: "example.com", want: true},
+		{name: "subdomain", host: "api-v2.corp.example.com", want: true},
+		{name: "single_label", host: "localhost", want: true},
+		{name: "empty", host: "", want: false},
+		{name: "path_traversal", host: "../x", want: false},
+		{name: "slash", host: "a/b", want: false},
+		{name: "uppercase", host: "Example.com", want: false},
+		{name: "trailing_dot", host: "example.com.", want: false},
+		{name: "empty_label", host: "api..example.com", want: false},
+		{name: "leading_hyphen", host: "-api.example.com", want: false},
+		{name: "trailing_hyphen", host: "api-.example.com", want: false},
+		{name: "wildcard", host: "*.example.com", want: false},
+		{name: "long_label", host: strings.Repeat("a", 64) + ".com", want: false},
+		{name: "long_name", host: strings.Repeat("a.", 127) + "com", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ValidHostname(tt.host))
+		})
+	}
+}
@@ -0,0 +1,209 @@
+// Package normalize canonicalizes result values, so variants of the same value are recognized as duplicates.
+package normalize
+
+import (
+	"errors"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+)
+
+// URLOptions configures URL normalization beyond the canonical form every URL is put into.
+type URLOptions struct {
+	// StripParams removes query parameters by name, case-insensitively, such as TrackingParams.
+	// A name ending in "*" removes every parameter with that prefix.
+	StripParams []string
+
+	// StripQuery removes the whole query.
+	StripQuery bool
+
+	// KeepParamOrder keeps query parameters in their original order, by default they are sorted by name then value.
+	KeepParamOrder bool
+
+	// KeepFragment keeps the fragment, by default it is removed as browsers never send it to the server.
+	KeepFragment bool
+}
+
+// TrackingParams are query parameters added by analytics and ad platforms which do not change the page.
+var TrackingParams = []string{"utm_*", "fbclid", "gclid", "dclid", "msclkid", "mc_cid", "mc_eid", "_ga", "_gl", "yclid"}
+
+// defaultPorts are removed from hosts, they are implied by the scheme.
+var defaultPorts = map[string]string{"http": "80", "https": "443", "ws": "80", "wss": "443", "ftp": "21"}
+
+// URL returns the canonical form of an absolute URL:
+//
+//   - scheme and host are lowercased, a trailing dot on the host and the scheme's default port are removed
+//   - dot segments and repeated slashes are removed from the path, an empty path becomes "/"
+//   - percent-encoding is consistent, unreserved characters are decoded, other escapes use uppercase hex,
+//     and characters not allowed in the URL, such as spaces, are encoded
+//   - query parameters are sorted and empty ones removed, then filtered by opts
+//   - the fragment is removed unless opts keeps it
+//
+// The path and query keep their case, servers may treat them case-sensitively.
+func URL(raw string, opts URLOptions) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", err
+	} else if u.Scheme == "" || u.Host == "" {
+		return "", errors.New("normalize: URL must be absolute")
+	}
+
+	var b strings.Builder
+	b.Grow(len(raw))
+	b.WriteString(strings.ToLower(u.Scheme))
+	b.WriteString("://")
+	if u.User != nil {
+		b.WriteString(u.User.String())
+		b.WriteByte('@')
+	}
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	host = strings.TrimSuffix(host, ".")
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]" // IPv6
+	}
+	b.WriteString(host)
+	if port != "" && port != defaultPorts[strings.ToLower(u.Scheme)] {
+		b.WriteByte(':')
+		b.WriteString(port)
+	}
+
+	b.WriteString(cleanPath(escape(u.EscapedPath(), pathAllowed)))
+
+	if !opts.StripQuery {
+		if query := normalizeQuery(u.RawQuery, opts); query != "" {
+			b.WriteByte('?')
+			b.WriteString(query)
+		}
+	}
+	if opts.KeepFragment && u.Fragment != "" {
+		b.WriteByte('#')
+		b.WriteString(escape(u.EscapedFragment(), queryAllowed))
+	}
+	return b.String(), nil
+}
+
+// cleanPath removes dot segments and repeated slashes, keeping a trailing slash.
+func cleanPath(p string) string {
+	if p == "" || p == "/" {
+		return "/"
+	}
+	cleaned := path.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+// normalizeQuery returns the query with consistent escaping, empty and stripped parameters removed,
+// sorted unless opts keeps the order.
+func normalizeQuery(raw string, opts URLOptions) string {
+	if raw == "" {
+		return ""
+	}
+	type param struct{ name, value string }
+	var params []param
+	for pair := range strings.SplitSeq(raw, "&") {
+		if pair == "" {
+			continue
+		}
+		name, value, hasValue := strings.Cut(pair, "=")
+		name = escape(name, queryAllowed)
+		if stripped(name, opts.StripParams) {
+			continue
+		}
+		if hasValue {
+			value = "=" + escape(value, queryAllowed)
+		}
+		params = append(params, param{name: name, value: value})
+	}
+	if !opts.KeepParamOrder {
+		slices.SortStableFunc(params, func(a, b param) int {
+			if c := strings.Compare(a.name, b.name); c != 0 {
+				return c
+			}
+			return strings.Compare(a.value, b.value)
+		})
+	}
+
+	var b strings.Builder
+	for i, p := range params {
+		if i > 0 {
+			b.WriteByte('&')
+		}
+		b.WriteString(p.name)
+		b.WriteString(p.value)
+	}
+	return b.String()
+}
+
+// stripped reports whether the escaped parameter name matches a name or prefix pattern.
+func stripped(name string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return false
+	}
+	if unescaped, err := url.QueryUnescape(name); err == nil {
+		name = unescaped
+	}
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if len(name) >= len(prefix) && strings.EqualFold(name[:len(prefix)], prefix) {
+				return true
+			}
+		} else if strings.EqualFold(name, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	unreserved   = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-._~"
+	subDelims    = "!$&'()*+,;="
+	pathAllowed  = unreserved + subDelims + ":@/"
+	queryAllowed = unreserved + "!$'()*+,;:@/?" // & and = separate parameters, so are escaped within them
+)
+
+// escape returns s with unreserved characters decoded, remaining escapes in uppercase hex,
+// and characters outside allowed encoded. A % not starting a valid escape is encoded.
+func escape(s, allowed string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			decoded := unhex(s[i+1])<<4 | unhex(s[i+2])
+			if strings.IndexByte(unreserved, decoded) >= 0 {
+				b.WriteByte(decoded)
+			} else {
+				b.WriteByte('%')
+				b.WriteByte(hex[decoded>>4])
+				b.WriteByte(hex[decoded&15])
+			}
+			i += 2
+		} else if c != '%' && strings.IndexByte(allowed, c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&15])
+		}
+	}
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
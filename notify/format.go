@@ -0,0 +1,171 @@
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-appsec/scout/output"
+	"github.com/go-appsec/scout/sources"
+)
+
+// Headers set on signed webhook deliveries.
+const (
+	TimestampHeader = "X-Scout-Timestamp" // Unix time the delivery was signed
+	SignatureHeader = "X-Scout-Signature" // "sha256=" followed by the hex HMAC-SHA256 of the timestamp, ".", and the body
+)
+
+// discordMaxContent is the message length limit of Discord webhooks.
+const discordMaxContent = 2000
+
+// payloadFormat encodes batches for a delivery endpoint.
+type payloadFormat interface {
+	name() string
+	body(b *Batch, message string) ([]byte, error)
+	sign(req *http.Request, body []byte)
+}
+
+// WebhookPayload is the JSON body of a generic webhook delivery.
+type WebhookPayload struct {
+	Domain  string          `json:"domain"`
+	Message string          `json:"message"`
+	Results []output.Record `json:"results"`
+	Removed []output.Record `json:"removed,omitempty"`
+	Batch   int             `json:"batch"`
+	Batches int             `json:"batches,omitempty"`
+}
+
+// NewWebhook creates a Notifier posting a WebhookPayload to url.
+// If secret is non-empty, deliveries are signed with HMAC-SHA256, see VerifySignature.
+func NewWebhook(url, secret string, opts ...Option) *Notifier {
+	return newNotifier(url, webhookFormat{secret: []byte(secret)}, opts)
+}
+
+// webhookFormat encodes a WebhookPayload, signing it when a secret is set.
+type webhookFormat struct {
+	secret []byte
+}
+
+func (webhookFormat) name() string {
+	return "webhook"
+}
+
+func (webhookFormat) body(b *Batch, message string) ([]byte, error) {
+	return json.Marshal(WebhookPayload{
+		Domain:  b.Domain,
+		Message: message,
+		Results: records(b.Results),
+		Removed: records(b.Removed),
+		Batch:   b.Index,
+		Batches: b.Count,
+	})
+}
+
+func (f webhookFormat) sign(req *http.Request, body []byte) {
+	if len(f.secret) == 0 {
+		return
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(signature(f.secret, timestamp, body)))
+}
+
+// signature computes the HMAC-SHA256 of the timestamp and body.
+func signature(secret []byte, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// VerifySignature checks the signature header of a webhook delivery against its timestamp header and body.
+// Receivers should also reject timestamps too far from the current time to prevent replays.
+func VerifySignature(secret, timestamp, sig string, body []byte) error {
+	hexSig, ok := strings.CutPrefix(sig, "sha256=")
+	if !ok {
+		return errors.New("notify: unsupported signature scheme")
+	}
+	got, err := hex.DecodeString(hexSig)
+	if err != nil {
+		return errors.New("notify: malformed signature")
+	} else if !hmac.Equal(got, signature([]byte(secret), timestamp, body)) {
+		return errors.New("notify: signature mismatch")
+	}
+	return nil
+}
+
+// records converts results to their JSON records.
+func records(results []sources.Result) []output.Record {
+	if len(results) == 0 {
+		return nil
+	}
+	recs := make([]output.Record, len(results))
+	for i, r := range results {
+		recs[i] = output.NewRecord(r)
+	}
+	return recs
+}
+
+// NewSlack creates a Notifier posting messages to a Slack incoming webhook url.
+func NewSlack(url string, opts ...Option) *Notifier {
+	return newNotifier(url, slackFormat{}, opts)
+}
+
+// slackEscaper escapes the characters Slack treats as control sequences in message text.
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// slackFormat encodes the message as Slack text.
+type slackFormat struct{}
+
+func (slackFormat) name() string {
+	return "slack"
+}
+
+func (slackFormat) body(_ *Batch, message string) ([]byte, error) {
+	return json.Marshal(struct {
+		Text string `json:"text"`
+	}{slackEscaper.Replace(message)})
+}
+
+func (slackFormat) sign(*http.Request, []byte) {}
+
+// NewDiscord creates a Notifier posting messages to a Discord webhook url.
+// Messages over the Discord length limit are truncated, use a smaller batch size to avoid this.
+func NewDiscord(url string, opts ...Option) *Notifier {
+	return newNotifier(url, discordFormat{}, opts)
+}
+
+// discordFormat encodes the message as Discord content, with mentions disabled so results cannot ping users.
+type discordFormat struct{}
+
+func (discordFormat) name() string {
+	return "discord"
+}
+
+func (discordFormat) body(_ *Batch, message string) ([]byte, error) {
+	if len(message) > discordMaxContent {
+		const suffix = "\n…"
+		cut := discordMaxContent - len(suffix)
+		for cut > 0 && !utf8.RuneStart(message[cut]) {
+			cut--
+		}
+		message = message[:cut] + suffix
+	}
+	type allowedMentions struct {
+		Parse []string `json:"parse"`
+	}
+	return json.Marshal(struct {
+		Content         string          `json:"content"`
+		AllowedMentions allowedMentions `json:"allowed_mentions"`
+	}{message, allowedMentions{Parse: []string{}}})
+}
+
+func (discordFormat) sign(*http.Request, []byte) {}
@@ -0,0 +1,90 @@
+package notify
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSignature(t *testing.T) {
+	t.Parallel()
+
+	r, server := newReceiver(t)
+	n := NewWebhook(server.URL, "s3cret")
+
+	require.NoError(t, n.Notify(t.Context(), "example.com", subdomains("a.example.com")))
+
+	body := r.delivered()[0]
+	header := r.headers[0]
+	assert.Equal(t, "application/json", header.Get("Content-Type"))
+	timestamp, sig := header.Get(TimestampHeader), header.Get(SignatureHeader)
+	require.NotEmpty(t, timestamp)
+	require.True(t, strings.HasPrefix(sig, "sha256="))
+
+	require.NoError(t, VerifySignature("s3cret", timestamp, sig, body))
+	assert.Error(t, VerifySignature("wrong", timestamp, sig, body))
+	assert.Error(t, VerifySignature("s3cret", timestamp, sig, append(body, ' ')))
+	assert.Error(t, VerifySignature("s3cret", "0", sig, body))
+	assert.Error(t, VerifySignature("s3cret", timestamp, "md5=abc", body))
+	assert.Error(t, VerifySignature("s3cret", timestamp, "sha256=zz", body))
+}
+
+func TestWebhookUnsigned(t *testing.T) {
+	t.Parallel()
+
+	r, server := newReceiver(t)
+
+	require.NoError(t, NewWebhook(server.URL, "").Notify(t.Context(), "example.com", subdomains("a.example.com")))
+
+	assert.Empty(t, r.headers[0].Get(SignatureHeader))
+}
+
+func TestSlackFormat(t *testing.T) {
+	t.Parallel()
+
+	body, err := slackFormat{}.body(&Batch{}, "found <https://example.com/?a=1&b=2>")
+	require.NoError(t, err)
+
+	var payload struct {
+		Text string `json:"text"`
+	}
+	require.NoError(t, json.Unmarshal(body, &payload))
+	assert.Equal(t, "found &lt;https://example.com/?a=1&amp;b=2&gt;", payload.Text)
+}
+
+func TestDiscordFormat(t *testing.T) {
+	t.Parallel()
+
+	type discordPayload struct {
+		Content         string `json:"content"`
+		AllowedMentions struct {
+			Parse []string `json:"parse"`
+		} `json:"allowed_mentions"`
+	}
+
+	t.Run("disables_mentions", func(t *testing.T) {
+		body, err := discordFormat{}.body(&Batch{}, "@everyone.example.com")
+		require.NoError(t, err)
+
+		var payload discordPayload
+		require.NoError(t, json.Unmarshal(body, &payload))
+		assert.Equal(t, "@everyone.example.com", payload.Content)
+		assert.NotNil(t, payload.AllowedMentions.Parse)
+		assert.Empty(t, payload.AllowedMentions.Parse)
+	})
+
+	t.Run("truncates", func(t *testing.T) {
+		body, err := discordFormat{}.body(&Batch{}, strings.Repeat("é", discordMaxContent))
+		require.NoError(t, err)
+
+		var payload discordPayload
+		require.NoError(t, json.Unmarshal(body, &payload))
+		assert.LessOrEqual(t, len(payload.Content), discordMaxContent)
+		assert.True(t, utf8.ValidString(payload.Content))
+		assert.True(t, strings.HasSuffix(payload.Content, "\n…"))
+	})
+}
@@ -0,0 +1,266 @@
+// Package notify pushes new findings to webhooks and chat services.
+//
+// A Notifier groups results into batches, renders a message for each batch from a template,
+// and delivers it as a generic signed JSON webhook, or in a Slack or Discord compatible payload.
+// Failed deliveries are retried with backoff. A Notifier can be used as a monitor sink.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"slices"
+	"strconv"
+	"text/template"
+	"time"
+
+	"github.com/go-appsec/scout"
+	"github.com/go-appsec/scout/baseline"
+	"github.com/go-appsec/scout/sources"
+)
+
+const (
+	defaultBatchSize = 50
+	defaultAttempts  = 3
+	defaultBackoff   = time.Second
+	maxRetryAfter    = time.Minute
+)
+
+// Batch is a group of results delivered in a single message, it is the data passed to message templates.
+type Batch struct {
+	Domain  string           // Domain the results were found for
+	Results []sources.Result // New results
+	Removed []sources.Result // Results no longer found, when notifying baseline changes
+	Index   int              // Position of the batch, starting at 1
+	Count   int              // Number of batches in the notification, 0 when streaming as the total is not known
+}
+
+// DefaultTemplate is the message template used unless WithTemplate is set.
+var DefaultTemplate = template.Must(template.New("message").Parse(
+	`{{if .Results}}{{len .Results}} new result{{if gt (len .Results) 1}}s{{end}} for {{.Domain}}` +
+		`{{if gt .Count 1}} ({{.Index}}/{{.Count}}){{end}}` + "\n" +
+		`{{range .Results}}{{.Value}} ({{.Source}})` + "\n" + `{{end}}{{end}}` +
+		`{{if .Removed}}{{len .Removed}} result{{if gt (len .Removed) 1}}s{{end}} no longer found for {{.Domain}}` + "\n" +
+		`{{range .Removed}}{{.Value}} ({{.Source}})` + "\n" + `{{end}}{{end}}`))
+
+// config holds the Notifier options.
+type config struct {
+	client    *http.Client
+	template  *template.Template
+	batchSize int
+	attempts  int
+	backoff   time.Duration
+}
+
+// Option configures a Notifier.
+type Option func(*config)
+
+// WithHTTPClient sets the client used for deliveries.
+func WithHTTPClient(c *http.Client) Option {
+	return func(cfg *config) {
+		cfg.client = c
+	}
+}
+
+// WithTemplate sets the template rendering each Batch into a message.
+func WithTemplate(t *template.Template) Option {
+	return func(cfg *config) {
+		cfg.template = t
+	}
+}
+
+// WithBatchSize sets the maximum number of results in a single message. Default is 50.
+func WithBatchSize(n int) Option {
+	return func(cfg *config) {
+		cfg.batchSize = n
+	}
+}
+
+// WithRetry sets the delivery attempts and the initial backoff between them, which doubles after each attempt.
+// Default is 3 attempts with a 1 second initial backoff.
+func WithRetry(attempts int, backoff time.Duration) Option {
+	return func(cfg *config) {
+		cfg.attempts = attempts
+		cfg.backoff = backoff
+	}
+}
+
+// Notifier delivers batches of results to an endpoint.
+type Notifier struct {
+	url    string
+	format payloadFormat
+	cfg    config
+}
+
+func newNotifier(url string, format payloadFormat, opts []Option) *Notifier {
+	cfg := config{
+		client:    &http.Client{Timeout: 10 * time.Second},
+		template:  DefaultTemplate,
+		batchSize: defaultBatchSize,
+		attempts:  defaultAttempts,
+		backoff:   defaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.batchSize < 1 {
+		cfg.batchSize = 1
+	}
+	if cfg.attempts < 1 {
+		cfg.attempts = 1
+	}
+	return &Notifier{url: url, format: format, cfg: cfg}
+}
+
+// Notify delivers the results in batches.
+func (n *Notifier) Notify(ctx context.Context, domain string, results []sources.Result) error {
+	return n.deliverAll(ctx, n.batches(domain, results, nil))
+}
+
+// Send delivers the added and removed results of a baseline run, implementing monitor.Sink.
+// Added results are sent first, followed by batches of removed results.
+func (n *Notifier) Send(ctx context.Context, changes *baseline.Changes) error {
+	return n.deliverAll(ctx, n.batches(changes.Domain, changes.Added, changes.Removed))
+}
+
+// Consume reads results from seq, delivering each batch as it fills and any remaining results once seq ends.
+// Errors from seq are passed to onErr, which may be nil to discard them.
+// Returned are the delivery errors, a failed batch does not stop consumption.
+func (n *Notifier) Consume(ctx context.Context, domain string, seq iter.Seq2[sources.Result, error], onErr func(error)) error {
+	var errs []error
+	batch := &Batch{Domain: domain, Index: 1}
+	for result, err := range seq {
+		if err != nil {
+			if onErr != nil {
+				onErr(err)
+			}
+			continue
+		}
+		batch.Results = append(batch.Results, result)
+		if len(batch.Results) == n.cfg.batchSize {
+			if err := n.deliver(ctx, batch); err != nil {
+				errs = append(errs, err)
+			}
+			batch = &Batch{Domain: domain, Index: batch.Index + 1}
+		}
+	}
+	if len(batch.Results) > 0 {
+		if err := n.deliver(ctx, batch); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// batches splits added then removed results into batches of the configured size.
+func (n *Notifier) batches(domain string, added, removed []sources.Result) []*Batch {
+	var batches []*Batch
+	for chunk := range slices.Chunk(added, n.cfg.batchSize) {
+		batches = append(batches, &Batch{Domain: domain, Results: chunk})
+	}
+	for chunk := range slices.Chunk(removed, n.cfg.batchSize) {
+		batches = append(batches, &Batch{Domain: domain, Removed: chunk})
+	}
+	for i, b := range batches {
+		b.Index = i + 1
+		b.Count = len(batches)
+	}
+	return batches
+}
+
+// deliverAll delivers each batch, continuing past failures.
+func (n *Notifier) deliverAll(ctx context.Context, batches []*Batch) error {
+	var errs []error
+	for _, b := range batches {
+		if err := n.deliver(ctx, b); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deliver renders and posts a batch, retrying failed attempts.
+func (n *Notifier) deliver(ctx context.Context, b *Batch) error {
+	var msg bytes.Buffer
+	if err := n.cfg.template.Execute(&msg, b); err != nil {
+		return fmt.Errorf("notify: template: %w", err)
+	}
+	body, err := n.format.body(b, msg.String())
+	if err != nil {
+		return fmt.Errorf("notify: %w", err)
+	}
+
+	backoff := n.cfg.backoff
+	for attempt := 1; ; attempt++ {
+		retryAfter, err := n.post(ctx, body)
+		if err == nil {
+			return nil
+		} else if attempt >= n.cfg.attempts || !isRetryable(err) || ctx.Err() != nil {
+			return fmt.Errorf("notify: %s: %w", n.format.name(), err)
+		}
+
+		wait := max(backoff, retryAfter)
+		backoff *= 2
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("notify: %s: %w", n.format.name(), ctx.Err())
+		case <-time.After(wait):
+		}
+	}
+}
+
+// statusError reports an unsuccessful response status.
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return "unexpected status " + strconv.Itoa(e.code)
+}
+
+// isRetryable reports whether a failed delivery may succeed on another attempt.
+// Connection errors are retried, as are rate limited and server error responses.
+func isRetryable(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code == http.StatusTooManyRequests || se.code >= http.StatusInternalServerError
+	}
+	return true
+}
+
+// post sends a single delivery attempt, returning the server requested delay before retrying, if any.
+func (n *Notifier) post(ctx context.Context, body []byte) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	n.format.sign(req, body)
+
+	resp, err := n.cfg.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return retryDelay(resp.Header.Get("Retry-After")), &statusError{code: resp.StatusCode}
+	}
+	return 0, nil
+}
+
+// retryDelay returns the delay a Retry-After value asks for, bounded by maxRetryAfter.
+// It returns zero if the value is missing, invalid, or already passed.
+func retryDelay(value string) time.Duration {
+	now := time.Now()
+	at, ok := scout.ParseRetryAfter(value, now)
+	if !ok {
+		return 0
+	}
+	return min(max(at.Sub(now), 0), maxRetryAfter)
+}
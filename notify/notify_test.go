@@ -0,0 +1,212 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-appsec/scout/baseline"
+	"github.com/go-appsec/scout/monitor"
+	"github.com/go-appsec/scout/sources"
+)
+
+// receiver records delivered bodies, responding with queued statuses before succeeding.
+type receiver struct {
+	mu       sync.Mutex
+	bodies   [][]byte
+	headers  []http.Header
+	statuses []int
+	attempts int
+}
+
+func newReceiver(t *testing.T, statuses ...int) (*receiver, *httptest.Server) {
+	t.Helper()
+
+	r := &receiver{statuses: statuses}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.attempts++
+		if len(r.statuses) > 0 {
+			status := r.statuses[0]
+			r.statuses = r.statuses[1:]
+			w.WriteHeader(status)
+			return
+		}
+		r.bodies = append(r.bodies, body)
+		r.headers = append(r.headers, req.Header.Clone())
+	}))
+	t.Cleanup(server.Close)
+	return r, server
+}
+
+func (r *receiver) delivered() [][]byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][]byte(nil), r.bodies...)
+}
+
+// subdomains creates subdomain results from the crtsh source.
+func subdomains(values ...string) []sources.Result {
+	results := make([]sources.Result, len(values))
+	for i, v := range values {
+		results[i] = sources.Result{Type: sources.Subdomain, Value: v, Source: "crtsh"}
+	}
+	return results
+}
+
+func TestNotify(t *testing.T) {
+	t.Parallel()
+
+	t.Run("batches", func(t *testing.T) {
+		r, server := newReceiver(t)
+		n := NewWebhook(server.URL, "", WithBatchSize(2))
+
+		require.NoError(t, n.Notify(t.Context(), "example.com", subdomains("a.example.com", "b.example.com", "c.example.com")))
+
+		bodies := r.delivered()
+		require.Len(t, bodies, 2)
+		var first, second WebhookPayload
+		require.NoError(t, json.Unmarshal(bodies[0], &first))
+		require.NoError(t, json.Unmarshal(bodies[1], &second))
+		assert.Len(t, first.Results, 2)
+		assert.Equal(t, 1, first.Batch)
+		assert.Equal(t, 2, first.Batches)
+		assert.Equal(t, "c.example.com", second.Results[0].Value)
+		assert.Equal(t, "1 new result for example.com (2/2)\nc.example.com (crtsh)\n", second.Message)
+	})
+
+	t.Run("retries_server_errors", func(t *testing.T) {
+		r, server := newReceiver(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+		n := NewSlack(server.URL, WithRetry(3, time.Millisecond))
+
+		require.NoError(t, n.Notify(t.Context(), "example.com", subdomains("a.example.com")))
+
+		assert.Len(t, r.delivered(), 1)
+		assert.Equal(t, 3, r.attempts)
+	})
+
+	t.Run("gives_up_after_attempts", func(t *testing.T) {
+		r, server := newReceiver(t, http.StatusBadGateway, http.StatusBadGateway)
+		n := NewSlack(server.URL, WithRetry(2, time.Millisecond))
+
+		err := n.Notify(t.Context(), "example.com", subdomains("a.example.com"))
+
+		assert.ErrorContains(t, err, "notify: slack: unexpected status 502")
+		assert.Equal(t, 2, r.attempts)
+	})
+
+	t.Run("no_retry_client_error", func(t *testing.T) {
+		r, server := newReceiver(t, http.StatusNotFound)
+		n := NewDiscord(server.URL, WithRetry(3, time.Millisecond))
+
+		assert.Error(t, n.Notify(t.Context(), "example.com", subdomains("a.example.com")))
+		assert.Equal(t, 1, r.attempts)
+	})
+
+	t.Run("template", func(t *testing.T) {
+		r, server := newReceiver(t)
+		tmpl := template.Must(template.New("t").Parse(`{{.Domain}}:{{range .Results}} {{.Value}}{{end}}`))
+		n := NewWebhook(server.URL, "", WithTemplate(tmpl))
+
+		require.NoError(t, n.Notify(t.Context(), "example.com", subdomains("a.example.com", "b.example.com")))
+
+		var payload WebhookPayload
+		require.NoError(t, json.Unmarshal(r.delivered()[0], &payload))
+		assert.Equal(t, "example.com: a.example.com b.example.com", payload.Message)
+	})
+}
+
+func TestRetryDelay(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		value string
+		min   time.Duration
+		max   time.Duration
+	}{
+		{name: "seconds", value: "5", min: 5 * time.Second, max: 5 * time.Second},
+		{name: "seconds_bounded", value: "3600", min: maxRetryAfter, max: maxRetryAfter},
+		{name: "date", value: time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat), min: 28 * time.Second, max: 30 * time.Second},
+		{name: "date_bounded", value: time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), min: maxRetryAfter, max: maxRetryAfter},
+		{name: "date_passed", value: time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)},
+		{name: "negative", value: "-1"},
+		{name: "missing", value: ""},
+		{name: "invalid", value: "soon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delay := retryDelay(tt.value)
+			assert.GreaterOrEqual(t, delay, tt.min)
+			assert.LessOrEqual(t, delay, tt.max)
+		})
+	}
+}
+
+func TestSend(t *testing.T) {
+	t.Parallel()
+
+	var _ monitor.Sink = (*Notifier)(nil)
+
+	r, server := newReceiver(t)
+	n := NewWebhook(server.URL, "", WithBatchSize(10))
+
+	require.NoError(t, n.Send(t.Context(), &baseline.Changes{
+		Domain:  "example.com",
+		Added:   subdomains("new.example.com"),
+		Removed: subdomains("old.example.com", "gone.example.com"),
+	}))
+
+	bodies := r.delivered()
+	require.Len(t, bodies, 2)
+	var added, removed WebhookPayload
+	require.NoError(t, json.Unmarshal(bodies[0], &added))
+	require.NoError(t, json.Unmarshal(bodies[1], &removed))
+	assert.Len(t, added.Results, 1)
+	assert.Empty(t, added.Removed)
+	assert.Empty(t, removed.Results)
+	assert.Len(t, removed.Removed, 2)
+	assert.True(t, strings.HasPrefix(removed.Message, "2 results no longer found for example.com"))
+}
+
+func TestConsume(t *testing.T) {
+	t.Parallel()
+
+	r, server := newReceiver(t)
+	n := NewWebhook(server.URL, "", WithBatchSize(2))
+	srcErr := errors.New("crtsh: unexpected status 503")
+	seq := func(yield func(sources.Result, error) bool) {
+		for i, res := range subdomains("a.example.com", "b.example.com", "c.example.com") {
+			if !yield(res, nil) {
+				return
+			}
+			if i == 0 && !yield(sources.Result{}, srcErr) {
+				return
+			}
+		}
+	}
+	var errs []error
+
+	require.NoError(t, n.Consume(t.Context(), "example.com", seq, func(err error) { errs = append(errs, err) }))
+
+	assert.Equal(t, []error{srcErr}, errs)
+	bodies := r.delivered()
+	require.Len(t, bodies, 2)
+	var second WebhookPayload
+	require.NoError(t, json.Unmarshal(bodies[1], &second))
+	assert.Equal(t, 2, second.Batch)
+	assert.Zero(t, second.Batches)
+	assert.Equal(t, "c.example.com", second.Results[0].Value)
+}
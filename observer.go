@@ -0,0 +1,203 @@
+package scout
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-appsec/scout/sources"
+)
+
+// Observer receives events as a Query progresses, enabling progress reporting, logging, and custom metrics.
+// Methods are called from multiple goroutines and must be safe for concurrent use; they should return quickly.
+// Embed NopObserver to implement only the events of interest.
+type Observer interface {
+	// SourceStart is called when a source begins running against a target.
+	SourceStart(SourceStartEvent)
+	// SourceFinish is called when a source run ends.
+	SourceFinish(SourceFinishEvent)
+	// Request is called before an HTTP request is sent.
+	Request(RequestEvent)
+	// RateLimitWait is called after a request waited on a global or per-source rate limiter.
+	RateLimitWait(RateLimitEvent)
+	// Retry is called when a failed request is about to be retried, see WithRetry.
+	Retry(RetryEvent)
+	// Response is called when HTTP response headers are received, or the request fails.
+	Response(ResponseEvent)
+	// PageFetched is called once a response body has been read and closed.
+	PageFetched(PageEvent)
+	// ResultEmitted is called when a unique result is yielded to the consumer.
+	ResultEmitted(ResultEvent)
+	// DuplicateSuppressed is called when a result is dropped as a duplicate.
+	DuplicateSuppressed(ResultEvent)
+	// Error is called when a source error is yielded to the consumer, or an error checking a result of the source
+	// for duplicates.
+	Error(ErrorEvent)
+}
+
+// SourceStartEvent describes a source starting to run.
+type SourceStartEvent struct {
+	Source string    // Source name
+	Target string    // Domain the source is queried for
+	Time   time.Time // When the source started
+}
+
+// SourceFinishEvent describes a source run ending.
+type SourceFinishEvent struct {
+	Source   string        // Source name
+	Target   string        // Domain the source was queried for
+	Status   SourceStatus  // Outcome of the run
+	Time     time.Time     // When the source finished
+	Duration time.Duration // Time spent running
+}
+
+// RequestEvent describes an HTTP request about to be sent by a source.
+type RequestEvent struct {
+	Source string    // Source name
+	Method string    // HTTP method
+	URL    string    // Request URL, with credentials redacted
+	Time   time.Time // When the request was sent
+}
+
+// RateLimitEvent describes time a request spent waiting on a rate limiter.
+type RateLimitEvent struct {
+	Source string        // Source name
+	Wait   time.Duration // Time spent waiting
+	Time   time.Time     // When the wait ended
+}
+
+// RetryEvent describes a failed HTTP request which is retried after a delay.
+type RetryEvent struct {
+	Source     string        // Source name
+	Method     string        // HTTP method
+	URL        string        // Request URL, with credentials redacted
+	Attempt    int           // Failed attempt, starting at 1
+	StatusCode int           // Response status of the failed attempt, zero if the request failed
+	Err        error         // Transport error of the failed attempt, if any
+	Delay      time.Duration // Time until the next attempt
+	Time       time.Time     // When the attempt failed
+}
+
+// ResponseEvent describes the result of an HTTP request.
+type ResponseEvent struct {
+	Source     string        // Source name
+	Method     string        // HTTP method
+	URL        string        // Request URL
+	StatusCode int           // Response status, zero if the request failed
+	Err        error         // Transport error, if any
+	Time       time.Time     // When the response headers were received
+	Duration   time.Duration // Time since the request was sent
+}
+
+// PageEvent describes a response body which was fully consumed.
+type PageEvent struct {
+	Source     string        // Source name
+	URL        string        // Request URL
+	StatusCode int           // Response status
+	Bytes      int64         // Body bytes read
+	Time       time.Time     // When the body was closed
+	Duration   time.Duration // Time since the request was sent
+}
+
+// ResultEvent describes a result reaching the deduplication stage.
+type ResultEvent struct {
+	Result sources.Result // The result
+	Time   time.Time      // When the result was processed
+}
+
+// ErrorEvent describes a source error.
+type ErrorEvent struct {
+	Source string    // Source name
+	Err    error     // The error
+	Time   time.Time // When the error was processed
+}
+
+// sourceContextKey is the context key holding the running source name, set when an observer is configured.
+type sourceContextKey struct{}
+
+// NopObserver implements Observer with methods which do nothing.
+type NopObserver struct{}
+
+func (NopObserver) SourceStart(SourceStartEvent)    {}
+func (NopObserver) SourceFinish(SourceFinishEvent)  {}
+func (NopObserver) Request(RequestEvent)            {}
+func (NopObserver) RateLimitWait(RateLimitEvent)    {}
+func (NopObserver) Retry(RetryEvent)                {}
+func (NopObserver) Response(ResponseEvent)          {}
+func (NopObserver) PageFetched(PageEvent)           {}
+func (NopObserver) ResultEmitted(ResultEvent)       {}
+func (NopObserver) DuplicateSuppressed(ResultEvent) {}
+func (NopObserver) Error(ErrorEvent)                {}
+
+// observerTransport wraps an http.RoundTripper to report request events for a source.
+type observerTransport struct {
+	base     http.RoundTripper
+	source   string
+	observer Observer
+}
+
+func (t *observerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	url := sources.RedactURL(req.URL.String())
+	t.observer.Request(RequestEvent{Source: t.source, Method: req.Method, URL: url, Time: start})
+
+	resp, err := t.base.RoundTrip(req)
+	now := time.Now()
+	event := ResponseEvent{Source: t.source, Method: req.Method, URL: url, Err: err, Time: now, Duration: now.Sub(start)}
+	if err != nil {
+		t.observer.Response(event)
+		return nil, err
+	}
+	event.StatusCode = resp.StatusCode
+	t.observer.Response(event)
+
+	resp.Body = &observedBody{
+		ReadCloser: resp.Body,
+		transport:  t,
+		url:        url,
+		statusCode: resp.StatusCode,
+		start:      start,
+	}
+	return resp, nil
+}
+
+// observedBody reports a PageFetched event when the response body is closed.
+type observedBody struct {
+	io.ReadCloser
+	transport  *observerTransport
+	url        string
+	statusCode int
+	start      time.Time
+	bytes      int64
+	once       sync.Once
+}
+
+func (b *observedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.bytes += int64(n)
+	return n, err
+}
+
+func (b *observedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		now := time.Now()
+		b.transport.observer.PageFetched(PageEvent{
+			Source:     b.transport.source,
+			URL:        b.url,
+			StatusCode: b.statusCode,
+			Bytes:      b.bytes,
+			Time:       now,
+			Duration:   now.Sub(b.start),
+		})
+	})
+	return err
+}
+
+// observerMiddleware returns middleware reporting request events for the source to the observer.
+func observerMiddleware(source string, observer Observer) Middleware {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return &observerTransport{base: rt, source: source, observer: observer}
+	}
+}
@@ -0,0 +1,223 @@
+package scout
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-appsec/scout/sources"
+)
+
+// recordingObserver records events for assertions.
+type recordingObserver struct {
+	NopObserver
+	mu         sync.Mutex
+	starts     []SourceStartEvent
+	finishes   []SourceFinishEvent
+	requests   []RequestEvent
+	waits      []RateLimitEvent
+	responses  []ResponseEvent
+	pages      []PageEvent
+	emitted    []ResultEvent
+	duplicates []ResultEvent
+	errors     []ErrorEvent
+}
+
+func (o *recordingObserver) SourceStart(e SourceStartEvent) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.starts = append(o.starts, e)
+}
+
+func (o *recordingObserver) SourceFinish(e SourceFinishEvent) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.finishes = append(o.finishes, e)
+}
+
+func (o *recordingObserver) Request(e RequestEvent) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.requests = append(o.requests, e)
+}
+
+func (o *recordingObserver) RateLimitWait(e RateLimitEvent) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.waits = append(o.waits, e)
+}
+
+func (o *recordingObserver) Response(e ResponseEvent) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.responses = append(o.responses, e)
+}
+
+func (o *recordingObserver) PageFetched(e PageEvent) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.pages = append(o.pages, e)
+}
+
+func (o *recordingObserver) ResultEmitted(e ResultEvent) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.emitted = append(o.emitted, e)
+}
+
+func (o *recordingObserver) DuplicateSuppressed(e ResultEvent) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.duplicates = append(o.duplicates, e)
+}
+
+func (o *recordingObserver) Error(e ErrorEvent) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.errors = append(o.errors, e)
+}
+
+func TestNopObserver(t *testing.T) {
+	t.Parallel()
+
+	var _ Observer = NopObserver{}
+}
+
+func TestQueryObserver(t *testing.T) {
+	t.Parallel()
+
+	t.Run("source_and_result_events", func(t *testing.T) {
+		testErr := errors.New("test: unexpected status 500")
+		src := mockSource("test", sources.Subdomain, []sources.Result{
+			{Type: sources.Subdomain, Value: "api.example.com", Source: "test"},
+			{Type: sources.Subdomain, Value: "API.example.com", Source: "test"},
+		}, []error{testErr})
+		observer := &recordingObserver{}
+
+		_, err := Collect(Query(t.Context(), "example.com", WithSources([]sources.Source{src}), WithObserver(observer)))
+		require.Error(t, err)
+
+		require.Len(t, observer.starts, 1)
+		assert.Equal(t, "test", observer.starts[0].Source)
+		assert.Equal(t, "example.com", observer.starts[0].Target)
+		assert.False(t, observer.starts[0].Time.IsZero())
+
+		require.Len(t, observer.finishes, 1)
+		assert.Equal(t, StatusFailed, observer.finishes[0].Status)
+		assert.False(t, observer.finishes[0].Time.Before(observer.starts[0].Time))
+
+		require.Len(t, observer.emitted, 1)
+		assert.Equal(t, "api.example.com", observer.emitted[0].Result.Value)
+		require.Len(t, observer.duplicates, 1)
+		assert.Equal(t, "API.example.com", observer.duplicates[0].Result.Value)
+		require.Len(t, observer.errors, 1)
+		assert.Equal(t, "test", observer.errors[0].Source)
+		assert.ErrorIs(t, observer.errors[0].Err, testErr)
+	})
+
+	t.Run("http_events", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+			_, _ = w.Write([]byte("hello"))
+		}))
+		t.Cleanup(server.Close)
+
+		fetching := sources.Source{
+			Name:   "fetching",
+			Yields: sources.Subdomain,
+			Run: func(ctx context.Context, client *http.Client, _ string, _ string) iter.Seq2[sources.Result, error] {
+				return func(yield func(sources.Result, error) bool) {
+					req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/page", nil)
+					if err != nil {
+						yield(sources.Result{}, err)
+						return
+					}
+					resp, err := client.Do(req)
+					if err != nil {
+						yield(sources.Result{}, err)
+						return
+					}
+					_, _ = io.Copy(io.Discard, resp.Body)
+					_ = resp.Body.Close()
+				}
+			},
+		}
+		observer := &recordingObserver{}
+
+		_, err := Collect(Query(t.Context(), "example.com",
+			WithSources([]sources.Source{fetching}),
+			WithHTTPClient(server.Client()),
+			WithObserver(observer),
+		))
+		require.NoError(t, err)
+
+		require.Len(t, observer.requests, 1)
+		assert.Equal(t, "fetching", observer.requests[0].Source)
+		assert.Equal(t, http.MethodGet, observer.requests[0].Method)
+		assert.Equal(t, server.URL+"/page", observer.requests[0].URL)
+
+		require.Len(t, observer.responses, 1)
+		assert.Equal(t, http.StatusAccepted, observer.responses[0].StatusCode)
+		assert.NoError(t, observer.responses[0].Err)
+
+		require.Len(t, observer.pages, 1)
+		assert.Equal(t, int64(5), observer.pages[0].Bytes)
+		assert.Equal(t, http.StatusAccepted, observer.pages[0].StatusCode)
+		assert.GreaterOrEqual(t, observer.pages[0].Duration, observer.responses[0].Duration)
+		assert.Empty(t, observer.waits)
+	})
+
+	t.Run("rate_limit_wait_events", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+		t.Cleanup(server.Close)
+
+		fetching := sources.Source{
+			Name:   "limited",
+			Yields: sources.Subdomain,
+			Run: func(ctx context.Context, client *http.Client, _ string, _ string) iter.Seq2[sources.Result, error] {
+				return func(yield func(sources.Result, error) bool) {
+					for range 2 {
+						req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+						if err != nil {
+							yield(sources.Result{}, err)
+							return
+						}
+						resp, err := client.Do(req)
+						if err != nil {
+							yield(sources.Result{}, err)
+							return
+						}
+						_ = resp.Body.Close()
+					}
+				}
+			},
+		}
+		observer := &recordingObserver{}
+
+		_, err := Collect(Query(t.Context(), "example.com",
+			WithSources([]sources.Source{fetching}),
+			WithHTTPClient(server.Client()),
+			WithGlobalRateLimit(1000),
+			WithSourceRateLimit("limited", 20),
+			WithObserver(observer),
+		))
+		require.NoError(t, err)
+
+		require.Len(t, observer.waits, 4) // global and per-source limiter for each request
+		var total time.Duration
+		for _, w := range observer.waits {
+			assert.Equal(t, "limited", w.Source)
+			total += w.Wait
+		}
+		assert.GreaterOrEqual(t, total, 40*time.Millisecond) // second request waits 1/20s on the source limiter
+	})
+}
@@ -1,12 +1,16 @@
 package scout
 
 import (
+	"log/slog"
 	"net/http"
+	"net/url"
 	"runtime"
 	"time"
 
 	"golang.org/x/time/rate"
 
+	"github.com/go-appsec/scout/cluster"
+	"github.com/go-appsec/scout/normalize"
 	"github.com/go-appsec/scout/sources"
 )
 
@@ -18,24 +22,130 @@ type Options struct {
 	Sources []sources.Source
 
 	// HTTPClient is the client used for all requests. If nil, a default client with sensible timeouts is used.
+	// Its transport is wrapped by the middleware chain, and redirects are refused unless it sets CheckRedirect.
 	HTTPClient *http.Client
 
+	// Middleware wraps the transport of every source, in order, between rate limiting and logging.
+	// See Middleware for the complete chain.
+	Middleware []Middleware
+
+	// SourceMiddleware wraps a source's transport inside Middleware. Key is a quota group or source name.
+	SourceMiddleware map[string][]Middleware
+
+	// Retry retries failed requests. If nil, requests are not retried.
+	Retry *RetryOptions
+
+	// Cache answers repeated GET requests from responses already received, outside retries and rate limiting
+	// so cached responses neither wait on the limiters nor count against the budget. If nil, nothing is cached.
+	Cache *ResponseCache
+
+	// UserAgents sets the User-Agent header of every request, rotating round robin per request.
+	// If empty, the profile each source prefers applies, see sources.Source.UserAgent.
+	UserAgents []string
+
+	// SourceUserAgents sets the User-Agent header of a source's requests instead of UserAgents, rotating round robin.
+	// Key is a quota group or source name.
+	SourceUserAgents map[string][]string
+
+	// SourceHeaders sets extra headers on a source's requests, replacing values of the same name.
+	// Key is a quota group or source name.
+	SourceHeaders map[string]http.Header
+
+	// Proxies routes requests through the proxies round robin. If nil, the proxy from the environment is used,
+	// an empty slice connects directly. Proxies require HTTPClient, if set, to use an *http.Transport.
+	Proxies []*url.URL
+
+	// SourceProxies routes a source's requests through its own proxies instead of Proxies.
+	// Key is a quota group or source name, an empty slice connects the source directly.
+	SourceProxies map[string][]*url.URL
+
+	// URLNormalization puts URL results into canonical form before deduplication, see normalize.URL.
+	// If nil, URLs are yielded as sources produce them and only exact duplicates are removed.
+	URLNormalization *normalize.URLOptions
+
+	// URLReduction groups near-identical URL results into clusters, yielding only samples of each.
+	// Cluster sizes are reported in the Summary. If nil, every unique URL is yielded.
+	URLReduction *cluster.Options
+
+	// Dedupe creates the Deduplicator filtering duplicate results of each Query. If nil, ExactDedupe is used.
+	// Other strategies bound memory for very large runs, see ShardedDedupe, DiskDedupe, and BloomDedupe,
+	// or NoDedupe disables deduplication. Results are deduplicated separately per type.
+	Dedupe DedupeStrategy
+
+	// Buffer configures the buffer of results between each source and the consumer, so a slow consumer
+	// does not stall sources' network reads. If nil, each result is handed directly to the consumer.
+	// By default 1024 results are buffered per source.
+	Buffer *BufferOptions
+
 	// Parallelism controls how many sources run concurrently. Set to 1 for sequential execution.
+	// A source blocked on the consumer, or whose remaining results are buffered, frees its slot.
 	Parallelism int
 
 	// GlobalRateLimit limits requests/second across all sources. Default is 0 (unlimited).
 	GlobalRateLimit rate.Limit
 
-	// SourceRateLimits sets per-source rate limits. Key is source name, value is requests/second.
+	// SourceRateLimits sets per-source rate limits. Key is a quota group or source name, value is requests/second.
+	// Sources in a group share one limiter, the group's entry takes precedence over a source's own.
+	// Sources without an entry use their recommended rate, see sources.Source.RecommendedRate.
 	SourceRateLimits map[string]rate.Limit
 
+	// SourceBursts sets per-source rate limit bursts, keyed as SourceRateLimits.
+	// Sources without an entry use their recommended burst.
+	SourceBursts map[string]int
+
+	// AdaptiveRateLimit adjusts per-source rate limits from provider feedback. If nil, per-source limits are fixed.
+	AdaptiveRateLimit *AdaptiveRateLimitOptions
+
+	// RateLimiters holds the limiters enforcing the rate limits. If nil, each Query uses its own limiters.
+	RateLimiters *RateLimiters
+
 	// Timeout is the per-source timeout.
 	Timeout time.Duration
 
 	// APIKeys maps source names to their API keys. Optional keys improve rate limits for some sources.
+	// A key set for a quota group is used by sources in the group without a key of their own.
 	APIKeys map[string]string
+
+	// APIKeyPools maps source names or quota groups to pools of API keys, rotated per source run.
+	// A source's pool is used before its key in APIKeys, and either before those of its quota group.
+	// The group's rate limit applies across all keys of a pool.
+	APIKeyPools map[string]*KeyPool
+
+	// MaxRequests is a hard budget on HTTP requests made across all sources in a single Query.
+	// Default is 0 (unlimited), unless recursion is enabled.
+	MaxRequests int
+
+	// Recursion enables recursive enumeration of discovered subdomains. If nil, only the queried domain is used.
+	Recursion *RecursionOptions
+
+	// CircuitBreaker skips sources which keep failing. If nil, every source runs on every query.
+	CircuitBreaker *CircuitBreaker
+
+	// OnSummary is called with the run summary once the query completes, including when iteration stops early.
+	OnSummary func(*Summary)
+
+	// Observer receives progress events as the query runs. If nil, no events are produced.
+	Observer Observer
+
+	// Logger receives debug records from Query and sources. If nil, nothing is logged.
+	Logger *slog.Logger
+}
+
+// RecursionOptions configures which discovered subdomains are fed back into Query as new targets.
+type RecursionOptions struct {
+	// MaxDepth is the maximum number of labels below the queried domain for a subdomain to be used as a target.
+	MaxDepth int
+
+	// MinChildren is the number of unique discovered subdomains beneath a subdomain before it is used as a target.
+	MinChildren int
+
+	// Sources names the sources run against recursive targets. If empty, sources marked Recursive are used.
+	Sources []string
 }
 
+// defaultRecursionMaxRequests is the request budget applied when recursion is enabled without WithMaxRequests.
+const defaultRecursionMaxRequests = 1000
+
 // Option is a functional option for configuring Query.
 type Option func(*Options)
 
@@ -45,7 +155,20 @@ func defaultOptions() *Options {
 		Sources:     sources.All(),
 		Parallelism: runtime.NumCPU() * 2,
 		Timeout:     30 * time.Second,
+
+		URLNormalization: &normalize.URLOptions{},
+		Buffer:           &BufferOptions{Size: defaultBufferSize},
+	}
+}
+
+// ResolveOptions returns the Options a Query with opts runs with, the defaults with each option applied.
+// It lets packages wrapping Query, such as baseline, treat results the way the query does.
+func ResolveOptions(opts ...Option) *Options {
+	cfg := defaultOptions()
+	for _, opt := range opts {
+		opt(cfg)
 	}
+	return cfg
 }
 
 // WithSources sets the sources to query.
@@ -62,6 +185,132 @@ func WithHTTPClient(c *http.Client) Option {
 	}
 }
 
+// WithMiddleware adds middleware wrapping the transport of every source, such as to cache responses.
+// Middleware added first is outermost, see Middleware for where the chain sits among built-in middleware.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(o *Options) {
+		o.Middleware = append(o.Middleware, middleware...)
+	}
+}
+
+// WithSourceMiddleware adds middleware wrapping the transport of a source, or every source in a quota group.
+// It runs inside middleware added with WithMiddleware.
+func WithSourceMiddleware(source string, middleware ...Middleware) Option {
+	return func(o *Options) {
+		if o.SourceMiddleware == nil {
+			o.SourceMiddleware = make(map[string][]Middleware)
+		}
+		o.SourceMiddleware[source] = append(o.SourceMiddleware[source], middleware...)
+	}
+}
+
+// WithRetry retries requests which fail with a transport error, throttling, or a server error.
+// Each attempt waits on the rate limiters and counts against the request budget.
+func WithRetry(opts RetryOptions) Option {
+	return func(o *Options) {
+		o.Retry = &opts
+	}
+}
+
+// WithCache reuses responses held by cache rather than requesting them again.
+// Share a cache across queries so a repeated domain is answered from it.
+func WithCache(cache *ResponseCache) Option {
+	return func(o *Options) {
+		o.Cache = cache
+	}
+}
+
+// WithUserAgent sets the User-Agent header of every request, rotating round robin per request
+// when given more than one. It replaces the user agent each source prefers.
+func WithUserAgent(userAgents ...string) Option {
+	return func(o *Options) {
+		o.UserAgents = append([]string{}, userAgents...)
+	}
+}
+
+// WithSourceUserAgent sets the User-Agent header of a source's requests, or of every source in a quota group,
+// instead of those set by WithUserAgent.
+func WithSourceUserAgent(source string, userAgents ...string) Option {
+	return func(o *Options) {
+		if o.SourceUserAgents == nil {
+			o.SourceUserAgents = make(map[string][]string)
+		}
+		o.SourceUserAgents[source] = append([]string{}, userAgents...)
+	}
+}
+
+// WithSourceHeader adds a header to a source's requests, or to every source in a quota group,
+// such as a token an API expects. Values added for the same name are all sent.
+func WithSourceHeader(source, name, value string) Option {
+	return func(o *Options) {
+		if o.SourceHeaders == nil {
+			o.SourceHeaders = make(map[string]http.Header)
+		}
+		if o.SourceHeaders[source] == nil {
+			o.SourceHeaders[source] = make(http.Header)
+		}
+		o.SourceHeaders[source].Add(name, value)
+	}
+}
+
+// WithProxy routes requests through the proxies, rotating round robin per request.
+// Supported schemes are http, https, socks5, and socks5h, proxy credentials are taken from the URL user info.
+// With no proxies every request connects directly, ignoring the environment.
+func WithProxy(proxies ...*url.URL) Option {
+	return func(o *Options) {
+		o.Proxies = append([]*url.URL{}, proxies...)
+	}
+}
+
+// WithSourceProxy routes the requests of a source, or every source in a quota group, through its own proxies
+// instead of those set by WithProxy. With no proxies the source connects directly.
+func WithSourceProxy(source string, proxies ...*url.URL) Option {
+	return func(o *Options) {
+		if o.SourceProxies == nil {
+			o.SourceProxies = make(map[string][]*url.URL)
+		}
+		o.SourceProxies[source] = append([]*url.URL{}, proxies...)
+	}
+}
+
+// WithURLNormalization sets how URL results are normalized before deduplication, such as stripping
+// tracking parameters with normalize.TrackingParams. URLs are normalized with default options unless set.
+func WithURLNormalization(opts normalize.URLOptions) Option {
+	return func(o *Options) {
+		o.URLNormalization = &opts
+	}
+}
+
+// WithRawURLs yields URL results as sources produce them, removing only exact duplicates.
+func WithRawURLs() Option {
+	return func(o *Options) {
+		o.URLNormalization = nil
+	}
+}
+
+// WithURLReduction groups URL results by host, path template, and query parameter names,
+// yielding the first opts.Samples URLs of each cluster and excluding static assets, see cluster.Reducer.
+func WithURLReduction(opts cluster.Options) Option {
+	return func(o *Options) {
+		o.URLReduction = &opts
+	}
+}
+
+// WithDedupe sets the strategy filtering duplicate results, such as DiskDedupe to bound memory on very large runs.
+func WithDedupe(strategy DedupeStrategy) Option {
+	return func(o *Options) {
+		o.Dedupe = strategy
+	}
+}
+
+// WithBuffer sets the buffering of each source's results, such as to spill them to disk rather than
+// block a source when the consumer falls behind.
+func WithBuffer(opts BufferOptions) Option {
+	return func(o *Options) {
+		o.Buffer = &opts
+	}
+}
+
 // WithParallelism sets the number of concurrent sources.
 func WithParallelism(n int) Option {
 	return func(o *Options) {
@@ -76,13 +325,47 @@ func WithGlobalRateLimit(rps float64) Option {
 	}
 }
 
-// WithSourceRateLimit sets a rate limit for a specific source.
+// WithSourceRateLimit sets a rate limit for a specific source, overriding the source's recommended rate.
+// A rate of zero or less removes the limit. Pass a quota group name to limit every source in the group together.
 func WithSourceRateLimit(source string, rps float64) Option {
 	return func(o *Options) {
 		if o.SourceRateLimits == nil {
 			o.SourceRateLimits = make(map[string]rate.Limit)
 		}
-		o.SourceRateLimits[source] = rate.Limit(rps)
+		limit := rate.Limit(rps)
+		if rps <= 0 {
+			limit = rate.Inf
+		}
+		o.SourceRateLimits[source] = limit
+	}
+}
+
+// WithSourceBurst sets the number of requests a source may make at once before its rate limit spaces them,
+// overriding the source's recommended Burst.
+func WithSourceBurst(source string, burst int) Option {
+	return func(o *Options) {
+		if o.SourceBursts == nil {
+			o.SourceBursts = make(map[string]int)
+		}
+		o.SourceBursts[source] = max(burst, 1)
+	}
+}
+
+// WithAdaptiveRateLimit adjusts per-source rate limits from provider feedback.
+// Rate limit headers and Retry-After are honored, limits halve when a request is throttled,
+// and recover towards the configured rate after sustained success.
+// Sources without a configured or recommended rate are only limited once throttled.
+func WithAdaptiveRateLimit(opts AdaptiveRateLimitOptions) Option {
+	return func(o *Options) {
+		o.AdaptiveRateLimit = &opts
+	}
+}
+
+// WithRateLimiters sets the rate limiters used by the query.
+// Share the same RateLimiters across Query calls so rate limits apply across all of them.
+func WithRateLimiters(l *RateLimiters) Option {
+	return func(o *Options) {
+		o.RateLimiters = l
 	}
 }
 
@@ -93,7 +376,7 @@ func WithTimeout(d time.Duration) Option {
 	}
 }
 
-// WithAPIKey sets an API key for a specific source.
+// WithAPIKey sets an API key for a specific source, or for every source in a quota group.
 func WithAPIKey(source, key string) Option {
 	return func(o *Options) {
 		if o.APIKeys == nil {
@@ -102,3 +385,77 @@ func WithAPIKey(source, key string) Option {
 		o.APIKeys[source] = key
 	}
 }
+
+// WithAPIKeys sets a pool of API keys for a source or quota group, each source run taking the next key.
+// The pool is created once, so queries reusing the option continue its rotation.
+func WithAPIKeys(source string, keys ...string) Option {
+	pool := NewKeyPool(keys...)
+	return func(o *Options) {
+		if o.APIKeyPools == nil {
+			o.APIKeyPools = make(map[string]*KeyPool)
+		}
+		o.APIKeyPools[source] = pool
+	}
+}
+
+// WithMaxRequests sets a hard budget on HTTP requests across all sources.
+// Requests beyond the budget fail with ErrRequestBudgetExceeded.
+func WithMaxRequests(n int) Option {
+	return func(o *Options) {
+		o.MaxRequests = n
+	}
+}
+
+// WithRecursion enables recursive enumeration of discovered subdomains.
+// Subdomains up to maxDepth labels below the domain, with at least minChildren discovered subdomains beneath them,
+// are queried as new targets.
+func WithRecursion(maxDepth, minChildren int) Option {
+	return func(o *Options) {
+		if o.Recursion == nil {
+			o.Recursion = &RecursionOptions{}
+		}
+		o.Recursion.MaxDepth = maxDepth
+		o.Recursion.MinChildren = minChildren
+	}
+}
+
+// WithRecursionSources sets the sources run against recursive targets.
+// Recursion must also be enabled with WithRecursion.
+func WithRecursionSources(names ...string) Option {
+	return func(o *Options) {
+		if o.Recursion == nil {
+			o.Recursion = &RecursionOptions{}
+		}
+		o.Recursion.Sources = names
+	}
+}
+
+// WithCircuitBreaker sets a circuit breaker which skips failing sources.
+// Share the same breaker across Query calls so source health carries over between runs.
+func WithCircuitBreaker(b *CircuitBreaker) Option {
+	return func(o *Options) {
+		o.CircuitBreaker = b
+	}
+}
+
+// WithSummary sets a callback which receives per-source statistics once the query completes.
+func WithSummary(fn func(*Summary)) Option {
+	return func(o *Options) {
+		o.OnSummary = fn
+	}
+}
+
+// WithObserver sets an observer which receives progress events as the query runs.
+func WithObserver(obs Observer) Option {
+	return func(o *Options) {
+		o.Observer = obs
+	}
+}
+
+// WithLogger sets the logger for debug records from Query and sources.
+// Sources receive the logger through their context, see sources.Logger.
+func WithLogger(l *slog.Logger) Option {
+	return func(o *Options) {
+		o.Logger = l
+	}
+}
@@ -3,6 +3,7 @@ package scout
 import (
 	"context"
 	"iter"
+	"log/slog"
 	"net/http"
 	"testing"
 	"time"
@@ -14,6 +15,16 @@ import (
 	"github.com/go-appsec/scout/sources"
 )
 
+func TestResolveOptions(t *testing.T) {
+	t.Parallel()
+
+	opts := ResolveOptions(WithTimeout(time.Minute))
+
+	assert.Equal(t, time.Minute, opts.Timeout)
+	assert.NotNil(t, opts.URLNormalization, "defaults are kept")
+	assert.Nil(t, ResolveOptions(WithRawURLs()).URLNormalization)
+}
+
 func TestWithParallelism(t *testing.T) {
 	t.Parallel()
 
@@ -62,6 +73,22 @@ func TestWithSourceRateLimit(t *testing.T) {
 	t.Run("first_source_unchanged", func(t *testing.T) {
 		assert.InDelta(t, float64(rate.Limit(5)), float64(opts.SourceRateLimits["wayback"]), 0.001)
 	})
+
+	t.Run("zero_removes_limit", func(t *testing.T) {
+		WithSourceRateLimit("rapiddns", 0)(opts)
+
+		assert.Equal(t, rate.Inf, opts.SourceRateLimits["rapiddns"])
+	})
+}
+
+func TestWithSourceBurst(t *testing.T) {
+	t.Parallel()
+
+	opts := defaultOptions()
+	WithSourceBurst("commoncrawl", 4)(opts)
+	WithSourceBurst("crtsh", 0)(opts)
+
+	assert.Equal(t, map[string]int{"commoncrawl": 4, "crtsh": 1}, opts.SourceBursts)
 }
 
 func TestWithHTTPClient(t *testing.T) {
@@ -92,3 +119,86 @@ func TestWithSources(t *testing.T) {
 	require.Len(t, opts.Sources, 1)
 	assert.Equal(t, "custom", opts.Sources[0].Name)
 }
+
+func TestWithMaxRequests(t *testing.T) {
+	t.Parallel()
+
+	opts := defaultOptions()
+	WithMaxRequests(100)(opts)
+
+	assert.Equal(t, 100, opts.MaxRequests)
+}
+
+func TestWithRecursion(t *testing.T) {
+	t.Parallel()
+
+	t.Run("sets_limits", func(t *testing.T) {
+		opts := defaultOptions()
+		WithRecursion(2, 3)(opts)
+
+		require.NotNil(t, opts.Recursion)
+		assert.Equal(t, 2, opts.Recursion.MaxDepth)
+		assert.Equal(t, 3, opts.Recursion.MinChildren)
+	})
+
+	t.Run("keeps_sources", func(t *testing.T) {
+		opts := defaultOptions()
+		WithRecursionSources("crtsh", "anubis")(opts)
+		WithRecursion(1, 0)(opts)
+
+		require.NotNil(t, opts.Recursion)
+		assert.Equal(t, []string{"crtsh", "anubis"}, opts.Recursion.Sources)
+		assert.Equal(t, 1, opts.Recursion.MaxDepth)
+	})
+}
+
+func TestWithCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	opts := defaultOptions()
+	breaker := NewCircuitBreaker(CircuitBreakerOptions{})
+	WithCircuitBreaker(breaker)(opts)
+
+	assert.Same(t, breaker, opts.CircuitBreaker)
+}
+
+func TestWithSummary(t *testing.T) {
+	t.Parallel()
+
+	opts := defaultOptions()
+	var called bool
+	WithSummary(func(*Summary) { called = true })(opts)
+
+	require.NotNil(t, opts.OnSummary)
+	opts.OnSummary(&Summary{})
+	assert.True(t, called)
+}
+
+func TestWithObserver(t *testing.T) {
+	t.Parallel()
+
+	opts := defaultOptions()
+	WithObserver(NopObserver{})(opts)
+
+	assert.Equal(t, NopObserver{}, opts.Observer)
+}
+
+func TestWithLogger(t *testing.T) {
+	t.Parallel()
+
+	opts := defaultOptions()
+	logger := slog.New(slog.DiscardHandler)
+	WithLogger(logger)(opts)
+
+	assert.Same(t, logger, opts.Logger)
+}
+
+func TestWithRateLimiters(t *testing.T) {
+	t.Parallel()
+
+	opts := defaultOptions()
+	limiters := NewRateLimiters()
+	WithRateLimiters(limiters)(opts)
+
+	assert.Same(t, limiters, opts.RateLimiters)
+}
@@ -0,0 +1,297 @@
+// Package otelscout instruments scout queries with OpenTelemetry traces and metrics.
+//
+// It is a separate module so the core scout package stays dependency-light.
+// Each query creates a span, with a child span per source run and client spans for the HTTP requests made by the source.
+// Metrics for results, errors, requests, retries, and rate limiter waits are labelled by source.
+package otelscout
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"strconv"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/go-appsec/scout"
+	"github.com/go-appsec/scout/sources"
+)
+
+const instrumentationName = "github.com/go-appsec/scout/otelscout"
+
+// Attribute keys used on spans and metrics.
+const (
+	DomainKey = attribute.Key("scout.domain")
+	SourceKey = attribute.Key("scout.source")
+	TargetKey = attribute.Key("scout.target")
+	StatusKey = attribute.Key("scout.status")
+	TypeKey   = attribute.Key("scout.result.type")
+)
+
+// config holds the providers used to create instruments.
+type config struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+}
+
+// Option configures Instrumentation.
+type Option func(*config)
+
+// WithTracerProvider sets the tracer provider. The global provider is used by default.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = tp
+	}
+}
+
+// WithMeterProvider sets the meter provider. The global provider is used by default.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) {
+		c.meterProvider = mp
+	}
+}
+
+// Instrumentation creates spans and records metrics for scout queries.
+// A single Instrumentation is safe for concurrent use and should be shared across queries.
+type Instrumentation struct {
+	tracer          trace.Tracer
+	results         metric.Int64Counter
+	duplicates      metric.Int64Counter
+	errors          metric.Int64Counter
+	requests        metric.Int64Counter
+	retries         metric.Int64Counter
+	requestDuration metric.Float64Histogram
+	sourceDuration  metric.Float64Histogram
+	rateLimitWait   metric.Float64Histogram
+}
+
+// New creates Instrumentation from the configured providers.
+func New(opts ...Option) (*Instrumentation, error) {
+	cfg := &config{
+		tracerProvider: otel.GetTracerProvider(),
+		meterProvider:  otel.GetMeterProvider(),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	meter := cfg.meterProvider.Meter(instrumentationName, metric.WithInstrumentationVersion(scout.Version))
+	i := &Instrumentation{
+		tracer: cfg.tracerProvider.Tracer(instrumentationName, trace.WithInstrumentationVersion(scout.Version)),
+	}
+	var err error
+	if i.results, err = meter.Int64Counter("scout.results",
+		metric.WithDescription("Unique results yielded"), metric.WithUnit("{result}")); err != nil {
+		return nil, err
+	} else if i.duplicates, err = meter.Int64Counter("scout.duplicates",
+		metric.WithDescription("Results dropped as duplicates"), metric.WithUnit("{result}")); err != nil {
+		return nil, err
+	} else if i.errors, err = meter.Int64Counter("scout.errors",
+		metric.WithDescription("Errors reported by sources"), metric.WithUnit("{error}")); err != nil {
+		return nil, err
+	} else if i.requests, err = meter.Int64Counter("scout.http.requests",
+		metric.WithDescription("HTTP requests made by sources"), metric.WithUnit("{request}")); err != nil {
+		return nil, err
+	} else if i.retries, err = meter.Int64Counter("scout.http.retries",
+		metric.WithDescription("Failed HTTP requests retried"), metric.WithUnit("{request}")); err != nil {
+		return nil, err
+	} else if i.requestDuration, err = meter.Float64Histogram("scout.http.duration",
+		metric.WithDescription("Time until HTTP response headers are received"), metric.WithUnit("s")); err != nil {
+		return nil, err
+	} else if i.sourceDuration, err = meter.Float64Histogram("scout.source.duration",
+		metric.WithDescription("Time spent running a source"), metric.WithUnit("s")); err != nil {
+		return nil, err
+	} else if i.rateLimitWait, err = meter.Float64Histogram("scout.ratelimit.wait",
+		metric.WithDescription("Time requests spent waiting on rate limiters"), metric.WithUnit("s")); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// Query runs scout.Query within a span, instrumenting the selected sources and recording metrics.
+// Any observer set through opts continues to receive events.
+func (i *Instrumentation) Query(ctx context.Context, domain string, opts ...scout.Option) iter.Seq2[sources.Result, error] {
+	// Resolve the sources and observer the options select, matching the scout defaults
+	resolved := scout.Options{Sources: sources.All()}
+	for _, opt := range opts {
+		opt(&resolved)
+	}
+	opts = append(opts, scout.WithSources(i.Sources(resolved.Sources)), scout.WithObserver(i.Observer(resolved.Observer)))
+
+	return func(yield func(sources.Result, error) bool) {
+		ctx, span := i.tracer.Start(ctx, "scout.Query",
+			trace.WithAttributes(DomainKey.String(domain)))
+		defer span.End()
+
+		var results, errs int
+		defer func() {
+			span.SetAttributes(attribute.Int("scout.results", results), attribute.Int("scout.errors", errs))
+		}()
+		for result, err := range scout.Query(ctx, domain, opts...) {
+			if err != nil {
+				errs++
+			} else {
+				results++
+			}
+			if !yield(result, err) {
+				return
+			}
+		}
+	}
+}
+
+// Sources wraps sources so each run creates a span, and the HTTP requests it makes create client spans.
+func (i *Instrumentation) Sources(srcs []sources.Source) []sources.Source {
+	wrapped := make([]sources.Source, len(srcs))
+	for idx, s := range srcs {
+		wrapped[idx] = i.wrapSource(s)
+	}
+	return wrapped
+}
+
+func (i *Instrumentation) wrapSource(s sources.Source) sources.Source {
+	run := s.Run
+	s.Run = func(ctx context.Context, client *http.Client, domain string, apiKey string) iter.Seq2[sources.Result, error] {
+		return func(yield func(sources.Result, error) bool) {
+			ctx, span := i.tracer.Start(ctx, "scout.Source",
+				trace.WithAttributes(SourceKey.String(s.Name), TargetKey.String(domain)))
+			defer span.End()
+
+			var results int
+			for result, err := range run(ctx, withClientSpans(client, i.tracer, s.Name), domain, apiKey) {
+				if err != nil {
+					span.RecordError(err)
+					span.SetStatus(codes.Error, err.Error())
+				} else {
+					results++
+				}
+				if !yield(result, err) {
+					break
+				}
+			}
+			span.SetAttributes(attribute.Int("scout.results", results))
+			if ctx.Err() != nil {
+				span.SetStatus(codes.Error, ctx.Err().Error())
+			}
+		}
+	}
+	return s
+}
+
+// clientSpanTransport wraps an http.RoundTripper to create a client span for each request.
+// Trace context is not propagated, requests go to third-party services.
+type clientSpanTransport struct {
+	base   http.RoundTripper
+	tracer trace.Tracer
+	source string
+}
+
+func (t *clientSpanTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The query string is omitted from attributes as some sources pass API keys there
+	ctx, span := t.tracer.Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			SourceKey.String(t.source),
+			attribute.String("http.request.method", req.Method),
+			attribute.String("server.address", req.URL.Hostname()),
+			attribute.String("url.path", req.URL.Path),
+		))
+	defer span.End()
+
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, strconv.Itoa(resp.StatusCode))
+	}
+	return resp, nil
+}
+
+// withClientSpans returns a new client that creates client spans for all requests.
+func withClientSpans(client *http.Client, tracer trace.Tracer, source string) *http.Client {
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &http.Client{
+		Transport:     &clientSpanTransport{base: transport, tracer: tracer, source: source},
+		CheckRedirect: client.CheckRedirect,
+		Jar:           client.Jar,
+		Timeout:       client.Timeout,
+	}
+}
+
+// Observer returns a scout.Observer which records metrics, forwarding events to next if non-nil.
+func (i *Instrumentation) Observer(next scout.Observer) scout.Observer {
+	if next == nil {
+		next = scout.NopObserver{}
+	}
+	return &metricsObserver{i: i, next: next}
+}
+
+// metricsObserver records query events as metrics.
+type metricsObserver struct {
+	i    *Instrumentation
+	next scout.Observer
+}
+
+func (o *metricsObserver) SourceStart(e scout.SourceStartEvent) {
+	o.next.SourceStart(e)
+}
+
+func (o *metricsObserver) SourceFinish(e scout.SourceFinishEvent) {
+	o.i.sourceDuration.Record(context.Background(), e.Duration.Seconds(),
+		metric.WithAttributes(SourceKey.String(e.Source), StatusKey.String(e.Status.String())))
+	o.next.SourceFinish(e)
+}
+
+func (o *metricsObserver) Request(e scout.RequestEvent) {
+	o.next.Request(e)
+}
+
+func (o *metricsObserver) RateLimitWait(e scout.RateLimitEvent) {
+	o.i.rateLimitWait.Record(context.Background(), e.Wait.Seconds(), metric.WithAttributes(SourceKey.String(e.Source)))
+	o.next.RateLimitWait(e)
+}
+
+func (o *metricsObserver) Retry(e scout.RetryEvent) {
+	o.i.retries.Add(context.Background(), 1,
+		metric.WithAttributes(SourceKey.String(e.Source), attribute.Int("http.response.status_code", e.StatusCode)))
+	o.next.Retry(e)
+}
+
+func (o *metricsObserver) Response(e scout.ResponseEvent) {
+	attrs := metric.WithAttributes(SourceKey.String(e.Source), attribute.Int("http.response.status_code", e.StatusCode))
+	o.i.requests.Add(context.Background(), 1, attrs)
+	o.i.requestDuration.Record(context.Background(), e.Duration.Seconds(), attrs)
+	o.next.Response(e)
+}
+
+func (o *metricsObserver) PageFetched(e scout.PageEvent) {
+	o.next.PageFetched(e)
+}
+
+func (o *metricsObserver) ResultEmitted(e scout.ResultEvent) {
+	o.i.results.Add(context.Background(), 1,
+		metric.WithAttributes(SourceKey.String(e.Result.Source), TypeKey.String(e.Result.Type.String())))
+	o.next.ResultEmitted(e)
+}
+
+func (o *metricsObserver) DuplicateSuppressed(e scout.ResultEvent) {
+	o.i.duplicates.Add(context.Background(), 1,
+		metric.WithAttributes(SourceKey.String(e.Result.Source), TypeKey.String(e.Result.Type.String())))
+	o.next.DuplicateSuppressed(e)
+}
+
+func (o *metricsObserver) Error(e scout.ErrorEvent) {
+	o.i.errors.Add(context.Background(), 1, metric.WithAttributes(SourceKey.String(e.Source)))
+	o.next.Error(e)
+}
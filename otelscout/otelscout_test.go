@@ -0,0 +1,287 @@
+package otelscout
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/go-appsec/scout"
+	"github.com/go-appsec/scout/sources"
+)
+
+// newTestInstrumentation creates Instrumentation recording to in-memory exporters.
+func newTestInstrumentation(t *testing.T) (*Instrumentation, *tracetest.InMemoryExporter, *sdkmetric.ManualReader) {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	t.Cleanup(func() {
+		_ = tp.Shutdown(context.Background())
+		_ = mp.Shutdown(context.Background())
+	})
+
+	i, err := New(WithTracerProvider(tp), WithMeterProvider(mp))
+	require.NoError(t, err)
+	return i, exporter, reader
+}
+
+// fetchingSource creates a test source which requests the URL then yields the given values.
+func fetchingSource(name, url string, values []string, srcErr error) sources.Source {
+	return sources.Source{
+		Name:   name,
+		Yields: sources.Subdomain,
+		Run: func(ctx context.Context, client *http.Client, _ string, _ string) iter.Seq2[sources.Result, error] {
+			return func(yield func(sources.Result, error) bool) {
+				req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+				if err != nil {
+					yield(sources.Result{}, err)
+					return
+				}
+				resp, err := client.Do(req)
+				if err != nil {
+					yield(sources.Result{}, err)
+					return
+				}
+				_, _ = io.Copy(io.Discard, resp.Body)
+				_ = resp.Body.Close()
+
+				for _, v := range values {
+					if !yield(sources.Result{Type: sources.Subdomain, Value: v, Source: name}, nil) {
+						return
+					}
+				}
+				if srcErr != nil {
+					yield(sources.Result{}, srcErr)
+				}
+			}
+		},
+	}
+}
+
+// spanAttr returns the value of a span attribute.
+func spanAttr(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+// spansByName groups exported spans by name.
+func spansByName(spans tracetest.SpanStubs) map[string][]tracetest.SpanStub {
+	result := make(map[string][]tracetest.SpanStub)
+	for _, s := range spans {
+		result[s.Name] = append(result[s.Name], s)
+	}
+	return result
+}
+
+func TestQuerySpans(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+
+	i, exporter, _ := newTestInstrumentation(t)
+	srcErr := errors.New("bad: unexpected status 503")
+	good := fetchingSource("good", server.URL+"/ok?apikey=secret", []string{"a.example.com", "b.example.com"}, nil)
+	bad := fetchingSource("bad", server.URL+"/fail", nil, srcErr)
+
+	results, err := scout.Collect(i.Query(t.Context(), "example.com",
+		scout.WithSources([]sources.Source{good, bad}),
+		scout.WithHTTPClient(server.Client()),
+	))
+	require.ErrorIs(t, err, srcErr)
+	assert.Len(t, results, 2)
+
+	spans := spansByName(exporter.GetSpans())
+	require.Len(t, spans["scout.Query"], 1)
+	require.Len(t, spans["scout.Source"], 2)
+	require.Len(t, spans["HTTP GET"], 2)
+
+	query := spans["scout.Query"][0]
+	assert.Equal(t, "example.com", spanAttr(query, DomainKey).AsString())
+	assert.Equal(t, int64(2), spanAttr(query, "scout.results").AsInt64())
+	assert.Equal(t, int64(1), spanAttr(query, "scout.errors").AsInt64())
+
+	sourceSpans := make(map[string]tracetest.SpanStub)
+	for _, s := range spans["scout.Source"] {
+		assert.Equal(t, query.SpanContext.SpanID(), s.Parent.SpanID())
+		assert.Equal(t, "example.com", spanAttr(s, TargetKey).AsString())
+		sourceSpans[spanAttr(s, SourceKey).AsString()] = s
+	}
+	assert.Equal(t, codes.Unset, sourceSpans["good"].Status.Code)
+	assert.Equal(t, int64(2), spanAttr(sourceSpans["good"], "scout.results").AsInt64())
+	assert.Equal(t, codes.Error, sourceSpans["bad"].Status.Code)
+
+	for _, s := range spans["HTTP GET"] {
+		name := spanAttr(s, SourceKey).AsString()
+		assert.Equal(t, trace.SpanKindClient, s.SpanKind)
+		assert.Equal(t, sourceSpans[name].SpanContext.SpanID(), s.Parent.SpanID())
+		for _, kv := range s.Attributes {
+			assert.NotContains(t, kv.Value.Emit(), "secret")
+		}
+		if name == "bad" {
+			assert.Equal(t, int64(http.StatusServiceUnavailable), spanAttr(s, "http.response.status_code").AsInt64())
+			assert.Equal(t, codes.Error, s.Status.Code)
+		} else {
+			assert.Equal(t, "/ok", spanAttr(s, "url.path").AsString())
+		}
+	}
+}
+
+// sumByAttr sums an int64 counter by the value of an attribute.
+func sumByAttr(t *testing.T, rm metricdata.ResourceMetrics, name string, key attribute.Key) map[string]int64 {
+	t.Helper()
+
+	result := make(map[string]int64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			require.True(t, ok)
+			for _, dp := range sum.DataPoints {
+				v, _ := dp.Attributes.Value(key)
+				result[v.Emit()] += dp.Value
+			}
+		}
+	}
+	return result
+}
+
+// histogramCount returns the number of recorded values in a histogram.
+func histogramCount(t *testing.T, rm metricdata.ResourceMetrics, name string) uint64 {
+	t.Helper()
+
+	var count uint64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			hist, ok := m.Data.(metricdata.Histogram[float64])
+			require.True(t, ok)
+			for _, dp := range hist.DataPoints {
+				count += dp.Count
+			}
+		}
+	}
+	return count
+}
+
+func TestQueryMetrics(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+
+	i, _, reader := newTestInstrumentation(t)
+	srcErr := errors.New("two: parse failure")
+	one := fetchingSource("one", server.URL, []string{"a.example.com", "b.example.com"}, nil)
+	two := fetchingSource("two", server.URL, []string{"a.example.com"}, srcErr)
+
+	_, err := scout.Collect(i.Query(t.Context(), "example.com",
+		scout.WithSources([]sources.Source{one, two}),
+		scout.WithHTTPClient(server.Client()),
+		scout.WithParallelism(1),
+		scout.WithSourceRateLimit("one", 1000),
+		scout.WithTimeout(5*time.Second),
+	))
+	require.ErrorIs(t, err, srcErr)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(t.Context(), &rm))
+
+	results := sumByAttr(t, rm, "scout.results", SourceKey)
+	duplicates := sumByAttr(t, rm, "scout.duplicates", SourceKey)
+	assert.Equal(t, int64(3), results["one"]+results["two"]+duplicates["one"]+duplicates["two"])
+	assert.Equal(t, int64(1), duplicates["one"]+duplicates["two"])
+	assert.Equal(t, map[string]int64{"two": 1}, sumByAttr(t, rm, "scout.errors", SourceKey))
+	assert.Equal(t, map[string]int64{"one": 1, "two": 1}, sumByAttr(t, rm, "scout.http.requests", SourceKey))
+	assert.Equal(t, uint64(2), histogramCount(t, rm, "scout.http.duration"))
+	assert.Equal(t, uint64(2), histogramCount(t, rm, "scout.source.duration"))
+	assert.Equal(t, uint64(1), histogramCount(t, rm, "scout.ratelimit.wait"))
+}
+
+func TestQueryRetryMetrics(t *testing.T) {
+	t.Parallel()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	i, _, reader := newTestInstrumentation(t)
+	_, err := scout.Collect(i.Query(t.Context(), "example.com",
+		scout.WithSources([]sources.Source{fetchingSource("one", server.URL, nil, nil)}),
+		scout.WithHTTPClient(server.Client()),
+		scout.WithRetry(scout.RetryOptions{MinBackoff: time.Millisecond}),
+	))
+	require.NoError(t, err)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(t.Context(), &rm))
+	assert.Equal(t, map[string]int64{"one": 1}, sumByAttr(t, rm, "scout.http.retries", SourceKey))
+	assert.Equal(t, map[string]int64{"one": 2}, sumByAttr(t, rm, "scout.http.requests", SourceKey))
+}
+
+// countingObserver counts emitted results.
+type countingObserver struct {
+	scout.NopObserver
+	emitted int
+}
+
+func (o *countingObserver) ResultEmitted(scout.ResultEvent) {
+	o.emitted++
+}
+
+func TestQueryForwardsObserver(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	t.Cleanup(server.Close)
+
+	i, _, _ := newTestInstrumentation(t)
+	observer := &countingObserver{}
+
+	_, err := scout.Collect(i.Query(t.Context(), "example.com",
+		scout.WithSources([]sources.Source{fetchingSource("one", server.URL, []string{"a.example.com"}, nil)}),
+		scout.WithHTTPClient(server.Client()),
+		scout.WithObserver(observer),
+	))
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, observer.emitted)
+}
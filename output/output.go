@@ -0,0 +1,225 @@
+// Package output provides streaming encoders for scout results.
+//
+// Encoders consume the results of a query, writing each result as it arrives.
+// Errors are never written to the encoded stream, they are passed separately so they cannot corrupt the output.
+package output
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"strings"
+
+	"github.com/go-appsec/scout/sources"
+)
+
+// Format names an output encoding.
+type Format string
+
+const (
+	Text   Format = "text"   // Result value only, one per line
+	JSONL  Format = "jsonl"  // One JSON Record per line
+	CSV    Format = "csv"    // Header row, then one Record per row
+	Report Format = "report" // Single JSON report grouping values by type and source, written on Close
+)
+
+// Formats returns the supported output formats.
+func Formats() []Format {
+	return []Format{Text, JSONL, CSV, Report}
+}
+
+// Encoder writes results to an output stream.
+type Encoder interface {
+	// Encode writes a single result.
+	Encode(result sources.Result) error
+	// Close writes any buffered output. The underlying writer is not closed.
+	Close() error
+}
+
+// New returns an encoder for the format writing to w.
+func New(format Format, w io.Writer) (Encoder, error) {
+	switch format {
+	case Text:
+		return NewText(w), nil
+	case JSONL:
+		return NewJSONL(w), nil
+	case CSV:
+		return NewCSV(w), nil
+	case Report:
+		return NewReport(w), nil
+	default:
+		return nil, fmt.Errorf("output: unknown format %q", format)
+	}
+}
+
+// Write encodes each result from seq, then closes the encoder.
+// Errors from seq are passed to onErr, which may be nil to discard them.
+// Iteration stops at the first encoding error, which is returned.
+func Write(enc Encoder, seq iter.Seq2[sources.Result, error], onErr func(error)) error {
+	var encErr error
+	for result, err := range seq {
+		if err != nil {
+			if onErr != nil {
+				onErr(err)
+			}
+			continue
+		}
+		if encErr = enc.Encode(result); encErr != nil {
+			break
+		}
+	}
+	return errors.Join(encErr, enc.Close())
+}
+
+// ErrorLines returns an error handler for Write which writes each error as a line to w.
+func ErrorLines(w io.Writer) func(error) {
+	return func(err error) {
+		_, _ = fmt.Fprintln(w, "error:", err)
+	}
+}
+
+// Record is the encoded form of a result in the JSONL and CSV formats.
+type Record struct {
+	Type    string   `json:"type"`
+	Value   string   `json:"value"`
+	Source  string   `json:"source"`
+	Parents []string `json:"parents,omitempty"`
+}
+
+// NewRecord returns the record for a result.
+func NewRecord(r sources.Result) Record {
+	return Record{Type: r.Type.String(), Value: r.Value, Source: r.Source, Parents: r.Parents}
+}
+
+// Result returns the result the record encodes.
+func (r Record) Result() (sources.Result, error) {
+	t, err := sources.ParseResultType(r.Type)
+	if err != nil {
+		return sources.Result{}, fmt.Errorf("output: %w", err)
+	}
+	return sources.Result{Type: t, Value: r.Value, Source: r.Source, Parents: r.Parents}, nil
+}
+
+// maxLineSize bounds a single JSON line read by ReadJSONL.
+const maxLineSize = 1 << 20
+
+// ReadJSONL decodes results from JSON Lines written by the JSONL encoder.
+// Blank lines are skipped, a malformed line yields an error and reading continues with the next line.
+func ReadJSONL(r io.Reader) iter.Seq2[sources.Result, error] {
+	return func(yield func(sources.Result, error) bool) {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+		var line int
+		for scanner.Scan() {
+			line++
+			data := bytes.TrimSpace(scanner.Bytes())
+			if len(data) == 0 {
+				continue
+			}
+			var record Record
+			if err := json.Unmarshal(data, &record); err != nil {
+				if !yield(sources.Result{}, fmt.Errorf("output: line %d: %w", line, err)) {
+					return
+				}
+				continue
+			}
+			result, err := record.Result()
+			if err != nil {
+				err = fmt.Errorf("output: line %d: %w", line, errors.Unwrap(err))
+			}
+			if !yield(result, err) {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			yield(sources.Result{}, fmt.Errorf("output: %w", err))
+		}
+	}
+}
+
+// textEncoder writes result values one per line.
+type textEncoder struct {
+	w io.Writer
+}
+
+// NewText returns an encoder writing only the result value, one per line.
+func NewText(w io.Writer) Encoder {
+	return &textEncoder{w: w}
+}
+
+func (e *textEncoder) Encode(r sources.Result) error {
+	_, err := io.WriteString(e.w, r.Value+"\n")
+	return err
+}
+
+func (e *textEncoder) Close() error {
+	return nil
+}
+
+// jsonlEncoder writes a JSON record per line.
+type jsonlEncoder struct {
+	enc *json.Encoder
+}
+
+// NewJSONL returns an encoder writing each result as a JSON Record on its own line.
+func NewJSONL(w io.Writer) Encoder {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &jsonlEncoder{enc: enc}
+}
+
+func (e *jsonlEncoder) Encode(r sources.Result) error {
+	return e.enc.Encode(NewRecord(r))
+}
+
+func (e *jsonlEncoder) Close() error {
+	return nil
+}
+
+// csvHeader is the first row written by the CSV encoder.
+var csvHeader = []string{"type", "value", "source", "parents"}
+
+// csvParentSep joins the parent chain in the CSV parents column.
+const csvParentSep = ">"
+
+// csvEncoder writes a CSV row per result, flushing each row so output streams.
+type csvEncoder struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+// NewCSV returns an encoder writing a header row followed by a row per result.
+// The parents column holds the parent chain joined by ">".
+func NewCSV(w io.Writer) Encoder {
+	return &csvEncoder{w: csv.NewWriter(w)}
+}
+
+func (e *csvEncoder) Encode(r sources.Result) error {
+	if !e.wroteHeader {
+		if err := e.w.Write(csvHeader); err != nil {
+			return err
+		}
+		e.wroteHeader = true
+	}
+	if err := e.w.Write([]string{r.Type.String(), r.Value, r.Source, strings.Join(r.Parents, csvParentSep)}); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder) Close() error {
+	if !e.wroteHeader {
+		if err := e.w.Write(csvHeader); err != nil {
+			return err
+		}
+		e.wroteHeader = true
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
@@ -0,0 +1,195 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"iter"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-appsec/scout"
+	"github.com/go-appsec/scout/sources"
+)
+
+// testResults are yielded by resultSeq, interleaved with an error.
+var testResults = []sources.Result{
+	{Type: sources.Subdomain, Value: "api.example.com", Source: "crtsh"},
+	{Type: sources.URL, Value: "https://example.com/a?b=<c>", Source: "wayback"},
+	{Type: sources.Subdomain, Value: "dev.corp.example.com", Source: "anubis", Parents: []string{"corp.example.com"}},
+}
+
+// resultSeq yields the results with an error after the first result.
+func resultSeq(results []sources.Result, err error) iter.Seq2[sources.Result, error] {
+	return func(yield func(sources.Result, error) bool) {
+		for i, r := range results {
+			if !yield(r, nil) {
+				return
+			}
+			if i == 0 && err != nil && !yield(sources.Result{}, err) {
+				return
+			}
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range Formats() {
+		t.Run(string(format), func(t *testing.T) {
+			enc, err := New(format, &bytes.Buffer{})
+			require.NoError(t, err)
+			assert.NotNil(t, enc)
+		})
+	}
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := New("xml", &bytes.Buffer{})
+		assert.Error(t, err)
+	})
+}
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	t.Run("errors_separate", func(t *testing.T) {
+		var out, errOut bytes.Buffer
+		srcErr := errors.New("crtsh: unexpected status 503")
+
+		err := Write(NewText(&out), resultSeq(testResults, srcErr), ErrorLines(&errOut))
+		require.NoError(t, err)
+
+		assert.Equal(t, "api.example.com\nhttps://example.com/a?b=<c>\ndev.corp.example.com\n", out.String())
+		assert.Equal(t, "error: crtsh: unexpected status 503\n", errOut.String())
+	})
+
+	t.Run("nil_error_handler", func(t *testing.T) {
+		var out bytes.Buffer
+
+		err := Write(NewText(&out), resultSeq(testResults, errors.New("dropped")), nil)
+		require.NoError(t, err)
+
+		assert.Equal(t, 3, strings.Count(out.String(), "\n"))
+	})
+
+	t.Run("stops_on_encode_error", func(t *testing.T) {
+		var yielded int
+		seq := func(yield func(sources.Result, error) bool) {
+			for _, r := range testResults {
+				yielded++
+				if !yield(r, nil) {
+					return
+				}
+			}
+		}
+
+		err := Write(NewText(failingWriter{}), seq, nil)
+		require.Error(t, err)
+
+		assert.Equal(t, 1, yielded)
+	})
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestJSONL(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	require.NoError(t, Write(NewJSONL(&out), resultSeq(testResults, nil), nil))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.JSONEq(t, `{"type":"subdomain","value":"api.example.com","source":"crtsh"}`, lines[0])
+	assert.Contains(t, lines[1], `"https://example.com/a?b=<c>"`)
+	assert.JSONEq(t, `{"type":"subdomain","value":"dev.corp.example.com","source":"anubis","parents":["corp.example.com"]}`, lines[2])
+
+	for i, line := range lines {
+		var record Record
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		result, err := record.Result()
+		require.NoError(t, err)
+		assert.Equal(t, testResults[i], result)
+	}
+}
+
+func TestReadJSONL(t *testing.T) {
+	t.Parallel()
+
+	t.Run("round_trip", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, Write(NewJSONL(&out), resultSeq(testResults, nil), nil))
+
+		results, err := scout.Collect(ReadJSONL(&out))
+		require.NoError(t, err)
+		assert.Equal(t, testResults, results)
+	})
+
+	t.Run("skips_blank_and_reports_malformed", func(t *testing.T) {
+		input := `{"type":"subdomain","value":"api.example.com","source":"crtsh"}` + "\n\n" +
+			"not json\n" +
+			`{"type":"ip","value":"10.0.0.1","source":"shodan"}` + "\n" +
+			`{"type":"url","value":"https://example.com/","source":"wayback"}` + "\n"
+
+		results, err := scout.Collect(ReadJSONL(strings.NewReader(input)))
+
+		require.Len(t, results, 2)
+		assert.Equal(t, "api.example.com", results[0].Value)
+		assert.Equal(t, "https://example.com/", results[1].Value)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "line 3")
+		assert.Contains(t, err.Error(), `line 4: unknown result type "ip"`)
+	})
+}
+
+func TestCSV(t *testing.T) {
+	t.Parallel()
+
+	t.Run("rows", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, Write(NewCSV(&out), resultSeq(testResults, nil), nil))
+
+		rows, err := csv.NewReader(&out).ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{
+			{"type", "value", "source", "parents"},
+			{"subdomain", "api.example.com", "crtsh", ""},
+			{"url", "https://example.com/a?b=<c>", "wayback", ""},
+			{"subdomain", "dev.corp.example.com", "anubis", "corp.example.com"},
+		}, rows)
+	})
+
+	t.Run("header_without_results", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, Write(NewCSV(&out), resultSeq(nil, nil), nil))
+
+		assert.Equal(t, "type,value,source,parents\n", out.String())
+	})
+}
+
+func TestRecordResult(t *testing.T) {
+	t.Parallel()
+
+	t.Run("round_trip", func(t *testing.T) {
+		for _, want := range testResults {
+			got, err := NewRecord(want).Result()
+			require.NoError(t, err)
+			assert.Equal(t, want, got)
+		}
+	})
+
+	t.Run("unknown_type", func(t *testing.T) {
+		_, err := Record{Type: "ip", Value: "10.0.0.1"}.Result()
+		assert.Error(t, err)
+	})
+}
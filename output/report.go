@@ -0,0 +1,47 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/go-appsec/scout/sources"
+)
+
+// ReportData is the document written by the report encoder.
+type ReportData struct {
+	// Total is the number of results in the report.
+	Total int `json:"total"`
+	// Results maps a result type name, then source name, to the values in the order they were received.
+	Results map[string]map[string][]string `json:"results"`
+}
+
+// reportEncoder buffers results and writes them grouped as a single JSON document.
+type reportEncoder struct {
+	w    io.Writer
+	data ReportData
+}
+
+// NewReport returns an encoder which groups results by type and source, writing a ReportData document on Close.
+// Unlike the other encoders, output is held in memory until the encoder is closed.
+func NewReport(w io.Writer) Encoder {
+	return &reportEncoder{w: w, data: ReportData{Results: make(map[string]map[string][]string)}}
+}
+
+func (e *reportEncoder) Encode(r sources.Result) error {
+	typeName := r.Type.String()
+	bySource, ok := e.data.Results[typeName]
+	if !ok {
+		bySource = make(map[string][]string)
+		e.data.Results[typeName] = bySource
+	}
+	bySource[r.Source] = append(bySource[r.Source], r.Value)
+	e.data.Total++
+	return nil
+}
+
+func (e *reportEncoder) Close() error {
+	enc := json.NewEncoder(e.w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(e.data)
+}
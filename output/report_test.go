@@ -0,0 +1,43 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-appsec/scout/sources"
+)
+
+func TestReport(t *testing.T) {
+	t.Parallel()
+
+	t.Run("groups_by_type_and_source", func(t *testing.T) {
+		results := append(slices.Clone(testResults), sources.Result{Type: sources.Subdomain, Value: "www.example.com", Source: "crtsh"})
+		var out bytes.Buffer
+		require.NoError(t, Write(NewReport(&out), resultSeq(results, nil), nil))
+
+		var data ReportData
+		require.NoError(t, json.Unmarshal(out.Bytes(), &data))
+		assert.Equal(t, 4, data.Total)
+		assert.Equal(t, map[string]map[string][]string{
+			"subdomain": {
+				"crtsh":  {"api.example.com", "www.example.com"},
+				"anubis": {"dev.corp.example.com"},
+			},
+			"url": {
+				"wayback": {"https://example.com/a?b=<c>"},
+			},
+		}, data.Results)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, Write(NewReport(&out), resultSeq(nil, nil), nil))
+
+		assert.JSONEq(t, `{"total":0,"results":{}}`, out.String())
+	})
+}
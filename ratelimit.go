@@ -0,0 +1,73 @@
+package scout
+
+import (
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimiters holds the rate limiters used by queries.
+// Share a RateLimiters across Query calls, such as in a long running process, so limits apply to all of them together.
+// The limits themselves are still set through WithGlobalRateLimit and WithSourceRateLimit.
+// Queries with adaptive rate limiting share per-source limiters only with other adaptive queries.
+type RateLimiters struct {
+	mu       sync.Mutex
+	global   *rate.Limiter
+	bySource map[string]*rate.Limiter
+	adaptive map[string]*adaptiveLimiter
+}
+
+// NewRateLimiters creates an empty set of rate limiters.
+func NewRateLimiters() *RateLimiters {
+	return &RateLimiters{bySource: make(map[string]*rate.Limiter), adaptive: make(map[string]*adaptiveLimiter)}
+}
+
+// globalLimiter returns the limiter shared by all sources.
+// The limit of an existing limiter is updated if it differs, so the most recent query's limit applies.
+func (r *RateLimiters) globalLimiter(limit rate.Limit) *rate.Limiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.global == nil {
+		r.global = rate.NewLimiter(limit, 1)
+	} else if r.global.Limit() != limit {
+		r.global.SetLimit(limit)
+	}
+	return r.global
+}
+
+// sourceLimiter returns the limiter for a source or quota group, updating its limit and burst as for globalLimiter.
+func (r *RateLimiters) sourceLimiter(name string, limit rate.Limit, burst int) *rate.Limiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	limiter, ok := r.bySource[name]
+	if !ok {
+		limiter = rate.NewLimiter(limit, burst)
+		r.bySource[name] = limiter
+		return limiter
+	}
+	if limiter.Limit() != limit {
+		limiter.SetLimit(limit)
+	}
+	if limiter.Burst() != burst {
+		limiter.SetBurst(burst)
+	}
+	return limiter
+}
+
+// adaptiveLimiter returns the adaptive limiter for a source, updating its configured limit as for sourceLimiter.
+// A limit reduced in response to throttling is kept, so later queries start from it.
+func (r *RateLimiters) adaptiveLimiter(name string, limit rate.Limit, burst int, opts AdaptiveRateLimitOptions) *adaptiveLimiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	limiter, ok := r.adaptive[name]
+	if !ok {
+		limiter = newAdaptiveLimiter(limit, burst, opts)
+		r.adaptive[name] = limiter
+	} else {
+		limiter.configure(limit, burst, opts)
+	}
+	return limiter
+}
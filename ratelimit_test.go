@@ -0,0 +1,197 @@
+package scout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+
+	"github.com/go-appsec/scout/sources"
+)
+
+func TestRateLimiters(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reuses_source_limiter", func(t *testing.T) {
+		limiters := NewRateLimiters()
+
+		first := limiters.sourceLimiter("crtsh", 5, 1)
+		assert.Same(t, first, limiters.sourceLimiter("crtsh", 5, 1))
+		assert.NotSame(t, first, limiters.sourceLimiter("anubis", 5, 1))
+	})
+
+	t.Run("updates_limit", func(t *testing.T) {
+		limiters := NewRateLimiters()
+
+		global := limiters.globalLimiter(5)
+		assert.Same(t, global, limiters.globalLimiter(10))
+		assert.Equal(t, rate.Limit(10), global.Limit())
+		assert.Equal(t, rate.Limit(2), limiters.sourceLimiter("crtsh", 2, 1).Limit())
+		assert.Equal(t, rate.Limit(3), limiters.sourceLimiter("crtsh", 3, 1).Limit())
+		assert.Equal(t, 4, limiters.sourceLimiter("crtsh", 3, 4).Burst())
+	})
+}
+
+func TestQuerySharedRateLimiters(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	t.Cleanup(server.Close)
+
+	requesting := requestingSource("limited", server.URL, 1)
+	limiters := NewRateLimiters()
+
+	// Each query makes one request, separate limiters would let all of them through immediately
+	start := time.Now()
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := Collect(Query(t.Context(), "example.com",
+				WithSources([]sources.Source{requesting}),
+				WithHTTPClient(server.Client()),
+				WithSourceRateLimit("limited", 20),
+				WithRateLimiters(limiters),
+			))
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	require.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+}
+
+func TestQuerySourceDefaultRateLimit(t *testing.T) {
+	t.Parallel()
+
+	limited := sources.Source{Name: "limited", RateLimit: 20, KeyedRateLimit: 50}
+
+	tests := []struct {
+		name    string
+		source  sources.Source
+		opts    []Option
+		wantGap time.Duration // Minimum spacing between consecutive requests after the burst
+		burst   int           // Requests allowed without spacing
+	}{
+		{name: "unkeyed", source: limited, wantGap: 50 * time.Millisecond, burst: 1},
+		{name: "keyed", source: limited, opts: []Option{WithAPIKey("limited", "k")}, wantGap: 20 * time.Millisecond, burst: 1},
+		{name: "keyed_without_keyed_rate", source: sources.Source{Name: "limited", RateLimit: 20},
+			opts: []Option{WithAPIKey("limited", "k")}, wantGap: 50 * time.Millisecond, burst: 1},
+		{name: "burst", source: sources.Source{Name: "limited", RateLimit: 20, Burst: 2}, wantGap: 50 * time.Millisecond, burst: 2},
+		{name: "overridden", source: limited, opts: []Option{WithSourceRateLimit("limited", 10)}, wantGap: 100 * time.Millisecond, burst: 1},
+		{name: "burst_overridden", source: limited, opts: []Option{WithSourceBurst("limited", 3)}, wantGap: 50 * time.Millisecond, burst: 3},
+		{name: "removed", source: limited, opts: []Option{WithSourceRateLimit("limited", 0)}, burst: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			times := requestTimes(t, tt.source, 4, tt.opts...)
+
+			require.Len(t, times, 4)
+			for i := 1; i < len(times); i++ {
+				gap := times[i].Sub(times[i-1])
+				if i < tt.burst {
+					assert.Less(t, gap, 15*time.Millisecond, "request %d within burst", i)
+				} else {
+					// Allow for timer granularity, the limiter reserves tokens at the exact rate
+					assert.GreaterOrEqual(t, gap, tt.wantGap-5*time.Millisecond, "request %d", i)
+				}
+			}
+		})
+	}
+}
+
+func TestQueryGroupRateLimit(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var times []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		times = append(times, time.Now())
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+
+	first := requestingSource("otx-urls", server.URL, 2)
+	second := requestingSource("otx-dns", server.URL, 2)
+	first.Group, second.Group = "otx", "otx"
+	limiters := NewRateLimiters()
+	_, err := Collect(Query(t.Context(), "example.com",
+		WithSources([]sources.Source{first, second}),
+		WithHTTPClient(server.Client()),
+		WithSourceRateLimit("otx", 20),
+		WithSourceRateLimit("otx-dns", 1000), // the group's limit takes precedence
+		WithRateLimiters(limiters),
+	))
+	require.NoError(t, err)
+
+	// Separate limiters would let each source's first request through together
+	require.Len(t, times, 4)
+	slices.SortFunc(times, func(a, b time.Time) int { return a.Compare(b) })
+	for i := 1; i < len(times); i++ {
+		assert.GreaterOrEqual(t, times[i].Sub(times[i-1]), 45*time.Millisecond, "request %d", i)
+	}
+	assert.Len(t, limiters.bySource, 1)
+}
+
+func TestQueryGroupLowestLimit(t *testing.T) {
+	t.Parallel()
+
+	urls := mockSource("otx-urls", sources.Subdomain, nil, nil)
+	dns := mockSource("otx-dns", sources.Subdomain, nil, nil)
+	urls.Group, dns.Group = "otx", "otx"
+	urls.RateLimit, urls.Burst = 5, 3
+	dns.RateLimit, dns.Burst = 2, 4
+
+	for name, opts := range map[string][]Option{
+		"recommended": nil,
+		"per_source":  {WithSourceRateLimit("otx-urls", 1000), WithSourceRateLimit("otx-dns", 20)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			limiters := NewRateLimiters()
+			_, summary := querySummary(t, append(opts, WithSources([]sources.Source{urls, dns}), WithRateLimiters(limiters))...)
+
+			want := 2.0
+			if name == "per_source" {
+				want = 20
+			}
+			assert.InDelta(t, want, summary.Source("otx-urls").RateLimit, 0.001)
+			assert.InDelta(t, want, summary.Source("otx-dns").RateLimit, 0.001)
+			require.Len(t, limiters.bySource, 1)
+			assert.Equal(t, 3, limiters.bySource["otx"].Burst())
+		})
+	}
+}
+
+// requestTimes runs a query of src making the given number of requests, returning when each reached the server.
+func requestTimes(t *testing.T, src sources.Source, requests int, opts ...Option) []time.Time {
+	t.Helper()
+
+	var mu sync.Mutex
+	var times []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		times = append(times, time.Now())
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+
+	requesting := requestingSource(src.Name, server.URL, requests)
+	src.Yields, src.Run = requesting.Yields, requesting.Run
+	opts = append([]Option{WithSources([]sources.Source{src}), WithHTTPClient(server.Client())}, opts...)
+	_, err := Collect(Query(t.Context(), "example.com", opts...))
+	require.NoError(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	return times
+}
@@ -0,0 +1,119 @@
+package scout
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-appsec/scout/normalize"
+	"github.com/go-appsec/scout/sources"
+)
+
+// ErrRequestBudgetExceeded is returned for requests made after the MaxRequests budget is spent.
+var ErrRequestBudgetExceeded = errors.New("request budget exceeded")
+
+// recursionTracker decides which discovered subdomains are queried as new targets.
+type recursionTracker struct {
+	domain   string
+	opts     RecursionOptions
+	children map[string]int
+	seen     map[string]bool // names observed, so each counts once toward MinChildren without deduplication
+	queued   map[string]bool
+}
+
+func newRecursionTracker(domain string, opts RecursionOptions) *recursionTracker {
+	return &recursionTracker{
+		domain:   strings.ToLower(domain),
+		opts:     opts,
+		children: make(map[string]int),
+		seen:     make(map[string]bool),
+		queued:   make(map[string]bool),
+	}
+}
+
+// observe records a discovered subdomain and returns the names which became eligible as targets, shallowest first.
+// Each name is returned at most once, and a subdomain observed again is not counted again.
+func (t *recursionTracker) observe(subdomain string) []string {
+	rel, ok := strings.CutSuffix(normalize.Hostname(subdomain), "."+t.domain)
+	if !ok || rel == "" {
+		return nil
+	}
+	labels := strings.Split(rel, ".")
+	if slices.ContainsFunc(labels, func(l string) bool { return l == "" || strings.Contains(l, "*") }) {
+		return nil // wildcards and malformed names can't be queried
+	}
+	if t.seen[rel] {
+		return nil
+	}
+	t.seen[rel] = true
+
+	var eligible []string
+	for i := len(labels) - 1; i >= 0; i-- {
+		depth := len(labels) - i
+		if depth > t.opts.MaxDepth {
+			break
+		}
+		candidate := strings.Join(labels[i:], ".") + "." + t.domain
+		if i > 0 {
+			t.children[candidate]++
+		}
+		if !t.queued[candidate] && t.children[candidate] >= t.opts.MinChildren {
+			t.queued[candidate] = true
+			eligible = append(eligible, candidate)
+		}
+	}
+	return eligible
+}
+
+// recursionSources returns the sources to run against recursive targets.
+func recursionSources(all []sources.Source, opts RecursionOptions) []sources.Source {
+	var result []sources.Source
+	for _, s := range all {
+		if len(opts.Sources) > 0 {
+			if slices.Contains(opts.Sources, s.Name) {
+				result = append(result, s)
+			}
+		} else if s.Recursive {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// requestBudget counts requests against a hard maximum.
+type requestBudget struct {
+	max  int64
+	used atomic.Int64
+}
+
+// take reserves a request, returning false if the budget is spent.
+func (b *requestBudget) take() bool {
+	return b.used.Add(1) <= b.max
+}
+
+// exhausted returns true if no requests remain.
+func (b *requestBudget) exhausted() bool {
+	return b.used.Load() >= b.max
+}
+
+// budgetTransport wraps an http.RoundTripper to enforce a request budget.
+type budgetTransport struct {
+	base   http.RoundTripper
+	budget *requestBudget
+}
+
+func (t *budgetTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.budget.take() {
+		return nil, ErrRequestBudgetExceeded
+	}
+	return t.base.RoundTrip(req)
+}
+
+// budgetMiddleware returns middleware enforcing the request budget on all requests.
+func budgetMiddleware(budget *requestBudget) Middleware {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return &budgetTransport{base: rt, budget: budget}
+	}
+}
@@ -0,0 +1,274 @@
+package scout
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-appsec/scout/sources"
+)
+
+func TestRecursionTrackerObserve(t *testing.T) {
+	t.Parallel()
+
+	t.Run("min_children_zero_queues_discovery", func(t *testing.T) {
+		tracker := newRecursionTracker("example.com", RecursionOptions{MaxDepth: 1})
+
+		assert.Equal(t, []string{"corp.example.com"}, tracker.observe("corp.example.com"))
+		assert.Empty(t, tracker.observe("corp.example.com"))
+	})
+
+	t.Run("min_children_threshold", func(t *testing.T) {
+		tracker := newRecursionTracker("example.com", RecursionOptions{MaxDepth: 2, MinChildren: 2})
+
+		assert.Empty(t, tracker.observe("a.corp.example.com"))
+		assert.Equal(t, []string{"corp.example.com"}, tracker.observe("b.corp.example.com"))
+		assert.Empty(t, tracker.observe("c.corp.example.com"))
+	})
+
+	t.Run("counts_all_ancestors", func(t *testing.T) {
+		tracker := newRecursionTracker("example.com", RecursionOptions{MaxDepth: 3, MinChildren: 1})
+
+		assert.Equal(t, []string{"corp.example.com", "dev.corp.example.com"}, tracker.observe("a.dev.corp.example.com"))
+	})
+
+	t.Run("counts_distinct_children", func(t *testing.T) {
+		tracker := newRecursionTracker("example.com", RecursionOptions{MaxDepth: 2, MinChildren: 2})
+
+		assert.Empty(t, tracker.observe("a.corp.example.com"))
+		assert.Empty(t, tracker.observe("A.corp.example.com."))
+		assert.Equal(t, []string{"corp.example.com"}, tracker.observe("b.corp.example.com"))
+	})
+
+	t.Run("respects_max_depth", func(t *testing.T) {
+		tracker := newRecursionTracker("example.com", RecursionOptions{MaxDepth: 1})
+
+		assert.Equal(t, []string{"corp.example.com"}, tracker.observe("dev.corp.example.com"))
+	})
+
+	t.Run("normalizes_case_and_trailing_dot", func(t *testing.T) {
+		tracker := newRecursionTracker("Example.com", RecursionOptions{MaxDepth: 1})
+
+		assert.Equal(t, []string{"corp.example.com"}, tracker.observe("CORP.example.com."))
+	})
+
+	t.Run("ignores_invalid", func(t *testing.T) {
+		tracker := newRecursionTracker("example.com", RecursionOptions{MaxDepth: 2})
+
+		assert.Empty(t, tracker.observe("example.com"))
+		assert.Empty(t, tracker.observe("other.org"))
+		assert.Empty(t, tracker.observe("*.corp.example.com"))
+		assert.Empty(t, tracker.observe("a..example.com"))
+	})
+}
+
+func TestRecursionSources(t *testing.T) {
+	t.Parallel()
+
+	all := []sources.Source{
+		{Name: "deep", Recursive: true},
+		{Name: "shallow"},
+	}
+
+	t.Run("default_recursive_flag", func(t *testing.T) {
+		got := recursionSources(all, RecursionOptions{})
+
+		require.Len(t, got, 1)
+		assert.Equal(t, "deep", got[0].Name)
+	})
+
+	t.Run("named_sources", func(t *testing.T) {
+		got := recursionSources(all, RecursionOptions{Sources: []string{"shallow"}})
+
+		require.Len(t, got, 1)
+		assert.Equal(t, "shallow", got[0].Name)
+	})
+}
+
+func TestBudgetTransport(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	budget := &requestBudget{max: 2}
+	client := &http.Client{Transport: budgetMiddleware(budget)(server.Client().Transport)}
+
+	for range 2 {
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+	}
+	assert.True(t, budget.exhausted())
+
+	_, err := client.Get(server.URL)
+	assert.ErrorIs(t, err, ErrRequestBudgetExceeded)
+}
+
+// recordingSource creates a test source yielding subdomains per target and recording queried targets.
+func recordingSource(name string, recursive bool, byTarget map[string][]string) (sources.Source, func() []string) {
+	var mu sync.Mutex
+	var targets []string
+	return sources.Source{
+		Name:      name,
+		Yields:    sources.Subdomain,
+		Recursive: recursive,
+		Run: func(_ context.Context, _ *http.Client, domain string, _ string) iter.Seq2[sources.Result, error] {
+			mu.Lock()
+			targets = append(targets, domain)
+			mu.Unlock()
+			return func(yield func(sources.Result, error) bool) {
+				for _, v := range byTarget[domain] {
+					if !yield(sources.Result{Type: sources.Subdomain, Value: v, Source: name}, nil) {
+						return
+					}
+				}
+			}
+		},
+	}, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), targets...)
+	}
+}
+
+func TestQueryRecursion(t *testing.T) {
+	t.Parallel()
+
+	byTarget := map[string][]string{
+		"example.com":          {"corp.example.com", "www.example.com"},
+		"corp.example.com":     {"dev.corp.example.com", "vpn.corp.example.com"},
+		"dev.corp.example.com": {"ci.dev.corp.example.com"},
+	}
+
+	t.Run("disabled_by_default", func(t *testing.T) {
+		src, targets := recordingSource("deep", true, byTarget)
+
+		results, err := Collect(Query(t.Context(), "example.com", WithSources([]sources.Source{src}), WithParallelism(1)))
+		require.NoError(t, err)
+
+		assert.Len(t, results, 2)
+		assert.Equal(t, []string{"example.com"}, targets())
+	})
+
+	t.Run("records_parent_chain", func(t *testing.T) {
+		src, targets := recordingSource("deep", true, byTarget)
+
+		results, err := Collect(Query(t.Context(), "example.com",
+			WithSources([]sources.Source{src}),
+			WithParallelism(1),
+			WithRecursion(2, 0),
+		))
+		require.NoError(t, err)
+
+		parents := make(map[string][]string)
+		for _, r := range results {
+			parents[r.Value] = r.Parents
+		}
+		assert.Len(t, results, 5)
+		assert.Empty(t, parents["corp.example.com"])
+		assert.Equal(t, []string{"corp.example.com"}, parents["dev.corp.example.com"])
+		assert.Equal(t, []string{"corp.example.com", "dev.corp.example.com"}, parents["ci.dev.corp.example.com"])
+		assert.ElementsMatch(t, []string{
+			"example.com", "corp.example.com", "www.example.com", "dev.corp.example.com", "vpn.corp.example.com",
+		}, targets())
+	})
+
+	t.Run("distinct_children_without_dedupe", func(t *testing.T) {
+		src, targets := recordingSource("deep", true, map[string][]string{
+			"example.com": {"a.corp.example.com", "a.corp.example.com", "a.corp.example.com"},
+		})
+
+		results, err := Collect(Query(t.Context(), "example.com",
+			WithSources([]sources.Source{src}),
+			WithParallelism(1),
+			WithRecursion(2, 3),
+			WithDedupe(NoDedupe()),
+		))
+		require.NoError(t, err)
+
+		// Repeats are yielded, but one child does not make corp.example.com a target
+		assert.Len(t, results, 3)
+		assert.Equal(t, []string{"example.com"}, targets())
+	})
+
+	t.Run("only_recursive_sources", func(t *testing.T) {
+		deep, deepTargets := recordingSource("deep", true, byTarget)
+		shallow, shallowTargets := recordingSource("shallow", false, byTarget)
+
+		_, err := Collect(Query(t.Context(), "example.com",
+			WithSources([]sources.Source{deep, shallow}),
+			WithParallelism(1),
+			WithRecursion(1, 0),
+		))
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"example.com"}, shallowTargets())
+		assert.ElementsMatch(t, []string{"example.com", "corp.example.com", "www.example.com"}, deepTargets())
+	})
+
+	t.Run("named_recursion_sources", func(t *testing.T) {
+		deep, deepTargets := recordingSource("deep", true, byTarget)
+		shallow, shallowTargets := recordingSource("shallow", false, byTarget)
+
+		_, err := Collect(Query(t.Context(), "example.com",
+			WithSources([]sources.Source{deep, shallow}),
+			WithParallelism(1),
+			WithRecursion(1, 0),
+			WithRecursionSources("shallow"),
+		))
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"example.com"}, deepTargets())
+		assert.ElementsMatch(t, []string{"example.com", "corp.example.com", "www.example.com"}, shallowTargets())
+	})
+
+	t.Run("stops_at_request_budget", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		t.Cleanup(server.Close)
+
+		requesting := sources.Source{
+			Name:      "requesting",
+			Yields:    sources.Subdomain,
+			Recursive: true,
+			Run: func(ctx context.Context, client *http.Client, domain string, _ string) iter.Seq2[sources.Result, error] {
+				return func(yield func(sources.Result, error) bool) {
+					req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+					if err != nil {
+						yield(sources.Result{}, err)
+						return
+					}
+					resp, err := client.Do(req)
+					if err != nil {
+						yield(sources.Result{}, err)
+						return
+					}
+					_ = resp.Body.Close()
+					yield(sources.Result{Type: sources.Subdomain, Value: "a." + domain, Source: "requesting"}, nil)
+				}
+			},
+		}
+
+		results, err := Collect(Query(t.Context(), "example.com",
+			WithSources([]sources.Source{requesting}),
+			WithHTTPClient(server.Client()),
+			WithParallelism(1),
+			WithRecursion(10, 0),
+			WithMaxRequests(3),
+		))
+
+		assert.Len(t, results, 3)
+		assert.False(t, errors.Is(err, ErrRequestBudgetExceeded))
+	})
+}
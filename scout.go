@@ -3,13 +3,16 @@ package scout
 import (
 	"context"
 	"errors"
+	"fmt"
 	"iter"
 	"net/http"
-	"strings"
-	"sync"
+	"slices"
+	"time"
 
 	"golang.org/x/time/rate"
 
+	"github.com/go-appsec/scout/cluster"
+	"github.com/go-appsec/scout/normalize"
 	"github.com/go-appsec/scout/sources"
 )
 
@@ -17,110 +20,450 @@ import (
 // By default all registered sources are queried; use WithSources to override.
 // Results are deduplicated across all sources.
 func Query(ctx context.Context, domain string, opts ...Option) iter.Seq2[sources.Result, error] {
-	cfg := defaultOptions()
-	for _, opt := range opts {
-		opt(cfg)
-	}
+	cfg := ResolveOptions(opts...)
 
 	return func(yield func(sources.Result, error) bool) {
 		// Cancel context when iterator returns to prevent goroutine leaks
 		ctx, cancel := context.WithCancel(ctx)
 		defer cancel()
 
-		client := cfg.HTTPClient
-		if client == nil {
-			client = &http.Client{
-				Timeout: cfg.Timeout,
-				Transport: &userAgentTransport{
-					base:      http.DefaultTransport,
-					userAgent: "Mozilla/5.0 (compatible; go-appsec/scout-v" + Version + ")",
-				},
-				CheckRedirect: func(req *http.Request, via []*http.Request) error {
-					return errors.New("redirects not allowed")
-				},
-			}
+		q, err := newQuery(ctx, cfg, domain)
+		defer q.closeDedupe()
+		if cfg.OnSummary != nil {
+			defer func() { cfg.OnSummary(q.summary.build()) }()
 		}
+		if err != nil {
+			yield(sources.Result{}, err)
+			return
+		}
+
+		q.launch(domain, nil, cfg.Sources)
+		q.run(yield)
+	}
+}
+
+// resultItem is sent from source goroutines to the query goroutine.
+// Each source run ends by sending an item with done set.
+type resultItem struct {
+	run    *sourceRun
+	result sources.Result
+	err    error
+	done   bool
+}
+
+// query holds the state of a single Query iteration.
+type query struct {
+	ctx            context.Context
+	cfg            *Options
+	client         *http.Client // settings and transport each source's middleware chain wraps
+	middleware     []Middleware // query-wide rate limit and budget, shared by every source
+	dedupe         Deduplicator
+	results        chan resultItem
+	sem            chan struct{} // semaphore for parallelism control
+	budget         *requestBudget
+	recursion      *recursionTracker
+	recursiveSrcs  []sources.Source
+	proxyPools     map[string]*proxyPool     // keyed as Options.SourceProxies
+	defaultProxies *proxyPool                // Options.Proxies, nil for the environment's proxy
+	userAgents     map[string]*userAgentPool // keyed as Options.SourceUserAgents, with "" for Options.UserAgents
+	profileAgents  map[sources.UserAgentProfile]*userAgentPool
+	limiters       *RateLimiters
+	summary        *summaryBuilder
+	groupLimits    map[string]groupLimit // keyed by quota group, set once the sources are known
+	pending        int                   // running sources, only accessed from the query goroutine
+}
+
+// groupLimit is the rate limit and burst shared by the sources of a quota group.
+type groupLimit struct {
+	limit rate.Limit
+	burst int
+}
+
+// newQuery creates the query state, the query is returned with an error for an invalid configuration
+// so its summary can still be built.
+func newQuery(ctx context.Context, cfg *Options, domain string) (*query, error) {
+	q := &query{
+		ctx:     ctx,
+		cfg:     cfg,
+		results: make(chan resultItem),
+		sem:     make(chan struct{}, cfg.Parallelism),
+		summary: newSummaryBuilder(domain),
+	}
+	if cfg.URLReduction != nil {
+		q.summary.reducer = cluster.NewReducer(*cfg.URLReduction)
+	}
 
-		if cfg.GlobalRateLimit > 0 {
-			client = wrapClientWithRateLimiter(client, rate.NewLimiter(cfg.GlobalRateLimit, 1))
+	strategy := cfg.Dedupe
+	if strategy == nil {
+		strategy = ExactDedupe()
+	}
+	dedupe, err := strategy()
+	if err != nil {
+		return q, err
+	}
+	q.dedupe = dedupe
+
+	// Copy the caller's client so its settings are kept, sources never follow redirects unless it sets a policy
+	q.client = &http.Client{Timeout: cfg.Timeout, CheckRedirect: noRedirects}
+	if cfg.HTTPClient != nil {
+		q.client.Transport = cfg.HTTPClient.Transport
+		q.client.Jar = cfg.HTTPClient.Jar
+		q.client.Timeout = cfg.HTTPClient.Timeout
+		if cfg.HTTPClient.CheckRedirect != nil {
+			q.client.CheckRedirect = cfg.HTTPClient.CheckRedirect
 		}
+	}
+	if q.client.Transport == nil {
+		q.client.Transport = http.DefaultTransport
+	}
 
-		dedupe := &deduplicator{}
+	// Select proxies per request when any are configured, otherwise the environment's proxy applies
+	if cfg.Proxies != nil || len(cfg.SourceProxies) > 0 {
+		defaultPool, pools, err := newProxyPools(cfg)
+		if err != nil {
+			return q, err
+		}
+		q.defaultProxies, q.proxyPools = defaultPool, pools
+		if q.client.Transport, err = proxyTransport(q.client.Transport); err != nil {
+			return q, err
+		}
+	}
 
-		// Results channel
-		type resultItem struct {
-			result sources.Result
-			err    error
+	// User agent pools are shared by every source using them, so rotation spans sources
+	q.userAgents = make(map[string]*userAgentPool, len(cfg.SourceUserAgents)+1)
+	if len(cfg.UserAgents) > 0 {
+		q.userAgents[""] = &userAgentPool{agents: cfg.UserAgents}
+	}
+	for name, agents := range cfg.SourceUserAgents {
+		if len(agents) > 0 {
+			q.userAgents[name] = &userAgentPool{agents: agents}
 		}
-		results := make(chan resultItem)
+	}
+	q.profileAgents = map[sources.UserAgentProfile]*userAgentPool{
+		sources.UserAgentDefault: {agents: profileUserAgents(sources.UserAgentDefault)},
+		sources.UserAgentBrowser: {agents: profileUserAgents(sources.UserAgentBrowser)},
+	}
 
-		// Semaphore for parallelism control
-		sem := make(chan struct{}, cfg.Parallelism)
+	q.limiters = cfg.RateLimiters
+	if q.limiters == nil {
+		q.limiters = NewRateLimiters()
+	}
 
-		// Start source goroutines
-		var wg sync.WaitGroup
-		for _, src := range cfg.Sources {
-			// Get API key for this source (if configured)
-			var apiKey string
-			if cfg.APIKeys != nil {
-				apiKey = cfg.APIKeys[src.Name]
-			}
+	maxRequests := cfg.MaxRequests
+	if cfg.Recursion != nil && cfg.Recursion.MaxDepth > 0 {
+		q.recursion = newRecursionTracker(domain, *cfg.Recursion)
+		q.recursiveSrcs = recursionSources(cfg.Sources, *cfg.Recursion)
+		if maxRequests <= 0 {
+			maxRequests = defaultRecursionMaxRequests
+		}
+	}
+	// Sources of a group share a limiter, which takes the lowest limit among those able to run
+	q.groupLimits = make(map[string]groupLimit)
+	for _, src := range slices.Concat(cfg.Sources, q.recursiveSrcs) {
+		keyed := q.hasAPIKey(src.Name, src.QuotaGroup())
+		if src.AuthRequired && !keyed {
+			continue
+		}
+		limit, burst := q.sourceLimit(src, keyed)
+		if g, ok := q.groupLimits[src.QuotaGroup()]; ok {
+			limit, burst = min(limit, g.limit), min(burst, g.burst)
+		}
+		q.groupLimits[src.QuotaGroup()] = groupLimit{limit: limit, burst: burst}
+	}
+	if maxRequests > 0 {
+		q.budget = &requestBudget{max: int64(maxRequests)}
+		q.middleware = append(q.middleware, budgetMiddleware(q.budget))
+	}
+	if cfg.GlobalRateLimit > 0 {
+		q.middleware = append(q.middleware, rateLimitMiddleware(q.limiters.globalLimiter(cfg.GlobalRateLimit), cfg.Observer))
+	}
+	return q, nil
+}
 
-			if src.AuthRequired && apiKey == "" {
-				continue // skip sources without a key that require one
-			}
+// launch starts the sources against a target, parents is the recursive target chain which led to it.
+func (q *query) launch(target string, parents []string, srcs []sources.Source) {
+	for _, src := range srcs {
+		run := &sourceRun{source: src.Name}
+		q.summary.runs = append(q.summary.runs, run)
 
-			wg.Add(1)
-			go func(s sources.Source, key string) {
-				defer wg.Done()
+		// Get API key for this source (if configured), falling back to a key shared by its quota group
+		apiKey := q.apiKey(src.Name, src.QuotaGroup())
 
-				// Acquire semaphore slot
-				sem <- struct{}{}
-				defer func() { <-sem }()
+		if src.AuthRequired && apiKey == "" {
+			run.status = StatusSkippedNoKey
+			run.done = true
+			if q.cfg.Logger != nil {
+				q.cfg.Logger.DebugContext(q.ctx, "source skipped, api key required", "source", src.Name, "target", target)
+			}
+			continue // skip sources without a key that require one
+		}
 
-				// Per-source timeout context
-				srcCtx, cancel := context.WithTimeout(ctx, cfg.Timeout)
-				defer cancel()
+		q.pending++
+		go q.runSource(run, src, apiKey, target, parents)
+	}
+}
 
-				// Apply per-source rate limiting
-				srcClient := client
-				if limit, ok := cfg.SourceRateLimits[s.Name]; ok {
-					srcClient = wrapClientWithRateLimiter(srcClient, rate.NewLimiter(limit, 1))
-				}
+// runSource runs a single source and forwards its results to the query goroutine.
+func (q *query) runSource(run *sourceRun, s sources.Source, key, target string, parents []string) {
+	ctx, breaker, group := q.ctx, q.cfg.CircuitBreaker, s.QuotaGroup()
+	sink := q.newSink(run)
+	defer sink.close()
 
-				for result, err := range s.Run(srcCtx, srcClient, domain, key) {
-					select {
-					case <-ctx.Done():
-						return
-					case results <- resultItem{result: result, err: err}:
-					}
-				}
-			}(src, apiKey)
+	// Skip sources which keep failing
+	if breaker != nil && !breaker.allow(group) {
+		run.status = StatusCircuitOpen
+		err := fmt.Errorf("%s: %w", s.Name, ErrCircuitOpen)
+		if q.cfg.Logger != nil {
+			q.cfg.Logger.DebugContext(ctx, "source skipped, circuit open", "source", s.Name, "target", target)
 		}
+		sink.send(resultItem{run: run, err: err})
+		return
+	}
 
-		// Close results when all sources complete
-		go func() {
-			wg.Wait()
-			close(results)
+	// Acquire semaphore slot, it is released while blocked on the consumer and once the source finishes,
+	// before its buffered results are yielded
+	if !sink.acquire() {
+		if breaker != nil {
+			breaker.release(group)
+		}
+		run.status = StatusCancelled
+		return
+	}
+	defer sink.release()
+
+	// Per-source timeout context
+	srcCtx, cancel := context.WithTimeout(ctx, q.cfg.Timeout)
+	defer cancel()
+
+	// Record the run outcome, runs cut short by the consumer have none
+	start := time.Now()
+	if q.cfg.Logger != nil {
+		log := q.cfg.Logger.With("source", s.Name, "target", target)
+		srcCtx = sources.WithLogger(srcCtx, log)
+		log.DebugContext(srcCtx, "source started")
+		defer func() {
+			log.DebugContext(srcCtx, "source finished", "status", run.status, "duration", time.Since(start),
+				"results", run.raw.Load(), "errors", len(run.errs), "blocked", time.Duration(run.blocked.Load()))
+		}()
+	}
+	if pool, ok := groupValue(q.proxyPools, group, s.Name); ok {
+		srcCtx = context.WithValue(srcCtx, proxyContextKey{}, pool)
+	} else if q.defaultProxies != nil {
+		srcCtx = context.WithValue(srcCtx, proxyContextKey{}, q.defaultProxies)
+	}
+	observer := q.cfg.Observer
+	if observer != nil {
+		srcCtx = context.WithValue(srcCtx, sourceContextKey{}, s.Name)
+		observer.SourceStart(SourceStartEvent{Source: s.Name, Target: target, Time: start})
+		defer func() {
+			now := time.Now()
+			observer.SourceFinish(SourceFinishEvent{
+				Source:   s.Name,
+				Target:   target,
+				Status:   run.status,
+				Time:     now,
+				Duration: now.Sub(start),
+			})
 		}()
+	}
+	var runErr error
+	defer func() {
+		run.duration = time.Since(start)
+		if ctx.Err() != nil {
+			run.status = StatusCancelled
+			if breaker != nil {
+				breaker.release(group)
+			}
+			return
+		} else if runErr == nil {
+			runErr = srcCtx.Err() // sources may return silently on timeout
+			if runErr != nil {
+				run.errs = append(run.errs, fmt.Errorf("%s: %w", s.Name, runErr))
+			}
+		}
+		if runErr != nil {
+			run.status = StatusFailed
+		}
+		if breaker == nil {
+			return
+		} else if errors.Is(runErr, ErrRequestBudgetExceeded) {
+			breaker.release(group) // the query's own budget ran out, not a failure of the source
+		} else {
+			breaker.record(group, runErr)
+		}
+	}()
 
-		// Yield results with deduplication
-		for r := range results {
-			if r.err != nil {
-				if !yield(sources.Result{}, r.err) {
-					return
-				}
-				continue
+	// Build the source's middleware chain in the order documented on Middleware
+	chain := []Middleware{userAgentMiddleware(q.userAgentPool(s, group))}
+	if header, ok := groupValue(q.cfg.SourceHeaders, group, s.Name); ok {
+		chain = append(chain, Headers(header))
+	}
+	if q.cfg.Cache != nil {
+		chain = append(chain, q.cfg.Cache.Middleware())
+	}
+	if q.cfg.Retry != nil {
+		chain = append(chain, retryMiddleware(*q.cfg.Retry, observer))
+	}
+
+	// Apply rate limiting shared by the source's quota group
+	limit, burst := q.groupLimit(s, key != "")
+	run.rateLimit = limit
+	if adaptive := q.cfg.AdaptiveRateLimit; adaptive != nil {
+		limiter := q.limiters.adaptiveLimiter(group, limit, burst, *adaptive)
+		chain = append(chain, adaptiveLimitMiddleware(limiter, observer))
+		defer func() { run.rateLimit = limiter.Limit() }()
+	} else if limit != rate.Inf {
+		chain = append(chain, rateLimitMiddleware(q.limiters.sourceLimiter(group, limit, burst), observer))
+	}
+	chain = append(chain, q.middleware...)
+	chain = append(chain, q.cfg.Middleware...)
+	if middleware, ok := groupValue(q.cfg.SourceMiddleware, group, s.Name); ok {
+		chain = append(chain, middleware...)
+	}
+	if q.cfg.Logger != nil {
+		chain = append(chain, loggingMiddleware())
+	}
+	if q.cfg.OnSummary != nil {
+		chain = append(chain, statsMiddleware(run))
+	}
+	if observer != nil {
+		chain = append(chain, observerMiddleware(s.Name, observer))
+	}
+	srcClient := &http.Client{
+		Transport:     Chain(chain...)(q.client.Transport),
+		CheckRedirect: q.client.CheckRedirect,
+		Jar:           q.client.Jar,
+		Timeout:       q.client.Timeout,
+	}
+
+	for result, err := range s.Run(srcCtx, srcClient, target, key) {
+		if err != nil {
+			runErr = err
+			run.errs = append(run.errs, err)
+		} else {
+			run.raw.Add(1)
+			if len(parents) > 0 {
+				result.Parents = parents
 			}
+		}
+		if !sink.send(resultItem{run: run, result: result, err: err}) {
+			return
+		}
+	}
+}
+
+// sourceLimit returns the rate limit and burst of a source, its recommended rate and burst unless overridden.
+func (q *query) sourceLimit(s sources.Source, keyed bool) (rate.Limit, int) {
+	group := s.QuotaGroup()
+	limit, ok := groupValue(q.cfg.SourceRateLimits, group, s.Name)
+	if !ok {
+		limit = rate.Inf
+		if recommended := s.RecommendedRate(keyed); recommended > 0 {
+			limit = rate.Limit(recommended)
+		}
+	}
+	burst, ok := groupValue(q.cfg.SourceBursts, group, s.Name)
+	if !ok {
+		burst = s.RecommendedBurst()
+	}
+	return limit, burst
+}
 
-			if dedupe.seen(r.result.Value) {
-				continue // skip duplicates
+// groupLimit returns the rate limit and burst of a source's quota group, the lowest of the query's sources
+// in the group, so sources sharing the group's limiter agree on one limit. A source outside the query's
+// sources uses its own.
+func (q *query) groupLimit(s sources.Source, keyed bool) (rate.Limit, int) {
+	if limit, ok := q.groupLimits[s.QuotaGroup()]; ok {
+		return limit.limit, limit.burst
+	}
+	return q.sourceLimit(s, keyed)
+}
+
+// userAgentPool returns the user agents for a source, from its own or the configured user agents,
+// else those of the profile it prefers.
+func (q *query) userAgentPool(s sources.Source, group string) *userAgentPool {
+	if pool, ok := groupValue(q.userAgents, group, s.Name); ok {
+		return pool
+	} else if pool, ok := q.userAgents[""]; ok {
+		return pool
+	} else if pool, ok := q.profileAgents[s.UserAgent]; ok {
+		return pool
+	}
+	return q.profileAgents[sources.UserAgentDefault]
+}
+
+// run yields results with deduplication until all sources complete.
+func (q *query) run(yield func(sources.Result, error) bool) {
+	for q.pending > 0 {
+		var r resultItem
+		select {
+		case <-q.ctx.Done():
+			return
+		case r = <-q.results:
+		}
+
+		if r.done {
+			r.run.done = true
+			q.pending--
+			continue
+		} else if r.err != nil {
+			q.summary.errors++
+			if q.cfg.Observer != nil {
+				q.cfg.Observer.Error(ErrorEvent{Source: r.run.source, Err: r.err, Time: time.Now()})
+			}
+			if !yield(sources.Result{}, r.err) {
+				return
 			}
+			continue
+		}
 
-			if !yield(r.result, nil) {
+		if r.result.Type == sources.URL && q.cfg.URLNormalization != nil {
+			// URLs which fail to parse are kept as produced, they are still deduplicated exactly
+			if normalized, err := normalize.URL(r.result.Value, *q.cfg.URLNormalization); err == nil {
+				r.result.Value = normalized
+			}
+		}
+		seen, err := q.dedupe.Seen(DedupeKey(r.result.Type, r.result.Value))
+		if err != nil {
+			// The result is yielded rather than lost, it may be a duplicate
+			q.summary.errors++
+			if q.cfg.Observer != nil {
+				q.cfg.Observer.Error(ErrorEvent{Source: r.run.source, Err: err, Time: time.Now()})
+			}
+			if q.cfg.Logger != nil {
+				q.cfg.Logger.DebugContext(q.ctx, "dedupe failed", "source", r.run.source, "value", r.result.Value, "error", err)
+			}
+			if !yield(sources.Result{}, err) {
 				return
 			}
 		}
+		if seen {
+			if q.cfg.Observer != nil {
+				q.cfg.Observer.DuplicateSuppressed(ResultEvent{Result: r.result, Time: time.Now()})
+			}
+			continue // skip duplicates
+		}
+		if r.result.Type == sources.URL && q.summary.reducer != nil && !q.summary.reducer.Add(r.result.Value) {
+			continue // excluded, or its cluster already has enough samples
+		}
+
+		q.summary.results++
+		q.summary.unique[r.run.source]++
+		if q.cfg.Observer != nil {
+			q.cfg.Observer.ResultEmitted(ResultEvent{Result: r.result, Time: time.Now()})
+		}
+		if !yield(r.result, nil) {
+			return
+		}
+
+		if q.recursion != nil && r.result.Type == sources.Subdomain {
+			for _, target := range q.recursion.observe(r.result.Value) {
+				if q.budget.exhausted() {
+					break
+				}
+				q.launch(target, append(slices.Clone(r.result.Parents), target), q.recursiveSrcs)
+			}
+		}
 	}
 }
 
@@ -166,53 +509,50 @@ func URLs(ctx context.Context, domain string, opts ...Option) iter.Seq2[string,
 	}
 }
 
-// deduplicator tracks seen values and filters duplicates.
-type deduplicator struct {
-	values sync.Map
-}
-
-// seen returns true if the value was already seen, and marks it as seen.
-func (d *deduplicator) seen(value string) bool {
-	normalized := strings.ToLower(strings.TrimSpace(value))
-	_, loaded := d.values.LoadOrStore(normalized, struct{}{})
-	return loaded
-}
-
-// userAgentTransport wraps an http.RoundTripper to set User-Agent header.
-type userAgentTransport struct {
-	base      http.RoundTripper
-	userAgent string
+// closeDedupe closes the query's Deduplicator, if created, logging a failure as the results are already yielded.
+func (q *query) closeDedupe() {
+	if q.dedupe == nil {
+		return
+	}
+	if err := q.dedupe.Close(); err != nil && q.cfg.Logger != nil {
+		q.cfg.Logger.DebugContext(q.ctx, "closing deduplicator failed", "error", err)
+	}
 }
 
-func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	req = req.Clone(req.Context())
-	req.Header.Set("User-Agent", t.userAgent)
-	return t.base.RoundTrip(req)
+// groupValue returns the value set for a quota group, or else for the source name.
+// The group is checked first so every source in it uses the same shared limit.
+func groupValue[V any](m map[string]V, group, name string) (V, bool) {
+	if v, ok := m[group]; ok {
+		return v, true
+	}
+	v, ok := m[name]
+	return v, ok
 }
 
 // rateLimitTransport wraps an http.RoundTripper to apply rate limiting.
 type rateLimitTransport struct {
-	base    http.RoundTripper
-	limiter *rate.Limiter
+	base     http.RoundTripper
+	limiter  *rate.Limiter
+	observer Observer
 }
 
 func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
 	if err := t.limiter.Wait(req.Context()); err != nil {
 		return nil, err
 	}
+	if t.observer != nil {
+		now := time.Now()
+		source, _ := req.Context().Value(sourceContextKey{}).(string)
+		t.observer.RateLimitWait(RateLimitEvent{Source: source, Wait: now.Sub(start), Time: now})
+	}
 	return t.base.RoundTrip(req)
 }
 
-// wrapClientWithRateLimiter returns a new client that applies rate limiting to all requests.
-func wrapClientWithRateLimiter(client *http.Client, limiter *rate.Limiter) *http.Client {
-	transport := client.Transport
-	if transport == nil {
-		transport = http.DefaultTransport
-	}
-	return &http.Client{
-		Transport:     &rateLimitTransport{base: transport, limiter: limiter},
-		CheckRedirect: client.CheckRedirect,
-		Jar:           client.Jar,
-		Timeout:       client.Timeout,
+// rateLimitMiddleware returns middleware applying rate limiting to all requests.
+// If observer is non-nil, time spent waiting on the limiter is reported to it.
+func rateLimitMiddleware(limiter *rate.Limiter, observer Observer) Middleware {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return &rateLimitTransport{base: rt, limiter: limiter, observer: observer}
 	}
 }
@@ -1,17 +1,21 @@
 package scout
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"iter"
+	"log/slog"
 	"net/http"
 	"slices"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/go-appsec/scout/normalize"
 	"github.com/go-appsec/scout/sources"
 )
 
@@ -116,6 +120,53 @@ func TestQuery(t *testing.T) {
 		assert.Len(t, results, 1)
 	})
 
+	t.Run("normalizes_urls", func(t *testing.T) {
+		src := mockSource("test", sources.URL, []sources.Result{
+			{Type: sources.URL, Value: "HTTPS://Example.com:443/Admin/./Login?b=2&a=1#top", Source: "test"},
+			{Type: sources.URL, Value: "https://example.com/Admin/Login?a=1&b=2", Source: "test"},
+			{Type: sources.URL, Value: "https://example.com/admin/login?a=1&b=2", Source: "test"},
+			{Type: sources.URL, Value: "not a url", Source: "test"},
+		}, nil)
+
+		results, err := Collect(Query(t.Context(), "example.com", WithSources([]sources.Source{src})))
+		require.NoError(t, err)
+
+		values := make([]string, 0, len(results))
+		for _, r := range results {
+			values = append(values, r.Value)
+		}
+		assert.ElementsMatch(t, []string{
+			"https://example.com/Admin/Login?a=1&b=2",
+			"https://example.com/admin/login?a=1&b=2",
+			"not a url",
+		}, values)
+	})
+
+	t.Run("raw_urls", func(t *testing.T) {
+		src := mockSource("test", sources.URL, []sources.Result{
+			{Type: sources.URL, Value: "https://example.com/a?b=2&a=1", Source: "test"},
+			{Type: sources.URL, Value: "https://example.com/a?a=1&b=2", Source: "test"},
+			{Type: sources.URL, Value: "https://example.com/a?a=1&b=2", Source: "test"},
+		}, nil)
+
+		results, err := Collect(Query(t.Context(), "example.com", WithSources([]sources.Source{src}), WithRawURLs()))
+		require.NoError(t, err)
+		assert.Len(t, results, 2)
+	})
+
+	t.Run("strips_url_params", func(t *testing.T) {
+		src := mockSource("test", sources.URL, []sources.Result{
+			{Type: sources.URL, Value: "https://example.com/?id=1&utm_source=x&fbclid=y", Source: "test"},
+			{Type: sources.URL, Value: "https://example.com/?id=1", Source: "test"},
+		}, nil)
+
+		results, err := Collect(Query(t.Context(), "example.com", WithSources([]sources.Source{src}),
+			WithURLNormalization(normalize.URLOptions{StripParams: normalize.TrackingParams})))
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "https://example.com/?id=1", results[0].Value)
+	})
+
 	t.Run("respects_context_cancellation", func(t *testing.T) {
 		ctx, cancel := context.WithCancel(t.Context())
 		defer cancel()
@@ -246,6 +297,62 @@ func TestQuery(t *testing.T) {
 		assert.Len(t, results, 1)
 		assert.Equal(t, "api.example.com", results[0].Value)
 	})
+
+	t.Run("group_key", func(t *testing.T) {
+		keys := make(map[string]string)
+		var mu sync.Mutex
+		newSource := func(name string) sources.Source {
+			return sources.Source{
+				Name:         name,
+				Yields:       sources.Subdomain,
+				Group:        "otx",
+				AuthRequired: true,
+				Run: func(_ context.Context, _ *http.Client, _ string, apiKey string) iter.Seq2[sources.Result, error] {
+					mu.Lock()
+					keys[name] = apiKey
+					mu.Unlock()
+					return func(func(sources.Result, error) bool) {}
+				},
+			}
+		}
+
+		_, err := Collect(Query(t.Context(), "example.com",
+			WithSources([]sources.Source{newSource("otx-urls"), newSource("otx-dns")}),
+			WithAPIKey("otx", "shared-key"),
+			WithAPIKey("otx-dns", "own-key"),
+		))
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]string{"otx-urls": "shared-key", "otx-dns": "own-key"}, keys)
+	})
+}
+
+func TestQueryLogger(t *testing.T) {
+	t.Parallel()
+
+	logged := sources.Source{
+		Name:   "logged",
+		Yields: sources.Subdomain,
+		Run: func(ctx context.Context, _ *http.Client, _ string, _ string) iter.Seq2[sources.Result, error] {
+			return func(yield func(sources.Result, error) bool) {
+				sources.Logger(ctx).DebugContext(ctx, "from source")
+				yield(sources.Result{Type: sources.Subdomain, Value: "api.example.com", Source: "logged"}, nil)
+			}
+		},
+	}
+	keyed := sources.Source{Name: "keyed", Yields: sources.Subdomain, AuthRequired: true}
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	_, err := Collect(Query(t.Context(), "example.com", WithSources([]sources.Source{logged, keyed}), WithLogger(logger)))
+	require.NoError(t, err)
+
+	logs := buf.String()
+	assert.Contains(t, logs, `msg="source skipped, api key required" source=keyed target=example.com`)
+	assert.Contains(t, logs, `msg="source started" source=logged target=example.com`)
+	assert.Contains(t, logs, `msg="from source" source=logged target=example.com`)
+	assert.Contains(t, logs, `msg="source finished" source=logged target=example.com status=ran`)
+	assert.Contains(t, logs, "results=1 errors=0")
 }
 
 func TestSubdomains(t *testing.T) {
@@ -291,40 +398,3 @@ func TestURLs(t *testing.T) {
 	assert.True(t, slices.Contains(urls, "https://example.com/path"))
 	assert.True(t, slices.Contains(urls, "https://example.com/other"))
 }
-
-func TestDeduplicator(t *testing.T) {
-	t.Parallel()
-
-	d := &deduplicator{}
-
-	t.Run("first_occurrence_false", func(t *testing.T) {
-		assert.False(t, d.seen("test"))
-	})
-
-	t.Run("duplicate_returns_true", func(t *testing.T) {
-		assert.True(t, d.seen("test"))
-	})
-
-	t.Run("case_insensitive", func(t *testing.T) {
-		assert.True(t, d.seen("TEST"))
-	})
-
-	t.Run("trims_whitespace", func(t *testing.T) {
-		assert.True(t, d.seen("  test  "))
-	})
-
-	t.Run("new_value_false", func(t *testing.T) {
-		assert.False(t, d.seen("other"))
-	})
-}
-
-func TestUserAgentTransport(t *testing.T) {
-	t.Parallel()
-
-	transport := &userAgentTransport{
-		base:      http.DefaultTransport,
-		userAgent: "test-agent/1.0",
-	}
-
-	var _ http.RoundTripper = transport
-}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParseClients reads a client list with one client per line as a name and bearer token,
+// optionally followed by the client's concurrent query limit. Blank lines and lines starting with # are ignored.
+func ParseClients(r io.Reader) ([]Client, error) {
+	var clients []Client
+	names := make(map[string]bool)
+	tokens := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	var line int
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("server: line %d: expected name, token, and optional concurrency", line)
+		}
+		client := Client{Name: fields[0], Token: fields[1]}
+		if len(fields) == 3 {
+			n, err := strconv.Atoi(fields[2])
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("server: line %d: concurrency must be a positive integer", line)
+			}
+			client.Concurrency = n
+		}
+		if names[client.Name] {
+			return nil, fmt.Errorf("server: line %d: duplicate client %s", line, client.Name)
+		} else if tokens[client.Token] {
+			return nil, fmt.Errorf("server: line %d: duplicate token", line)
+		}
+		names[client.Name], tokens[client.Token] = true, true
+		clients = append(clients, client)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("server: %w", err)
+	}
+	return clients, nil
+}
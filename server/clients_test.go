@@ -0,0 +1,40 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseClients(t *testing.T) {
+	t.Parallel()
+
+	t.Run("valid", func(t *testing.T) {
+		clients, err := ParseClients(strings.NewReader("# internal tools\nci ci-token\n\n  dashboard  dash-token 4\n"))
+		require.NoError(t, err)
+		assert.Equal(t, []Client{
+			{Name: "ci", Token: "ci-token"},
+			{Name: "dashboard", Token: "dash-token", Concurrency: 4},
+		}, clients)
+	})
+
+	errTests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{name: "missing_token", input: "ci\n", wantErr: "line 1: expected name, token"},
+		{name: "extra_fields", input: "ci token 2 # nightly\n", wantErr: "line 1: expected name, token"},
+		{name: "bad_concurrency", input: "ci token\ndash other 0\n", wantErr: "line 2: concurrency must be a positive integer"},
+		{name: "duplicate_name", input: "ci a\nci b\n", wantErr: "duplicate client ci"},
+		{name: "duplicate_token", input: "ci a\ndash a\n", wantErr: "line 2: duplicate token"},
+	}
+	for _, tt := range errTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseClients(strings.NewReader(tt.input))
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
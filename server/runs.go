@@ -0,0 +1,108 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-appsec/scout"
+)
+
+// Run statuses.
+const (
+	StatusRunning   = "running"   // The query is streaming
+	StatusCompleted = "completed" // The query finished
+	StatusCancelled = "cancelled" // The client disconnected before the query finished
+)
+
+// Run is a query submitted to the server.
+type Run struct {
+	ID      string         `json:"id"`
+	Client  string         `json:"client"`
+	Domain  string         `json:"domain"`
+	Status  string         `json:"status"`
+	Started time.Time      `json:"started"`
+	Summary *scout.Summary `json:"summary,omitempty"` // Set once the run is no longer running
+}
+
+// runStore keeps running queries and the most recent finished ones.
+type runStore struct {
+	mu       sync.Mutex
+	runs     map[string]*Run
+	finished []string // IDs of finished runs, oldest first
+	max      int
+}
+
+func newRunStore(maxRuns int) *runStore {
+	return &runStore{runs: make(map[string]*Run), max: max(maxRuns, 0)}
+}
+
+// start records a new running query.
+func (s *runStore) start(client, domain string) (Run, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return Run{}, err
+	}
+	run := &Run{
+		ID:      hex.EncodeToString(id),
+		Client:  client,
+		Domain:  domain,
+		Status:  StatusRunning,
+		Started: time.Now(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runs[run.ID] = run
+	return *run, nil
+}
+
+// finish sets the summary of a run, evicting the oldest finished runs past the limit.
+func (s *runStore) finish(id string, summary *scout.Summary, cancelled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run, ok := s.runs[id]
+	if !ok {
+		return
+	}
+	run.Summary = summary
+	run.Status = StatusCompleted
+	if cancelled {
+		run.Status = StatusCancelled
+	}
+	s.finished = append(s.finished, id)
+	for len(s.finished) > s.max {
+		delete(s.runs, s.finished[0])
+		s.finished = s.finished[1:]
+	}
+}
+
+// get returns a copy of the run if it belongs to client.
+func (s *runStore) get(id, client string) (Run, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run, ok := s.runs[id]
+	if !ok || run.Client != client {
+		return Run{}, false
+	}
+	return *run, true
+}
+
+// list returns copies of the runs of client, most recently started first.
+func (s *runStore) list(client string) []Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	runs := make([]Run, 0, len(s.runs))
+	for _, run := range s.runs {
+		if run.Client == client {
+			runs = append(runs, *run)
+		}
+	}
+	slices.SortFunc(runs, func(a, b Run) int { return b.Started.Compare(a.Started) })
+	return runs
+}
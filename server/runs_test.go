@@ -0,0 +1,62 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-appsec/scout"
+)
+
+func TestRunStore(t *testing.T) {
+	t.Parallel()
+
+	t.Run("evicts_oldest_finished", func(t *testing.T) {
+		s := newRunStore(2)
+		var ids []string
+		for range 3 {
+			run, err := s.start("ci", "example.com")
+			require.NoError(t, err)
+			ids = append(ids, run.ID)
+		}
+		running, err := s.start("ci", "example.org")
+		require.NoError(t, err)
+
+		for _, id := range ids {
+			s.finish(id, &scout.Summary{Domain: "example.com"}, false)
+		}
+
+		_, ok := s.get(ids[0], "ci")
+		assert.False(t, ok)
+		for _, id := range append(ids[1:], running.ID) {
+			_, ok := s.get(id, "ci")
+			assert.True(t, ok)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		s := newRunStore(1)
+		run, err := s.start("ci", "example.com")
+		require.NoError(t, err)
+		assert.Equal(t, StatusRunning, run.Status)
+
+		s.finish(run.ID, nil, true)
+
+		got, ok := s.get(run.ID, "ci")
+		require.True(t, ok)
+		assert.Equal(t, StatusCancelled, got.Status)
+		assert.Nil(t, got.Summary)
+	})
+
+	t.Run("unique_ids", func(t *testing.T) {
+		s := newRunStore(0)
+		a, err := s.start("ci", "example.com")
+		require.NoError(t, err)
+		b, err := s.start("ci", "example.com")
+		require.NoError(t, err)
+
+		assert.NotEqual(t, a.ID, b.ID)
+		assert.Len(t, a.ID, 32)
+	})
+}
@@ -0,0 +1,462 @@
+// Package server exposes scout queries over a JSON HTTP API.
+//
+// Endpoints:
+//
+//	GET  /v1/sources     list the registered sources
+//	POST /v1/query       run a query, streaming events as NDJSON, or SSE when requested with Accept: text/event-stream
+//	GET  /v1/runs        list recent runs of the calling client
+//	GET  /v1/runs/{id}   fetch a run and its summary
+//
+// Provider API keys and other query options are configured on the server, so clients never hold them.
+// Clients authenticate with a bearer token and each has a limit on concurrent queries.
+package server
+
+import (
+	"cmp"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/go-appsec/scout"
+	"github.com/go-appsec/scout/normalize"
+	"github.com/go-appsec/scout/output"
+	"github.com/go-appsec/scout/sources"
+)
+
+const (
+	defaultConcurrency = 2
+	defaultMaxRuns     = 100
+	maxRequestBody     = 1 << 20
+)
+
+// Client is an API consumer allowed to call the server.
+type Client struct {
+	Name        string // Identifies the client in runs and logs
+	Token       string // Bearer token the client authenticates with
+	Concurrency int    // Maximum concurrent queries, if zero the server default is used
+}
+
+// config holds the Server options.
+type config struct {
+	queryOpts   []scout.Option
+	clients     []Client
+	concurrency int
+	maxRuns     int
+	logger      *slog.Logger
+}
+
+// Option configures a Server.
+type Option func(*config)
+
+// WithQueryOptions sets the options for every query, such as provider API keys, rate limits, and timeouts.
+// When the options set sources, clients may only query those. Sources requested by clients are applied on top.
+func WithQueryOptions(opts ...scout.Option) Option {
+	return func(c *config) {
+		c.queryOpts = opts
+	}
+}
+
+// WithClient adds a client which may call the server. Without any clients, requests are not authenticated
+// and all callers share a single anonymous client.
+func WithClient(client Client) Option {
+	return func(c *config) {
+		c.clients = append(c.clients, client)
+	}
+}
+
+// WithConcurrency sets the default limit on concurrent queries per client. Default is 2.
+func WithConcurrency(n int) Option {
+	return func(c *config) {
+		c.concurrency = n
+	}
+}
+
+// WithMaxRuns sets how many finished runs are kept for fetching summaries. Default is 100.
+func WithMaxRuns(n int) Option {
+	return func(c *config) {
+		c.maxRuns = n
+	}
+}
+
+// WithLogger sets the logger for request errors. Default discards all records.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *config) {
+		c.logger = l
+	}
+}
+
+// client is the runtime state of a Client.
+type client struct {
+	Client
+	sem chan struct{}
+}
+
+// Server is an http.Handler serving the scout API.
+type Server struct {
+	cfg       config
+	resolved  scout.Options // queryOpts applied, to chain the summary callback and redact API keys
+	clients   []*client
+	anonymous *client
+	runs      *runStore
+	mux       *http.ServeMux
+}
+
+// New creates a Server.
+func New(opts ...Option) *Server {
+	cfg := config{
+		concurrency: defaultConcurrency,
+		maxRuns:     defaultMaxRuns,
+		logger:      slog.New(slog.DiscardHandler),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.concurrency < 1 {
+		cfg.concurrency = 1
+	}
+
+	s := &Server{
+		cfg:  cfg,
+		runs: newRunStore(cfg.maxRuns),
+		mux:  http.NewServeMux(),
+	}
+	for _, opt := range cfg.queryOpts {
+		opt(&s.resolved)
+	}
+	if s.resolved.RateLimiters == nil { // rate limits apply across all clients' queries
+		s.cfg.queryOpts = append(slices.Clone(cfg.queryOpts), scout.WithRateLimiters(scout.NewRateLimiters()))
+	}
+	for _, c := range cfg.clients {
+		s.clients = append(s.clients, s.newClient(c))
+	}
+	if len(s.clients) == 0 {
+		s.anonymous = s.newClient(Client{Name: "anonymous"})
+	}
+
+	s.mux.HandleFunc("GET /v1/sources", s.handleSources)
+	s.mux.HandleFunc("POST /v1/query", s.handleQuery)
+	s.mux.HandleFunc("GET /v1/runs", s.handleRuns)
+	s.mux.HandleFunc("GET /v1/runs/{id}", s.handleRun)
+	return s
+}
+
+func (s *Server) newClient(c Client) *client {
+	if c.Concurrency <= 0 {
+		c.Concurrency = s.cfg.concurrency
+	}
+	return &client{Client: c, sem: make(chan struct{}, c.Concurrency)}
+}
+
+// ServeHTTP authenticates the client then routes the request.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c := s.authenticate(r)
+	if c == nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="scout"`)
+		writeError(w, http.StatusUnauthorized, "invalid or missing bearer token")
+		return
+	}
+	s.mux.ServeHTTP(w, r.WithContext(withClient(r.Context(), c)))
+}
+
+// authenticate returns the client for the request's bearer token, or nil if it does not match a client.
+func (s *Server) authenticate(r *http.Request) *client {
+	if s.anonymous != nil {
+		return s.anonymous
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return nil
+	}
+	var match *client
+	for _, c := range s.clients { // compare every token so timing does not reveal a match
+		if subtle.ConstantTimeCompare([]byte(token), []byte(c.Token)) == 1 {
+			match = c
+		}
+	}
+	return match
+}
+
+// SourceInfo describes a registered source.
+type SourceInfo struct {
+	Name         string   `json:"name"`
+	Yields       []string `json:"yields"`
+	Group        string   `json:"group"` // Quota group, shared rate limits apply across sources in it
+	AuthRequired bool     `json:"auth_required"`
+	KeyOptional  bool     `json:"key_optional,omitempty"`
+	KeyFormat    string   `json:"key_format,omitempty"`
+	RateLimit    float64  `json:"rate_limit,omitempty"` // Recommended requests per second
+	KeyedRate    float64  `json:"keyed_rate_limit,omitempty"`
+	Burst        int      `json:"burst"`
+	Quota        string   `json:"quota,omitempty"`
+	Homepage     string   `json:"homepage,omitempty"`
+	Collection   string   `json:"collection"`
+	UserAgent    string   `json:"user_agent"` // Preferred User-Agent profile: default or browser
+}
+
+// available returns the sources clients may query, those set by WithQueryOptions or else all registered sources,
+// sorted by name.
+func (s *Server) available() []sources.Source {
+	srcs := slices.Clone(s.resolved.Sources)
+	if srcs == nil {
+		srcs = sources.All()
+	}
+	slices.SortFunc(srcs, func(a, b sources.Source) int { return cmp.Compare(a.Name, b.Name) })
+	return srcs
+}
+
+func (s *Server) handleSources(w http.ResponseWriter, _ *http.Request) {
+	available := s.available()
+	infos := make([]SourceInfo, len(available))
+	for i, src := range available {
+		infos[i] = SourceInfo{
+			Name:         src.Name,
+			Yields:       typeNames(src.Yields),
+			Group:        src.QuotaGroup(),
+			AuthRequired: src.AuthRequired,
+			KeyOptional:  src.KeyOptional,
+			KeyFormat:    src.KeyFormat,
+			RateLimit:    src.RateLimit,
+			KeyedRate:    src.KeyedRateLimit,
+			Burst:        src.RecommendedBurst(),
+			Quota:        src.Quota.String(),
+			Homepage:     src.Homepage,
+			Collection:   src.Collection.String(),
+			UserAgent:    src.UserAgent.String(),
+		}
+	}
+	writeJSON(w, http.StatusOK, infos)
+}
+
+// typeNames returns the names of the result types set in t.
+func typeNames(t sources.ResultType) []string {
+	var names []string
+	for _, rt := range []sources.ResultType{sources.Subdomain, sources.URL} {
+		if t&rt != 0 {
+			names = append(names, rt.String())
+		}
+	}
+	return names
+}
+
+// QueryRequest is the body of a query request.
+type QueryRequest struct {
+	Domain  string   `json:"domain"`            // Domain to query, required
+	Sources []string `json:"sources,omitempty"` // Source names to run, all sources yielding the types if empty
+	Types   []string `json:"types,omitempty"`   // Result types to return, all types if empty
+}
+
+// parse validates the request against the available sources, returning the sources to run and the wanted result types.
+// The domain is put into canonical form, and must be a hostname as sources build request URLs from it.
+func (q *QueryRequest) parse(available []sources.Source) ([]sources.Source, sources.ResultType, error) {
+	if strings.TrimSpace(q.Domain) == "" {
+		return nil, 0, errors.New("domain is required")
+	}
+	domain := normalize.Hostname(q.Domain)
+	if !normalize.ValidHostname(domain) {
+		return nil, 0, fmt.Errorf("invalid domain %q", q.Domain)
+	}
+	q.Domain = domain
+	want := sources.Subdomain | sources.URL
+	if len(q.Types) > 0 {
+		want = 0
+		for _, name := range q.Types {
+			t, err := sources.ParseResultType(name)
+			if err != nil {
+				return nil, 0, err
+			}
+			want |= t
+		}
+	}
+
+	var srcs []sources.Source
+	if len(q.Sources) == 0 {
+		for _, src := range available {
+			if src.Yields&want != 0 {
+				srcs = append(srcs, src)
+			}
+		}
+		return srcs, want, nil
+	}
+	for _, name := range q.Sources {
+		i := slices.IndexFunc(available, func(src sources.Source) bool { return src.Name == name })
+		if i < 0 {
+			return nil, 0, fmt.Errorf("unknown source %q", name)
+		} else if available[i].Yields&want != 0 {
+			srcs = append(srcs, available[i])
+		}
+	}
+	return srcs, want, nil
+}
+
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
+	var req QueryRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	srcs, want, err := req.parse(s.available())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c := clientFrom(r.Context())
+	select {
+	case c.sem <- struct{}{}:
+		defer func() { <-c.sem }()
+	default:
+		w.Header().Set("Retry-After", "1")
+		writeError(w, http.StatusTooManyRequests, fmt.Sprintf("concurrent query limit of %d reached", c.Concurrency))
+		return
+	}
+
+	run, err := s.runs.start(c.Name, req.Domain)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "creating run failed")
+		return
+	}
+	events := newEventWriter(w, r)
+	w.Header().Set("X-Scout-Run-ID", run.ID)
+	w.WriteHeader(http.StatusOK)
+
+	var summary *scout.Summary
+	opts := append(slices.Clone(s.cfg.queryOpts),
+		scout.WithSources(srcs),
+		scout.WithSummary(func(sum *scout.Summary) {
+			summary = sum
+			if s.resolved.OnSummary != nil {
+				s.resolved.OnSummary(sum)
+			}
+		}),
+	)
+	writeErr := events.write(Event{Event: EventStart, RunID: run.ID})
+	if writeErr == nil {
+		for result, err := range scout.Query(r.Context(), req.Domain, opts...) {
+			if err != nil {
+				writeErr = events.write(Event{Event: EventError, Error: s.redact(err)})
+			} else if result.Type&want != 0 {
+				record := output.NewRecord(result)
+				writeErr = events.write(Event{Event: EventResult, Result: &record})
+			}
+			if writeErr != nil {
+				break // client disconnected
+			}
+		}
+	}
+
+	summary = s.redactSummary(summary)
+	cancelled := writeErr != nil || r.Context().Err() != nil
+	s.runs.finish(run.ID, summary, cancelled)
+	if writeErr != nil {
+		s.cfg.logger.DebugContext(r.Context(), "stream ended early", "run", run.ID, "client", c.Name, "error", writeErr)
+		return
+	}
+	_ = events.write(Event{Event: EventSummary, RunID: run.ID, Summary: summary})
+}
+
+func (s *Server) handleRuns(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.runs.list(clientFrom(r.Context()).Name))
+}
+
+func (s *Server) handleRun(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.runs.get(r.PathValue("id"), clientFrom(r.Context()).Name)
+	if !ok {
+		writeError(w, http.StatusNotFound, "run not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, run)
+}
+
+// redact returns an error's message without the server's API keys, which clients must never see.
+// Client errors include the request URL, so URLs are redacted as in logs, and any configured key is replaced
+// wherever else it appears.
+func (s *Server) redact(err error) string {
+	msg := err.Error()
+	for _, urlErr := range urlErrors(err) {
+		msg = strings.ReplaceAll(msg, urlErr.URL, sources.RedactURL(urlErr.URL))
+	}
+	keys := slices.Collect(maps.Values(s.resolved.APIKeys))
+	for _, pool := range s.resolved.APIKeyPools {
+		keys = append(keys, pool.Keys()...)
+	}
+	for _, key := range keys {
+		if key != "" {
+			msg = strings.ReplaceAll(msg, key, "REDACTED")
+			msg = strings.ReplaceAll(msg, url.QueryEscape(key), "REDACTED")
+		}
+	}
+	return msg
+}
+
+// urlErrors returns the *url.Error values in an error's tree.
+func urlErrors(err error) []*url.Error {
+	var found []*url.Error
+	switch e := err.(type) {
+	case *url.Error:
+		found = append(found, e)
+	case interface{ Unwrap() []error }:
+		for _, err := range e.Unwrap() {
+			found = append(found, urlErrors(err)...)
+		}
+		return found
+	}
+	if next := errors.Unwrap(err); next != nil {
+		found = append(found, urlErrors(next)...)
+	}
+	return found
+}
+
+// redactSummary returns a copy of the summary with redacted source errors, for streaming and storing.
+func (s *Server) redactSummary(summary *scout.Summary) *scout.Summary {
+	if summary == nil {
+		return nil
+	}
+	redacted := *summary
+	redacted.Sources = slices.Clone(summary.Sources)
+	for i, src := range redacted.Sources {
+		errs := make([]error, len(src.Errors))
+		for j, err := range src.Errors {
+			errs[j] = errors.New(s.redact(err))
+		}
+		redacted.Sources[i].Errors = errs
+	}
+	return &redacted
+}
+
+// writeJSON writes v as the JSON response body.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// ErrorResponse is the body of unsuccessful responses.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, ErrorResponse{Error: msg})
+}
+
+// clientKey is the request context key of the authenticated client.
+type clientKey struct{}
+
+func withClient(ctx context.Context, c *client) context.Context {
+	return context.WithValue(ctx, clientKey{}, c)
+}
+
+func clientFrom(ctx context.Context) *client {
+	c, _ := ctx.Value(clientKey{}).(*client)
+	return c
+}
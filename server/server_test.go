@@ -0,0 +1,339 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-appsec/scout"
+	"github.com/go-appsec/scout/sources"
+)
+
+// mockSource yields a subdomain and a URL for the queried domain, recording the API key it received.
+func mockSource(name string, keys chan<- string) sources.Source {
+	return sources.Source{
+		Name:         name,
+		Yields:       sources.Subdomain | sources.URL,
+		AuthRequired: keys != nil,
+		Run: func(_ context.Context, _ *http.Client, domain string, apiKey string) iter.Seq2[sources.Result, error] {
+			if keys != nil {
+				keys <- apiKey
+			}
+			return func(yield func(sources.Result, error) bool) {
+				if !yield(sources.Result{Type: sources.Subdomain, Value: name + "." + domain, Source: name}, nil) {
+					return
+				}
+				yield(sources.Result{Type: sources.URL, Value: "https://" + domain + "/" + name, Source: name}, nil)
+			}
+		},
+	}
+}
+
+// blockingSource signals when started and stops once its context is done.
+func blockingSource(started chan<- struct{}) sources.Source {
+	return sources.Source{
+		Name:   "blocking",
+		Yields: sources.Subdomain,
+		Run: func(ctx context.Context, _ *http.Client, _ string, _ string) iter.Seq2[sources.Result, error] {
+			return func(func(sources.Result, error) bool) {
+				started <- struct{}{}
+				<-ctx.Done()
+			}
+		},
+	}
+}
+
+func newTestServer(t *testing.T, opts ...Option) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(New(opts...))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func doRequest(t *testing.T, method, url, token, body string, header ...string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(t.Context(), method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
+
+// readEvents decodes an NDJSON event stream.
+func readEvents(t *testing.T, r io.Reader) []Event {
+	t.Helper()
+
+	var events []Event
+	dec := json.NewDecoder(r)
+	for dec.More() {
+		var ev Event
+		require.NoError(t, dec.Decode(&ev))
+		events = append(events, ev)
+	}
+	return events
+}
+
+func TestSources(t *testing.T) {
+	t.Parallel()
+
+	zeta := mockSource("zeta", nil)
+	zeta.KeyOptional = true
+	zeta.KeyFormat = "key"
+	zeta.RateLimit = 2
+	zeta.KeyedRateLimit = 5
+	zeta.Burst = 3
+	zeta.Group = "greek"
+	zeta.Quota = sources.Quota{Requests: 4, Period: time.Minute}
+	zeta.Homepage = "https://zeta.example.net"
+	zeta.Collection = sources.CollectionScraping
+	zeta.UserAgent = sources.UserAgentBrowser
+	server := newTestServer(t, WithQueryOptions(scout.WithSources([]sources.Source{
+		zeta, mockSource("alpha", make(chan string, 1)),
+	})))
+
+	resp := doRequest(t, http.MethodGet, server.URL+"/v1/sources", "", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var infos []SourceInfo
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&infos))
+	assert.Equal(t, []SourceInfo{
+		{Name: "alpha", Yields: []string{"subdomain", "url"}, Group: "alpha", AuthRequired: true, Burst: 1, Collection: "api", UserAgent: "default"},
+		{Name: "zeta", Yields: []string{"subdomain", "url"}, Group: "greek", KeyOptional: true, KeyFormat: "key", RateLimit: 2, KeyedRate: 5, Burst: 3,
+			Quota: "4/min", Homepage: "https://zeta.example.net", Collection: "scraping", UserAgent: "browser"},
+	}, infos)
+}
+
+func TestQuery(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ndjson", func(t *testing.T) {
+		server := newTestServer(t, WithQueryOptions(scout.WithSources([]sources.Source{mockSource("mock", nil)})))
+
+		resp := doRequest(t, http.MethodPost, server.URL+"/v1/query", "", `{"domain":"example.com"}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+
+		events := readEvents(t, resp.Body)
+		require.Len(t, events, 4)
+		assert.Equal(t, EventStart, events[0].Event)
+		assert.Equal(t, resp.Header.Get("X-Scout-Run-ID"), events[0].RunID)
+		assert.Equal(t, EventResult, events[1].Event)
+		assert.Equal(t, "mock.example.com", events[1].Result.Value)
+		assert.Equal(t, "https://example.com/mock", events[2].Result.Value)
+		assert.Equal(t, EventSummary, events[3].Event)
+		require.NotNil(t, events[3].Summary)
+		assert.EqualValues(t, 2, events[3].Summary.Results)
+	})
+
+	t.Run("sse", func(t *testing.T) {
+		server := newTestServer(t, WithQueryOptions(scout.WithSources([]sources.Source{mockSource("mock", nil)})))
+
+		resp := doRequest(t, http.MethodPost, server.URL+"/v1/query", "", `{"domain":"example.com","types":["url"]}`,
+			"Accept", "text/event-stream")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+		var names []string
+		var results []string
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if name, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+				names = append(names, name)
+			} else if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				var ev Event
+				require.NoError(t, json.Unmarshal([]byte(data), &ev))
+				if ev.Result != nil {
+					results = append(results, ev.Result.Value)
+				}
+			}
+		}
+		assert.Equal(t, []string{EventStart, EventResult, EventSummary}, names)
+		assert.Equal(t, []string{"https://example.com/mock"}, results)
+	})
+
+	t.Run("server_api_keys", func(t *testing.T) {
+		keys := make(chan string, 1)
+		server := newTestServer(t, WithQueryOptions(
+			scout.WithSources([]sources.Source{mockSource("keyed", keys), mockSource("other", nil)}),
+			scout.WithAPIKey("keyed", "provider-key"),
+		))
+
+		resp := doRequest(t, http.MethodPost, server.URL+"/v1/query", "", `{"domain":"example.com","sources":["keyed"]}`)
+		events := readEvents(t, resp.Body)
+
+		assert.Equal(t, "provider-key", <-keys)
+		for _, ev := range events {
+			if ev.Result != nil {
+				assert.Equal(t, "keyed", ev.Result.Source)
+			}
+		}
+	})
+
+	t.Run("redacts_api_keys", func(t *testing.T) {
+		failing := sources.Source{
+			Name:         "failing",
+			Yields:       sources.Subdomain,
+			AuthRequired: true,
+			Run: func(_ context.Context, _ *http.Client, domain string, apiKey string) iter.Seq2[sources.Result, error] {
+				return func(yield func(sources.Result, error) bool) {
+					err := &url.Error{Op: "Get", URL: "https://api.example.net/search?q=" + domain + "&apikey=" + apiKey,
+						Err: errors.New("connection refused")}
+					yield(sources.Result{}, fmt.Errorf("failing: %w", err))
+				}
+			},
+		}
+		server := newTestServer(t, WithQueryOptions(scout.WithSources([]sources.Source{failing}),
+			scout.WithAPIKey("failing", "provider-key")))
+
+		resp := doRequest(t, http.MethodPost, server.URL+"/v1/query", "", `{"domain":"example.com"}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		events := readEvents(t, resp.Body)
+		require.Len(t, events, 3)
+		assert.Equal(t, EventError, events[1].Event)
+		assert.Contains(t, events[1].Error, "apikey=REDACTED")
+		assert.NotContains(t, events[1].Error, "provider-key")
+		require.NotNil(t, events[2].Summary)
+		require.Len(t, events[2].Summary.Sources, 1)
+		require.Len(t, events[2].Summary.Sources[0].Errors, 1)
+		assert.NotContains(t, events[2].Summary.Sources[0].Errors[0].Error(), "provider-key")
+
+		run := doRequest(t, http.MethodGet, server.URL+"/v1/runs/"+resp.Header.Get("X-Scout-Run-ID"), "", "")
+		require.Equal(t, http.StatusOK, run.StatusCode)
+		body, err := io.ReadAll(run.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), "apikey=REDACTED")
+		assert.NotContains(t, string(body), "provider-key")
+	})
+
+	t.Run("bad_requests", func(t *testing.T) {
+		server := newTestServer(t, WithQueryOptions(scout.WithSources([]sources.Source{mockSource("mock", nil)})))
+
+		tests := []struct {
+			name string
+			body string
+			want string
+		}{
+			{name: "invalid_json", body: `{`, want: "invalid request body"},
+			{name: "unknown_field", body: `{"domain":"example.com","depth":3}`, want: "unknown field"},
+			{name: "missing_domain", body: `{}`, want: "domain is required"},
+			{name: "domain_with_path", body: `{"domain":"x.com/../../other?"}`, want: "invalid domain"},
+			{name: "domain_with_port", body: `{"domain":"example.com:8080"}`, want: "invalid domain"},
+			{name: "unknown_source", body: `{"domain":"example.com","sources":["nope"]}`, want: `unknown source "nope"`},
+			{name: "unknown_type", body: `{"domain":"example.com","types":["ip"]}`, want: `unknown result type "ip"`},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				resp := doRequest(t, http.MethodPost, server.URL+"/v1/query", "", tt.body)
+				require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+				var body ErrorResponse
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+				assert.Contains(t, body.Error, tt.want)
+			})
+		}
+	})
+}
+
+func TestAuthentication(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t,
+		WithClient(Client{Name: "ci", Token: "ci-token"}),
+		WithClient(Client{Name: "dashboard", Token: "dash-token"}),
+		WithQueryOptions(scout.WithSources([]sources.Source{mockSource("mock", nil)})),
+	)
+
+	t.Run("missing_token", func(t *testing.T) {
+		resp := doRequest(t, http.MethodGet, server.URL+"/v1/sources", "", "")
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.NotEmpty(t, resp.Header.Get("WWW-Authenticate"))
+	})
+
+	t.Run("wrong_token", func(t *testing.T) {
+		resp := doRequest(t, http.MethodGet, server.URL+"/v1/sources", "ci-token-x", "")
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("runs_per_client", func(t *testing.T) {
+		resp := doRequest(t, http.MethodPost, server.URL+"/v1/query", "ci-token", `{"domain":"example.com"}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		readEvents(t, resp.Body)
+		id := resp.Header.Get("X-Scout-Run-ID")
+
+		resp = doRequest(t, http.MethodGet, server.URL+"/v1/runs/"+id, "ci-token", "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var run Run
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&run))
+		assert.Equal(t, id, run.ID)
+		assert.Equal(t, "ci", run.Client)
+		assert.Equal(t, StatusCompleted, run.Status)
+		require.NotNil(t, run.Summary)
+		assert.Equal(t, "example.com", run.Summary.Domain)
+
+		resp = doRequest(t, http.MethodGet, server.URL+"/v1/runs/"+id, "dash-token", "")
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		resp = doRequest(t, http.MethodGet, server.URL+"/v1/runs", "dash-token", "")
+		var runs []Run
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&runs))
+		assert.Empty(t, runs)
+	})
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{}, 1)
+	server := newTestServer(t,
+		WithClient(Client{Name: "ci", Token: "ci-token", Concurrency: 1}),
+		WithClient(Client{Name: "other", Token: "other-token", Concurrency: 1}),
+		WithQueryOptions(scout.WithSources([]sources.Source{blockingSource(started)})),
+	)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/v1/query", strings.NewReader(`{"domain":"example.com"}`))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer ci-token")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	<-started
+
+	limited := doRequest(t, http.MethodPost, server.URL+"/v1/query", "ci-token", `{"domain":"example.com"}`)
+	assert.Equal(t, http.StatusTooManyRequests, limited.StatusCode)
+	assert.Equal(t, "1", limited.Header.Get("Retry-After"))
+
+	other := doRequest(t, http.MethodPost, server.URL+"/v1/query", "other-token", `{"domain":"example.com"}`)
+	assert.Equal(t, http.StatusOK, other.StatusCode)
+	<-started
+	_ = other.Body.Close()
+
+	cancel()
+	assert.Eventually(t, func() bool {
+		resp := doRequest(t, http.MethodGet, server.URL+"/v1/runs", "ci-token", "")
+		var runs []Run
+		return json.NewDecoder(resp.Body).Decode(&runs) == nil && len(runs) == 1 && runs[0].Status == StatusCancelled
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-appsec/scout"
+	"github.com/go-appsec/scout/output"
+)
+
+// Event names of a query stream.
+const (
+	EventStart   = "start"   // First event, carrying the run ID
+	EventResult  = "result"  // A result yielded by the query
+	EventError   = "error"   // A source error, the query continues
+	EventSummary = "summary" // Last event, carrying the run summary
+)
+
+// Event is a single message of a query stream. Over NDJSON each event is a line,
+// over SSE the event name is also set as the SSE event type and the data is the JSON encoded Event.
+type Event struct {
+	Event   string         `json:"event"`
+	RunID   string         `json:"run_id,omitempty"`
+	Result  *output.Record `json:"result,omitempty"`
+	Error   string         `json:"error,omitempty"`
+	Summary *scout.Summary `json:"summary,omitempty"`
+}
+
+// eventWriter writes events to a response, flushing each so clients receive results as they are found.
+type eventWriter struct {
+	w   http.ResponseWriter
+	rc  *http.ResponseController
+	sse bool
+}
+
+// newEventWriter selects SSE when the request accepts text/event-stream or sets stream=sse, NDJSON otherwise.
+func newEventWriter(w http.ResponseWriter, r *http.Request) *eventWriter {
+	sse := r.URL.Query().Get("stream") == "sse" || strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	return &eventWriter{w: w, rc: http.NewResponseController(w), sse: sse}
+}
+
+func (e *eventWriter) write(ev Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	var buf []byte
+	if e.sse {
+		buf = append(buf, "event: "+ev.Event+"\ndata: "...)
+		buf = append(buf, data...)
+		buf = append(buf, "\n\n"...)
+	} else {
+		buf = append(data, '\n')
+	}
+	if _, err := e.w.Write(buf); err != nil {
+		return err
+	}
+	return e.rc.Flush()
+}
@@ -14,9 +14,13 @@ func init() {
 
 // AlienVault queries the AlienVault OTX URL list endpoint.
 var AlienVault = Source{
-	Name:   "alienvault",
-	Yields: Subdomain | URL,
-	Run:    runAlienVault,
+	Name:       "alienvault",
+	Yields:     Subdomain | URL,
+	Group:      "alienvault",
+	RateLimit:  1,
+	Homepage:   "https://otx.alienvault.com",
+	Collection: CollectionAPI,
+	Run:        runAlienVault,
 }
 
 func runAlienVault(ctx context.Context, client *http.Client, domain string, _ string) iter.Seq2[Result, error] {
@@ -27,6 +31,8 @@ func runAlienVault(ctx context.Context, client *http.Client, domain string, _ st
 			return
 		}
 
+		log := Logger(ctx)
+		var count int
 		page := 1
 		for {
 			if ctx.Err() != nil {
@@ -47,6 +53,7 @@ func runAlienVault(ctx context.Context, client *http.Client, domain string, _ st
 				return
 			}
 
+			log.DebugContext(ctx, "response received", "url", url, "page", page, "status", resp.StatusCode)
 			if resp.StatusCode != http.StatusOK {
 				_ = resp.Body.Close()
 				yield(Result{}, fmt.Errorf("alienvault: unexpected status %d", resp.StatusCode))
@@ -76,17 +83,20 @@ func runAlienVault(ctx context.Context, client *http.Client, domain string, _ st
 				if !yield(Result{Type: URL, Value: u.URL, Source: "alienvault"}, nil) {
 					return
 				}
+				count++
 
 				// Extract and yield subdomain
 				for _, sub := range extractor.Extract(u.URL) {
 					if !yield(Result{Type: Subdomain, Value: sub, Source: "alienvault"}, nil) {
 						return
 					}
+					count++
 				}
 			}
 
 			// Check for more pages
 			if !response.HasNext {
+				log.DebugContext(ctx, "query complete", "pages", page, "results", count)
 				return
 			}
 			page++
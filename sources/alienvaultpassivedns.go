@@ -7,4 +7,5 @@ package sources
 //   Method:   GET
 //   Auth:     Bearer token
 //   Yields:   Subdomain
+//   Group:    alienvault (shares the OTX account quota with alienvault.go)
 //   Notes:    Different from alienvault.go which uses the URL list endpoint (no auth required)
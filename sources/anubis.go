@@ -14,13 +14,18 @@ func init() {
 
 // Anubis queries the Anubis API for subdomains.
 var Anubis = Source{
-	Name:   "anubis",
-	Yields: Subdomain,
-	Run:    runAnubis,
+	Name:       "anubis",
+	Yields:     Subdomain,
+	Recursive:  true,
+	RateLimit:  1,
+	Homepage:   "https://jonlu.ca/anubis",
+	Collection: CollectionAPI,
+	Run:        runAnubis,
 }
 
 func runAnubis(ctx context.Context, client *http.Client, domain string, _ string) iter.Seq2[Result, error] {
 	return func(yield func(Result, error) bool) {
+		log := Logger(ctx)
 		url := "https://jonlu.ca/anubis/subdomains/" + domain
 
 		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
@@ -35,6 +40,7 @@ func runAnubis(ctx context.Context, client *http.Client, domain string, _ string
 			return
 		}
 		defer func() { _ = resp.Body.Close() }()
+		log.DebugContext(ctx, "response received", "url", url, "status", resp.StatusCode)
 
 		if resp.StatusCode != http.StatusOK {
 			yield(Result{}, fmt.Errorf("anubis: unexpected status %d", resp.StatusCode))
@@ -52,5 +58,6 @@ func runAnubis(ctx context.Context, client *http.Client, domain string, _ string
 				return
 			}
 		}
+		log.DebugContext(ctx, "query complete", "results", len(subdomains))
 	}
 }
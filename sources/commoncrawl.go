@@ -19,9 +19,14 @@ func init() {
 
 // CommonCrawl queries the Common Crawl index for URLs and subdomains.
 var CommonCrawl = Source{
-	Name:   "commoncrawl",
-	Yields: Subdomain | URL,
-	Run:    runCommonCrawl,
+	Name:       "commoncrawl",
+	Yields:     Subdomain | URL,
+	RateLimit:  1,
+	Burst:      2,
+	Quota:      Quota{Note: "shared index servers, slow down on 503 responses"},
+	Homepage:   "https://index.commoncrawl.org",
+	Collection: CollectionAPI,
+	Run:        runCommonCrawl,
 }
 
 func runCommonCrawl(ctx context.Context, client *http.Client, domain string, _ string) iter.Seq2[Result, error] {
@@ -42,6 +47,8 @@ func runCommonCrawl(ctx context.Context, client *http.Client, domain string, _ s
 			}
 		}
 
+		log := Logger(ctx)
+		log.DebugContext(ctx, "indexes selected", "total", len(indexes), "recent", len(recentIndexes))
 		if len(recentIndexes) == 0 {
 			return
 		}
@@ -53,6 +60,7 @@ func runCommonCrawl(ctx context.Context, client *http.Client, domain string, _ s
 		}
 
 		// Query each index
+		var count int
 		for _, idx := range recentIndexes {
 			if ctx.Err() != nil {
 				return
@@ -75,8 +83,10 @@ func runCommonCrawl(ctx context.Context, client *http.Client, domain string, _ s
 			if resp.StatusCode != http.StatusOK {
 				_ = resp.Body.Close()
 				// Skip this index but continue with others
+				log.DebugContext(ctx, "skipping index", "index", idx.id, "url", endpoint, "status", resp.StatusCode)
 				continue
 			}
+			indexCount := count
 
 			scanner := bufio.NewScanner(resp.Body)
 			for scanner.Scan() {
@@ -94,6 +104,7 @@ func runCommonCrawl(ctx context.Context, client *http.Client, domain string, _ s
 					_ = resp.Body.Close()
 					return
 				}
+				count++
 
 				// Extract and yield subdomain
 				for _, sub := range extractor.Extract(decoded) {
@@ -101,6 +112,7 @@ func runCommonCrawl(ctx context.Context, client *http.Client, domain string, _ s
 						_ = resp.Body.Close()
 						return
 					}
+					count++
 				}
 			}
 
@@ -110,7 +122,9 @@ func runCommonCrawl(ctx context.Context, client *http.Client, domain string, _ s
 				yield(Result{}, fmt.Errorf("commoncrawl: %w", err))
 				return
 			}
+			log.DebugContext(ctx, "index complete", "index", idx.id, "results", count-indexCount)
 		}
+		log.DebugContext(ctx, "query complete", "indexes", len(recentIndexes), "results", count)
 	}
 }
 
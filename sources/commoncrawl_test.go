@@ -1,6 +1,9 @@
 package sources
 
 import (
+	"bytes"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"testing"
 	"time"
@@ -18,6 +21,32 @@ func TestCommonCrawl(t *testing.T) {
 		assert.Equal(t, Subdomain|URL, src.Yields)
 	})
 
+	t.Run("logs_skipped_index", func(t *testing.T) {
+		year := time.Now().Year()
+		client := localClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/collinfo.json":
+				_, _ = fmt.Fprintf(w, `[{"id":"CC-MAIN-%d-10","cdx-api":"https://index.commoncrawl.org/good"},`+
+					`{"id":"CC-MAIN-%d-05","cdx-api":"https://index.commoncrawl.org/bad"}]`, year, year)
+			case "/good":
+				_, _ = fmt.Fprintln(w, "https://api.example.com/path")
+			default:
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		var logs bytes.Buffer
+		ctx := WithLogger(t.Context(), slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+		subdomains, urls, errs := collectResults(CommonCrawl.Run(ctx, client, "example.com", ""))
+
+		assert.Empty(t, errs)
+		assert.Len(t, subdomains, 1)
+		assert.Len(t, urls, 1)
+		assert.Contains(t, logs.String(), fmt.Sprintf("skipping index\" index=CC-MAIN-%d-05", year))
+		assert.Contains(t, logs.String(), "status=503")
+		assert.Contains(t, logs.String(), "msg=\"query complete\" indexes=2 results=2")
+	})
+
 	t.Run("integration", func(t *testing.T) {
 		if testing.Short() {
 			t.Skip("skipping integration test")
@@ -15,13 +15,19 @@ func init() {
 
 // CrtSh queries the crt.sh certificate transparency database.
 var CrtSh = Source{
-	Name:   "crtsh",
-	Yields: Subdomain,
-	Run:    runCrtSh,
+	Name:       "crtsh",
+	Yields:     Subdomain,
+	Recursive:  true,
+	RateLimit:  0.5,
+	Quota:      Quota{Note: "shared public database, frequent requests are throttled"},
+	Homepage:   "https://crt.sh",
+	Collection: CollectionAPI,
+	Run:        runCrtSh,
 }
 
 func runCrtSh(ctx context.Context, client *http.Client, domain string, _ string) iter.Seq2[Result, error] {
 	return func(yield func(Result, error) bool) {
+		log := Logger(ctx)
 		url := fmt.Sprintf("https://crt.sh/?q=%%25.%s&output=json", domain)
 
 		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
@@ -36,6 +42,7 @@ func runCrtSh(ctx context.Context, client *http.Client, domain string, _ string)
 			return
 		}
 		defer func() { _ = resp.Body.Close() }()
+		log.DebugContext(ctx, "response received", "url", url, "status", resp.StatusCode)
 
 		if resp.StatusCode != http.StatusOK {
 			yield(Result{}, fmt.Errorf("crtsh: unexpected status %d", resp.StatusCode))
@@ -56,6 +63,7 @@ func runCrtSh(ctx context.Context, client *http.Client, domain string, _ string)
 			return
 		}
 
+		var count int
 		for _, record := range records {
 			// name_value may contain multiple subdomains separated by newlines
 			for _, line := range strings.Split(record.NameValue, "\n") {
@@ -67,8 +75,10 @@ func runCrtSh(ctx context.Context, client *http.Client, domain string, _ string)
 					if !yield(Result{Type: Subdomain, Value: sub, Source: "crtsh"}, nil) {
 						return
 					}
+					count++
 				}
 			}
 		}
+		log.DebugContext(ctx, "query complete", "records", len(records), "results", count)
 	}
 }
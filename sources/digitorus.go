@@ -15,13 +15,18 @@ func init() {
 
 // Digitorus queries the CertificateDetails website for subdomains.
 var Digitorus = Source{
-	Name:   "digitorus",
-	Yields: Subdomain,
-	Run:    runDigitorus,
+	Name:       "digitorus",
+	Yields:     Subdomain,
+	RateLimit:  1,
+	Homepage:   "https://certificatedetails.com",
+	Collection: CollectionScraping,
+	UserAgent:  UserAgentBrowser,
+	Run:        runDigitorus,
 }
 
 func runDigitorus(ctx context.Context, client *http.Client, domain string, _ string) iter.Seq2[Result, error] {
 	return func(yield func(Result, error) bool) {
+		log := Logger(ctx)
 		url := "https://certificatedetails.com/" + domain
 
 		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
@@ -36,6 +41,7 @@ func runDigitorus(ctx context.Context, client *http.Client, domain string, _ str
 			return
 		}
 		defer func() { _ = resp.Body.Close() }()
+		log.DebugContext(ctx, "response received", "url", url, "status", resp.StatusCode)
 
 		// 404 pages still contain subdomains, treat as success
 		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
@@ -49,6 +55,7 @@ func runDigitorus(ctx context.Context, client *http.Client, domain string, _ str
 			return
 		}
 
+		var count int
 		scanner := bufio.NewScanner(resp.Body)
 		for scanner.Scan() {
 			line := strings.TrimSpace(scanner.Text())
@@ -61,11 +68,14 @@ func runDigitorus(ctx context.Context, client *http.Client, domain string, _ str
 				if !yield(Result{Type: Subdomain, Value: sub, Source: "digitorus"}, nil) {
 					return
 				}
+				count++
 			}
 		}
 
 		if err := scanner.Err(); err != nil {
 			yield(Result{}, fmt.Errorf("digitorus: %w", err))
+			return
 		}
+		log.DebugContext(ctx, "query complete", "results", count)
 	}
 }
@@ -7,6 +7,7 @@ import (
 	"iter"
 	"net/http"
 	"strings"
+	"time"
 )
 
 func init() {
@@ -16,13 +17,21 @@ func init() {
 // HackerTarget queries the HackerTarget API for subdomains.
 // Works without API key but has rate limits; key improves limits.
 var HackerTarget = Source{
-	Name:   "hackertarget",
-	Yields: Subdomain,
-	Run:    runHackerTarget,
+	Name:           "hackertarget",
+	Yields:         Subdomain,
+	RateLimit:      1,
+	KeyedRateLimit: 2,
+	KeyOptional:    true,
+	KeyFormat:      "key",
+	Quota:          Quota{Requests: 50, Period: 24 * time.Hour, Note: "without an API key, membership raises the limit"},
+	Homepage:       "https://hackertarget.com",
+	Collection:     CollectionAPI,
+	Run:            runHackerTarget,
 }
 
 func runHackerTarget(ctx context.Context, client *http.Client, domain string, apiKey string) iter.Seq2[Result, error] {
 	return func(yield func(Result, error) bool) {
+		log := Logger(ctx)
 		url := "https://api.hackertarget.com/hostsearch/?q=" + domain
 		if apiKey != "" {
 			url += "&apikey=" + apiKey
@@ -40,6 +49,7 @@ func runHackerTarget(ctx context.Context, client *http.Client, domain string, ap
 			return
 		}
 		defer func() { _ = resp.Body.Close() }()
+		log.DebugContext(ctx, "response received", "url", RedactURL(url), "status", resp.StatusCode)
 
 		if resp.StatusCode != http.StatusOK {
 			yield(Result{}, fmt.Errorf("hackertarget: unexpected status %d", resp.StatusCode))
@@ -53,6 +63,7 @@ func runHackerTarget(ctx context.Context, client *http.Client, domain string, ap
 		}
 
 		// Response is CSV-like: subdomain,ip per line
+		var count int
 		scanner := bufio.NewScanner(resp.Body)
 		for scanner.Scan() {
 			line := strings.TrimSpace(scanner.Text())
@@ -65,11 +76,14 @@ func runHackerTarget(ctx context.Context, client *http.Client, domain string, ap
 				if !yield(Result{Type: Subdomain, Value: sub, Source: "hackertarget"}, nil) {
 					return
 				}
+				count++
 			}
 		}
 
 		if err := scanner.Err(); err != nil {
 			yield(Result{}, fmt.Errorf("hackertarget: %w", err))
+			return
 		}
+		log.DebugContext(ctx, "query complete", "results", count)
 	}
 }
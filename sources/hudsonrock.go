@@ -15,13 +15,17 @@ func init() {
 
 // HudsonRock queries the HudsonRock API for breach data URLs.
 var HudsonRock = Source{
-	Name:   "hudsonrock",
-	Yields: Subdomain | URL,
-	Run:    runHudsonRock,
+	Name:       "hudsonrock",
+	Yields:     Subdomain | URL,
+	RateLimit:  1,
+	Homepage:   "https://www.hudsonrock.com",
+	Collection: CollectionAPI,
+	Run:        runHudsonRock,
 }
 
 func runHudsonRock(ctx context.Context, client *http.Client, domain string, _ string) iter.Seq2[Result, error] {
 	return func(yield func(Result, error) bool) {
+		log := Logger(ctx)
 		endpoint := "https://cavalier.hudsonrock.com/api/json/v2/osint-tools/urls-by-domain?domain=" + domain
 
 		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
@@ -36,6 +40,7 @@ func runHudsonRock(ctx context.Context, client *http.Client, domain string, _ st
 			return
 		}
 		defer func() { _ = resp.Body.Close() }()
+		log.DebugContext(ctx, "response received", "url", endpoint, "status", resp.StatusCode)
 
 		if resp.StatusCode != http.StatusOK {
 			yield(Result{}, fmt.Errorf("hudsonrock: unexpected status %d", resp.StatusCode))
@@ -72,6 +77,7 @@ func runHudsonRock(ctx context.Context, client *http.Client, domain string, _ st
 			allURLs = append(allURLs, u.URL)
 		}
 
+		var count int
 		for _, u := range allURLs {
 			u = strings.TrimSpace(u)
 			if u == "" {
@@ -82,13 +88,16 @@ func runHudsonRock(ctx context.Context, client *http.Client, domain string, _ st
 			if !yield(Result{Type: URL, Value: u, Source: "hudsonrock"}, nil) {
 				return
 			}
+			count++
 
 			// Extract and yield subdomain
 			for _, sub := range extractor.Extract(u) {
 				if !yield(Result{Type: Subdomain, Value: sub, Source: "hudsonrock"}, nil) {
 					return
 				}
+				count++
 			}
 		}
+		log.DebugContext(ctx, "query complete", "urls", len(allURLs), "results", count)
 	}
 }
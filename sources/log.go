@@ -0,0 +1,59 @@
+package sources
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+// loggerContextKey is the context key holding the logger for a source run.
+type loggerContextKey struct{}
+
+// discardLogger is returned when no logger is set on the context.
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// WithLogger returns a context carrying the logger for sources to use.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, logger)
+}
+
+// Logger returns the logger carried by the context, or a logger which discards all records.
+func Logger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerContextKey{}).(*slog.Logger); ok && logger != nil {
+		return logger
+	}
+	return discardLogger
+}
+
+// secretParams are query parameter names whose values are redacted, compared case-insensitively.
+var secretParams = []string{"apikey", "api_key", "key", "token", "access_token", "auth", "secret", "password"}
+
+// RedactURL returns the URL with credentials removed so it can be safely logged.
+// Userinfo passwords and query parameters which commonly hold API keys are replaced.
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "REDACTED")
+	}
+	if u.RawQuery != "" {
+		query := u.Query()
+		var redacted bool
+		for name := range query {
+			for _, secret := range secretParams {
+				if strings.EqualFold(name, secret) {
+					query.Set(name, "REDACTED")
+					redacted = true
+					break
+				}
+			}
+		}
+		if redacted {
+			u.RawQuery = query.Encode()
+		}
+	}
+	return u.String()
+}
@@ -16,9 +16,13 @@ func init() {
 
 // RapidDNS queries the RapidDNS website for subdomains.
 var RapidDNS = Source{
-	Name:   "rapiddns",
-	Yields: Subdomain,
-	Run:    runRapidDNS,
+	Name:       "rapiddns",
+	Yields:     Subdomain,
+	RateLimit:  0.5,
+	Homepage:   "https://rapiddns.io",
+	Collection: CollectionScraping,
+	UserAgent:  UserAgentBrowser,
+	Run:        runRapidDNS,
 }
 
 var rapidDNSPagePattern = regexp.MustCompile(`class="page-link"\s+href="/subdomain/[^?]+\?page=(\d+)"`)
@@ -37,12 +41,16 @@ func runRapidDNS(ctx context.Context, client *http.Client, domain string, _ stri
 			yield(Result{}, fmt.Errorf("rapiddns: %w", err))
 			return
 		}
+		log := Logger(ctx)
+		log.DebugContext(ctx, "pages discovered", "pages", maxPage)
 
 		// Extract subdomains from first page
+		var count int
 		for _, sub := range extractor.Extract(body) {
 			if !yield(Result{Type: Subdomain, Value: sub, Source: "rapiddns"}, nil) {
 				return
 			}
+			count++
 		}
 
 		// Fetch remaining pages
@@ -61,8 +69,10 @@ func runRapidDNS(ctx context.Context, client *http.Client, domain string, _ stri
 				if !yield(Result{Type: Subdomain, Value: sub, Source: "rapiddns"}, nil) {
 					return
 				}
+				count++
 			}
 		}
+		log.DebugContext(ctx, "query complete", "pages", maxPage, "results", count)
 	}
 }
 
@@ -79,6 +89,7 @@ func fetchRapidDNSPage(ctx context.Context, client *http.Client, domain string,
 		return "", 0, err
 	}
 	defer func() { _ = resp.Body.Close() }()
+	Logger(ctx).DebugContext(ctx, "response received", "url", url, "page", page, "status", resp.StatusCode)
 
 	if resp.StatusCode != http.StatusOK {
 		return "", 0, fmt.Errorf("unexpected status %d", resp.StatusCode)
@@ -15,13 +15,21 @@ func init() {
 // Reconeer queries the Reconeer API for subdomains.
 // Works without API key; key improves rate limits.
 var Reconeer = Source{
-	Name:   "reconeer",
-	Yields: Subdomain,
-	Run:    runReconeer,
+	Name:           "reconeer",
+	Yields:         Subdomain,
+	RateLimit:      1,
+	KeyedRateLimit: 2,
+	KeyOptional:    true,
+	KeyFormat:      "key",
+	Quota:          Quota{Note: "an API key raises the rate limit"},
+	Homepage:       "https://www.reconeer.com",
+	Collection:     CollectionAPI,
+	Run:            runReconeer,
 }
 
 func runReconeer(ctx context.Context, client *http.Client, domain string, apiKey string) iter.Seq2[Result, error] {
 	return func(yield func(Result, error) bool) {
+		log := Logger(ctx)
 		url := "https://www.reconeer.com/api/domain/" + domain
 
 		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
@@ -40,6 +48,7 @@ func runReconeer(ctx context.Context, client *http.Client, domain string, apiKey
 			return
 		}
 		defer func() { _ = resp.Body.Close() }()
+		log.DebugContext(ctx, "response received", "url", url, "status", resp.StatusCode)
 
 		if resp.StatusCode != http.StatusOK {
 			yield(Result{}, fmt.Errorf("reconeer: unexpected status %d", resp.StatusCode))
@@ -56,6 +65,7 @@ func runReconeer(ctx context.Context, client *http.Client, domain string, apiKey
 			return
 		}
 
+		var count int
 		for _, s := range response.Subdomains {
 			if s.Subdomain == "" {
 				continue
@@ -63,6 +73,8 @@ func runReconeer(ctx context.Context, client *http.Client, domain string, apiKey
 			if !yield(Result{Type: Subdomain, Value: s.Subdomain, Source: "reconeer"}, nil) {
 				return
 			}
+			count++
 		}
+		log.DebugContext(ctx, "query complete", "results", count)
 	}
 }
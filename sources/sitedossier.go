@@ -15,9 +15,15 @@ func init() {
 
 // SiteDossier queries the SiteDossier website for subdomains.
 var SiteDossier = Source{
-	Name:   "sitedossier",
-	Yields: Subdomain,
-	Run:    runSiteDossier,
+	Name:       "sitedossier",
+	Yields:     Subdomain,
+	Recursive:  true,
+	RateLimit:  0.5,
+	Quota:      Quota{Note: "rapid requests are blocked with a captcha"},
+	Homepage:   "http://www.sitedossier.com",
+	Collection: CollectionScraping,
+	UserAgent:  UserAgentBrowser,
+	Run:        runSiteDossier,
 }
 
 var siteDossierNextPattern = regexp.MustCompile(`<a href="([A-Za-z0-9/.]+)"><b>`)
@@ -31,12 +37,15 @@ func runSiteDossier(ctx context.Context, client *http.Client, domain string, _ s
 		}
 
 		// Start with the initial URL
+		log := Logger(ctx)
+		var page, count int
 		currentURL := "http://www.sitedossier.com/parentdomain/" + domain
 
 		for currentURL != "" {
 			if ctx.Err() != nil {
 				return
 			}
+			page++
 
 			body, nextPath, err := fetchSiteDossierPage(ctx, client, currentURL)
 			if err != nil {
@@ -49,6 +58,7 @@ func runSiteDossier(ctx context.Context, client *http.Client, domain string, _ s
 				if !yield(Result{Type: Subdomain, Value: sub, Source: "sitedossier"}, nil) {
 					return
 				}
+				count++
 			}
 
 			// Follow next link if found
@@ -58,6 +68,7 @@ func runSiteDossier(ctx context.Context, client *http.Client, domain string, _ s
 				currentURL = ""
 			}
 		}
+		log.DebugContext(ctx, "query complete", "pages", page, "results", count)
 	}
 }
 
@@ -72,6 +83,7 @@ func fetchSiteDossierPage(ctx context.Context, client *http.Client, url string)
 		return "", "", err
 	}
 	defer func() { _ = resp.Body.Close() }()
+	Logger(ctx).DebugContext(ctx, "response received", "url", url, "status", resp.StatusCode)
 
 	if resp.StatusCode != http.StatusOK {
 		return "", "", fmt.Errorf("unexpected status %d", resp.StatusCode)
@@ -2,9 +2,12 @@ package sources
 
 import (
 	"context"
+	"fmt"
 	"iter"
 	"net/http"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/go-analyze/bulk"
 )
@@ -17,11 +20,141 @@ const (
 	URL                              // A full URL (e.g., https://example.com/path)
 )
 
+// String returns the lowercase name of the result type, such as "subdomain".
+func (t ResultType) String() string {
+	switch t {
+	case Subdomain:
+		return "subdomain"
+	case URL:
+		return "url"
+	default:
+		return "ResultType(" + strconv.Itoa(int(t)) + ")"
+	}
+}
+
+// ParseResultType returns the result type for a name returned by ResultType.String.
+func ParseResultType(name string) (ResultType, error) {
+	switch name {
+	case "subdomain":
+		return Subdomain, nil
+	case "url":
+		return URL, nil
+	default:
+		return 0, fmt.Errorf("unknown result type %q", name)
+	}
+}
+
+// Collection describes how a source obtains its data.
+// Both kinds are passive towards the queried domain, only the data provider is contacted.
+type Collection uint8
+
+const (
+	CollectionAPI      Collection = iota // Queries an API or dataset intended for programmatic use
+	CollectionScraping                   // Parses web pages intended for browsers, which may change or block automated use
+)
+
+// String returns the lowercase name of the collection, such as "api".
+func (c Collection) String() string {
+	switch c {
+	case CollectionAPI:
+		return "api"
+	case CollectionScraping:
+		return "scraping"
+	default:
+		return "Collection(" + strconv.Itoa(int(c)) + ")"
+	}
+}
+
+// ParseCollection returns the collection for a name returned by Collection.String.
+func ParseCollection(name string) (Collection, error) {
+	switch name {
+	case "api":
+		return CollectionAPI, nil
+	case "scraping":
+		return CollectionScraping, nil
+	default:
+		return 0, fmt.Errorf("unknown collection %q", name)
+	}
+}
+
+// UserAgentProfile is the kind of User-Agent header a source prefers its requests to carry.
+type UserAgentProfile uint8
+
+const (
+	UserAgentDefault UserAgentProfile = iota // Identifies scout, suited to APIs
+	UserAgentBrowser                         // Resembles a desktop browser, for sites which block other clients
+)
+
+// String returns the lowercase name of the profile, such as "browser".
+func (p UserAgentProfile) String() string {
+	switch p {
+	case UserAgentDefault:
+		return "default"
+	case UserAgentBrowser:
+		return "browser"
+	default:
+		return "UserAgentProfile(" + strconv.Itoa(int(p)) + ")"
+	}
+}
+
+// ParseUserAgentProfile returns the profile for a name returned by UserAgentProfile.String.
+func ParseUserAgentProfile(name string) (UserAgentProfile, error) {
+	switch name {
+	case "default":
+		return UserAgentDefault, nil
+	case "browser":
+		return UserAgentBrowser, nil
+	default:
+		return 0, fmt.Errorf("unknown user agent profile %q", name)
+	}
+}
+
+// Quota describes the usage a provider allows on its free tier. The zero value means no limit is documented.
+type Quota struct {
+	Requests int           // Requests allowed per Period
+	Period   time.Duration // Window the allowance applies to
+	Note     string        // Further terms, such as how an API key changes the allowance
+}
+
+// String formats the quota such as "50/day", followed by the note if set.
+func (q Quota) String() string {
+	var s string
+	if q.Requests > 0 && q.Period > 0 {
+		s = strconv.Itoa(q.Requests) + "/" + periodName(q.Period)
+	}
+	if q.Note != "" {
+		if s != "" {
+			s += ", "
+		}
+		s += q.Note
+	}
+	return s
+}
+
+// periodName returns a short name for common quota windows, or the duration string.
+func periodName(d time.Duration) string {
+	switch d {
+	case time.Second:
+		return "sec"
+	case time.Minute:
+		return "min"
+	case time.Hour:
+		return "hour"
+	case 24 * time.Hour:
+		return "day"
+	case 30 * 24 * time.Hour:
+		return "month"
+	default:
+		return d.String()
+	}
+}
+
 // Result represents a single discovery from a source.
 type Result struct {
-	Type   ResultType // What type of result this is
-	Value  string     // The subdomain or URL
-	Source string     // Which source produced this result
+	Type    ResultType // What type of result this is
+	Value   string     // The subdomain or URL
+	Source  string     // Which source produced this result
+	Parents []string   // Recursive query targets that led to this result, outermost first (empty for the queried domain)
 }
 
 // Source represents a reconnaissance data source.
@@ -37,11 +170,69 @@ type Source struct {
 	// Sources with AuthRequired=true are silently skipped when no API key is provided.
 	AuthRequired bool
 
+	// Group names the provider quota the source shares with other sources, such as "alienvault" for every OTX endpoint.
+	// Sources in a group share rate limiters, API keys, and circuit breakers, so together they stay within the quota.
+	// Empty means the source is its own group.
+	Group string
+
+	// Recursive indicates the source returns more results when queried directly for a deep subdomain.
+	// These sources are used by default when recursive enumeration is enabled.
+	Recursive bool
+
+	// RateLimit is the recommended requests per second to the provider without an API key,
+	// applied by Query unless overridden. Zero means no limit is recommended.
+	RateLimit float64
+
+	// KeyedRateLimit is the recommended requests per second when an API key is set. Zero means RateLimit applies.
+	KeyedRateLimit float64
+
+	// Burst is the number of requests allowed at once before the rate limit spaces them. Zero means 1.
+	Burst int
+
+	// KeyOptional indicates the source works without an API key, but a key raises limits or improves results.
+	KeyOptional bool
+
+	// KeyFormat describes the expected API key, such as "key" or "email:key". Empty if the source takes no key.
+	KeyFormat string
+
+	// Quota is the provider's free usage allowance.
+	Quota Quota
+
+	// Homepage is the provider's website, where documentation and API key signup can be found.
+	Homepage string
+
+	// Collection describes how the source obtains its data.
+	Collection Collection
+
+	// UserAgent is the preferred kind of User-Agent header, applied by Query unless a user agent is configured.
+	UserAgent UserAgentProfile
+
 	// Run executes the source query and yields results.
 	// The apiKey parameter is optional and used by sources that support authentication.
 	Run func(ctx context.Context, client *http.Client, domain string, apiKey string) iter.Seq2[Result, error]
 }
 
+// QuotaGroup returns Group, or Name if the source does not share a group.
+func (s Source) QuotaGroup() string {
+	if s.Group != "" {
+		return s.Group
+	}
+	return s.Name
+}
+
+// RecommendedRate returns the recommended requests per second, using KeyedRateLimit when keyed and it is set.
+func (s Source) RecommendedRate(keyed bool) float64 {
+	if keyed && s.KeyedRateLimit > 0 {
+		return s.KeyedRateLimit
+	}
+	return s.RateLimit
+}
+
+// RecommendedBurst returns Burst, or 1 if it is not set.
+func (s Source) RecommendedBurst() int {
+	return max(s.Burst, 1)
+}
+
 // Registry state protected by RWMutex for concurrent access.
 var (
 	registry   = make(map[string]Source)
@@ -4,8 +4,11 @@ import (
 	"context"
 	"iter"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"slices"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -39,6 +42,138 @@ func assertResults(t *testing.T, results []Result, source string, resultType Res
 	}
 }
 
+// localClient returns a client which sends every request to the handler, regardless of the requested host.
+// This allows running sources with hardcoded endpoints against canned responses.
+func localClient(t *testing.T, handler http.Handler) *http.Client {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	target, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	return &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.URL.Scheme = target.Scheme
+		req.URL.Host = target.Host
+		return server.Client().Transport.RoundTrip(req)
+	})}
+}
+
+// roundTripFunc adapts a function to http.RoundTripper.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestResultTypeString(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "subdomain", Subdomain.String())
+	assert.Equal(t, "url", URL.String())
+	assert.Equal(t, "ResultType(3)", (Subdomain | URL).String())
+}
+
+func TestParseResultType(t *testing.T) {
+	t.Parallel()
+
+	for _, want := range []ResultType{Subdomain, URL} {
+		got, err := ParseResultType(want.String())
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	_, err := ParseResultType("ip")
+	assert.Error(t, err)
+}
+
+func TestParseCollection(t *testing.T) {
+	t.Parallel()
+
+	for _, want := range []Collection{CollectionAPI, CollectionScraping} {
+		got, err := ParseCollection(want.String())
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	_, err := ParseCollection("crawl")
+	assert.Error(t, err)
+}
+
+func TestParseUserAgentProfile(t *testing.T) {
+	t.Parallel()
+
+	for _, want := range []UserAgentProfile{UserAgentDefault, UserAgentBrowser} {
+		got, err := ParseUserAgentProfile(want.String())
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	_, err := ParseUserAgentProfile("mobile")
+	assert.Error(t, err)
+}
+
+func TestQuotaString(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		quota Quota
+		want  string
+	}{
+		{name: "zero", quota: Quota{}, want: ""},
+		{name: "requests", quota: Quota{Requests: 4, Period: time.Minute}, want: "4/min"},
+		{name: "uncommon_period", quota: Quota{Requests: 10, Period: 90 * time.Second}, want: "10/1m30s"},
+		{name: "note_only", quota: Quota{Note: "fair use"}, want: "fair use"},
+		{name: "requests_and_note", quota: Quota{Requests: 50, Period: 24 * time.Hour, Note: "per IP"}, want: "50/day, per IP"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.quota.String())
+		})
+	}
+}
+
+func TestQuotaGroup(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "alienvault", Source{Name: "alienvault"}.QuotaGroup())
+	assert.Equal(t, "otx", Source{Name: "alienvaultpassivedns", Group: "otx"}.QuotaGroup())
+}
+
+func TestRecommendedRate(t *testing.T) {
+	t.Parallel()
+
+	s := Source{RateLimit: 1, KeyedRateLimit: 4}
+	assert.InDelta(t, 1, s.RecommendedRate(false), 0.001)
+	assert.InDelta(t, 4, s.RecommendedRate(true), 0.001)
+
+	s.KeyedRateLimit = 0
+	assert.InDelta(t, 1, s.RecommendedRate(true), 0.001)
+}
+
+func TestRecommendedBurst(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 1, Source{}.RecommendedBurst())
+	assert.Equal(t, 3, Source{Burst: 3}.RecommendedBurst())
+}
+
+func TestBuiltinMetadata(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range []Source{AlienVault, Anubis, CommonCrawl, CrtSh, Digitorus, HackerTarget, HudsonRock, RapidDNS, Reconeer, SiteDossier, THC} {
+		t.Run(s.Name, func(t *testing.T) {
+			assert.Positive(t, s.RateLimit)
+			assert.GreaterOrEqual(t, s.KeyedRateLimit, 0.0)
+			assert.False(t, s.KeyedRateLimit > 0 && !s.AuthRequired && !s.KeyOptional, "keyed rate without a key")
+			assert.NotEmpty(t, s.Homepage)
+			assert.Equal(t, s.AuthRequired || s.KeyOptional, s.KeyFormat != "")
+		})
+	}
+}
+
 func TestRegister(t *testing.T) {
 	t.Parallel()
 
@@ -15,9 +15,12 @@ func init() {
 
 // THC queries the THC API for subdomains.
 var THC = Source{
-	Name:   "thc",
-	Yields: Subdomain,
-	Run:    runTHC,
+	Name:       "thc",
+	Yields:     Subdomain,
+	RateLimit:  1,
+	Homepage:   "https://ip.thc.org",
+	Collection: CollectionAPI,
+	Run:        runTHC,
 }
 
 type thcRequest struct {
@@ -35,9 +38,12 @@ type thcResponse struct {
 
 func runTHC(ctx context.Context, client *http.Client, domain string, _ string) iter.Seq2[Result, error] {
 	return func(yield func(Result, error) bool) {
+		log := Logger(ctx)
+		var page, count int
 		pageState := ""
 
 		for {
+			page++
 			if ctx.Err() != nil {
 				return
 			}
@@ -67,6 +73,7 @@ func runTHC(ctx context.Context, client *http.Client, domain string, _ string) i
 				return
 			}
 
+			log.DebugContext(ctx, "response received", "url", req.URL.String(), "page", page, "status", resp.StatusCode)
 			if resp.StatusCode != http.StatusOK {
 				_ = resp.Body.Close()
 				yield(Result{}, fmt.Errorf("thc: unexpected status %d", resp.StatusCode))
@@ -89,10 +96,12 @@ func runTHC(ctx context.Context, client *http.Client, domain string, _ string) i
 				if !yield(Result{Type: Subdomain, Value: d.Domain, Source: "thc"}, nil) {
 					return
 				}
+				count++
 			}
 
 			// Check for more pages
 			if response.NextPageState == "" {
+				log.DebugContext(ctx, "query complete", "pages", page, "results", count)
 				return
 			}
 			pageState = response.NextPageState
@@ -8,4 +8,5 @@ package sources
 //   Method:             GET
 //   Auth:               x-apikey header (v3) or query param (v2)
 //   Yields:             Subdomain, URL
+//   Group:              virustotal (v2 and v3 share the key's quota, including if split into separate sources)
 //   Notes:              Cursor-based pagination for v3
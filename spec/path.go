@@ -0,0 +1,81 @@
+package spec
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// splitPath splits a dot separated path, an empty path selects the document itself.
+func splitPath(path string) []string {
+	if path == "" || path == "." {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+// lookup returns the values at path within a decoded JSON document.
+// A * element matches every array element or object value, numeric elements index arrays.
+func lookup(v any, path []string) []any {
+	if len(path) == 0 {
+		return []any{v}
+	}
+	key, rest := path[0], path[1:]
+	switch node := v.(type) {
+	case map[string]any:
+		if key == "*" {
+			var out []any
+			for _, child := range node {
+				out = append(out, lookup(child, rest)...)
+			}
+			return out
+		}
+		if child, ok := node[key]; ok {
+			return lookup(child, rest)
+		}
+	case []any:
+		if key == "*" {
+			var out []any
+			for _, child := range node {
+				out = append(out, lookup(child, rest)...)
+			}
+			return out
+		}
+		if i, err := strconv.Atoi(key); err == nil && i >= 0 && i < len(node) {
+			return lookup(node[i], rest)
+		}
+	}
+	return nil
+}
+
+// lookupStrings returns the string and number values at path, other values are skipped.
+func lookupStrings(v any, path []string) []string {
+	var out []string
+	for _, value := range lookup(v, path) {
+		switch value := value.(type) {
+		case string:
+			out = append(out, value)
+		case json.Number:
+			out = append(out, value.String())
+		}
+	}
+	return out
+}
+
+// lookupString returns the first string value at path, or empty if there is none.
+func lookupString(v any, path []string) string {
+	if values := lookupStrings(v, path); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// lookupTrue reports whether the value at path is the boolean true.
+func lookupTrue(v any, path []string) bool {
+	for _, value := range lookup(v, path) {
+		if b, ok := value.(bool); ok && b {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package spec
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup(t *testing.T) {
+	t.Parallel()
+
+	dec := json.NewDecoder(strings.NewReader(`{
+		"data": [{"host": "a.example.com", "port": 443}, {"host": "b.example.com"}, {"other": true}],
+		"meta": {"next": "c2", "more": true, "count": 3},
+		"hosts": {"x": "x.example.com", "y": "y.example.com"}
+	}`))
+	dec.UseNumber()
+	var doc any
+	require.NoError(t, dec.Decode(&doc))
+
+	tests := []struct {
+		name string
+		path string
+		want []string
+	}{
+		{name: "array_wildcard", path: "data.*.host", want: []string{"a.example.com", "b.example.com"}},
+		{name: "array_index", path: "data.1.host", want: []string{"b.example.com"}},
+		{name: "index_out_of_range", path: "data.5.host", want: nil},
+		{name: "number", path: "meta.count", want: []string{"3"}},
+		{name: "missing", path: "meta.prev", want: nil},
+		{name: "non_string", path: "meta.more", want: nil},
+		{name: "object_wildcard", path: "hosts.*", want: []string{"x.example.com", "y.example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ElementsMatch(t, tt.want, lookupStrings(doc, splitPath(tt.path)))
+		})
+	}
+
+	t.Run("root", func(t *testing.T) {
+		assert.Equal(t, []any{"v"}, lookup("v", splitPath("")))
+	})
+
+	t.Run("true", func(t *testing.T) {
+		assert.True(t, lookupTrue(doc, splitPath("meta.more")))
+		assert.False(t, lookupTrue(doc, splitPath("meta.next")))
+		assert.False(t, lookupTrue(doc, splitPath("meta.missing")))
+	})
+}
@@ -0,0 +1,341 @@
+package spec
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-appsec/scout/sources"
+)
+
+const (
+	maxLineSize = 1024 * 1024
+	maxBodySize = 32 * 1024 * 1024
+)
+
+// run queries the declared endpoint, following pagination until a stop condition or MaxPages is reached.
+func (c *compiled) run(ctx context.Context, client *http.Client, domain string, apiKey string) iter.Seq2[sources.Result, error] {
+	return func(yield func(sources.Result, error) bool) {
+		log := sources.Logger(ctx)
+		ex, err := newExtractors(domain)
+		if err != nil {
+			yield(sources.Result{}, fmt.Errorf("%s: %w", c.Name, err))
+			return
+		}
+
+		p := c.Paginate
+		number, cursor, next := *p.Start, "", ""
+		visited := make(map[string]bool)
+		var count int
+		for pages := 1; ; pages++ {
+			if ctx.Err() != nil {
+				return
+			}
+
+			req, err := c.newRequest(ctx, domain, apiKey, number, cursor, next)
+			if err != nil {
+				yield(sources.Result{}, fmt.Errorf("%s: %w", c.Name, err))
+				return
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				yield(sources.Result{}, fmt.Errorf("%s: %w", c.Name, redactError(err, apiKey)))
+				return
+			}
+			log.DebugContext(ctx, "response received", "url", redact(req.URL.String(), apiKey), "page", pages, "status", resp.StatusCode)
+			if resp.StatusCode != http.StatusOK {
+				_ = resp.Body.Close()
+				yield(sources.Result{}, fmt.Errorf("%s: unexpected status %d", c.Name, resp.StatusCode))
+				return
+			}
+
+			pg, err := c.decode(resp.Body, ex)
+			_ = resp.Body.Close()
+			if err != nil {
+				yield(sources.Result{}, fmt.Errorf("%s: %w", c.Name, err))
+				return
+			}
+			for _, result := range pg.results {
+				if !yield(result, nil) {
+					return
+				}
+				count++
+			}
+
+			// Determine the next page, stopping once the response indicates there is none or a token repeats
+			var token string
+			more := true
+			switch p.Type {
+			case PageNone:
+				more = false
+			case PageNumber:
+				number++
+				more = len(pg.results) > 0
+			case PageCursor:
+				cursor, token = pg.cursor, pg.cursor
+			case PageNextToken:
+				cursor = resp.Header.Get(p.Header)
+				token = cursor
+			case PageLink:
+				next = pg.next
+				if p.Path == "" {
+					next = linkNext(resp.Header.Values("Link"))
+				}
+				if u, err := req.URL.Parse(next); err == nil && next != "" {
+					if u.Scheme != req.URL.Scheme || u.Host != req.URL.Host {
+						// The API key is sent with each page, so it must not leave the source's host
+						log.DebugContext(ctx, "next link to another host ignored", "host", u.Host, "pages", pages, "results", count)
+						return
+					}
+					next = u.String()
+				}
+				token = next
+			}
+			if p.Type != PageNone && p.Type != PageNumber && (token == "" || visited[token]) {
+				more = false
+			} else if p.HasMore != "" && !pg.hasMore {
+				more = false
+			}
+			if !more {
+				log.DebugContext(ctx, "query complete", "pages", pages, "results", count)
+				return
+			} else if pages >= p.MaxPages {
+				log.DebugContext(ctx, "page limit reached", "pages", pages, "results", count)
+				return
+			}
+			visited[token] = true
+		}
+	}
+}
+
+// newRequest builds the request for a page, using next as the URL when following links.
+func (c *compiled) newRequest(ctx context.Context, domain, apiKey string, number int, cursor, next string) (*http.Request, error) {
+	offset := strconv.Itoa((number - *c.Paginate.Start) * c.Paginate.Size)
+	page := strconv.Itoa(number)
+
+	rawURL := next
+	if rawURL == "" {
+		rawURL = strings.NewReplacer(
+			"{domain}", url.PathEscape(domain), "{key}", url.QueryEscape(apiKey),
+			"{page}", page, "{offset}", offset, "{cursor}", url.QueryEscape(cursor),
+		).Replace(c.Request.URL)
+	}
+	var body io.Reader
+	if c.Request.Body != "" {
+		body = strings.NewReader(strings.NewReplacer(
+			"{domain}", jsonEscape(domain), "{key}", jsonEscape(apiKey),
+			"{page}", page, "{offset}", offset, "{cursor}", jsonEscape(cursor),
+		).Replace(c.Request.Body))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, c.Request.Method, rawURL, body)
+	if err != nil {
+		return nil, redactError(err, apiKey)
+	}
+	headers := strings.NewReplacer("{domain}", domain, "{key}", apiKey, "{page}", page, "{offset}", offset, "{cursor}", cursor)
+	for name, value := range c.Request.Headers {
+		req.Header.Set(name, headers.Replace(value))
+	}
+	if apiKey != "" {
+		switch c.Auth.In {
+		case "header":
+			req.Header.Set(c.Auth.Name, c.Auth.Prefix+apiKey)
+		case "query":
+			query := req.URL.Query()
+			query.Set(c.Auth.Name, c.Auth.Prefix+apiKey)
+			req.URL.RawQuery = query.Encode()
+		}
+	}
+	return req, nil
+}
+
+// jsonEscape returns s escaped for use within a JSON string.
+func jsonEscape(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data[1 : len(data)-1])
+}
+
+// redact removes the API key and common credential parameters from a URL for logging.
+func redact(rawURL, apiKey string) string {
+	if apiKey != "" {
+		rawURL = strings.ReplaceAll(rawURL, url.QueryEscape(apiKey), "REDACTED")
+		rawURL = strings.ReplaceAll(rawURL, apiKey, "REDACTED")
+	}
+	return sources.RedactURL(rawURL)
+}
+
+// redactError removes the API key from an error message, as client errors include the request URL.
+func redactError(err error, apiKey string) error {
+	if apiKey == "" {
+		return err
+	}
+	msg := err.Error()
+	if !strings.Contains(msg, apiKey) && !strings.Contains(msg, url.QueryEscape(apiKey)) {
+		return err
+	}
+	return errors.New(redact(msg, apiKey))
+}
+
+// linkNext returns the target of the rel="next" link in Link header values, or empty if there is none.
+func linkNext(values []string) string {
+	for _, value := range values {
+		for _, link := range strings.Split(value, ",") {
+			target, params, ok := strings.Cut(link, ";")
+			if !ok {
+				continue
+			}
+			for _, param := range strings.Split(params, ";") {
+				name, rel, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(name, "rel") {
+					continue
+				}
+				for _, r := range strings.Fields(strings.Trim(rel, `"`)) {
+					if strings.EqualFold(r, "next") {
+						return strings.Trim(strings.TrimSpace(target), "<>")
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// page is the decoded content of a single response.
+type page struct {
+	results []sources.Result
+	hasMore bool   // Value at the HasMore path
+	cursor  string // Value at the cursor path
+	next    string // Value at the next link path
+}
+
+// decode reads the results and pagination state from a response body.
+func (c *compiled) decode(body io.Reader, ex *extractors) (*page, error) {
+	pg := &page{}
+	switch c.Response.Format {
+	case FormatJSON:
+		dec := json.NewDecoder(body)
+		dec.UseNumber()
+		var doc any
+		if err := dec.Decode(&doc); err != nil {
+			return nil, err
+		}
+		c.addDocument(pg, doc, ex)
+	case FormatNDJSON:
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			dec := json.NewDecoder(strings.NewReader(line))
+			dec.UseNumber()
+			var doc any
+			if err := dec.Decode(&doc); err != nil {
+				return nil, err
+			}
+			c.addDocument(pg, doc, ex)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	case FormatLines:
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				c.addText(pg, line, ex)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	case FormatHTML:
+		data, err := io.ReadAll(io.LimitReader(body, maxBodySize))
+		if err != nil {
+			return nil, err
+		}
+		c.addText(pg, string(data), ex)
+	}
+	return pg, nil
+}
+
+// addDocument adds the results of a JSON document, taking pagination state from the latest document.
+func (c *compiled) addDocument(pg *page, doc any, ex *extractors) {
+	for _, m := range c.mappings {
+		for _, value := range lookupStrings(doc, m.path) {
+			pg.results = m.appendResults(pg.results, c.Name, value, ex)
+		}
+	}
+	if c.Paginate.HasMore != "" {
+		pg.hasMore = lookupTrue(doc, splitPath(c.Paginate.HasMore))
+	}
+	switch c.Paginate.Type {
+	case PageCursor:
+		pg.cursor = lookupString(doc, splitPath(c.Paginate.Path))
+	case PageLink:
+		if c.Paginate.Path != "" {
+			pg.next = lookupString(doc, splitPath(c.Paginate.Path))
+		}
+	}
+}
+
+// addText adds the results of a line or body of text.
+func (c *compiled) addText(pg *page, text string, ex *extractors) {
+	for _, m := range c.mappings {
+		pg.results = m.appendResults(pg.results, c.Name, text, ex)
+	}
+}
+
+// appendResults narrows a value by the mapping's pattern and extraction, appending the results.
+func (m mapping) appendResults(results []sources.Result, source, value string, ex *extractors) []sources.Result {
+	values := []string{value}
+	if m.pattern != nil {
+		values = values[:0]
+		for _, match := range m.pattern.FindAllStringSubmatch(value, -1) {
+			values = append(values, match[min(1, len(match)-1)])
+		}
+	}
+	for _, v := range values {
+		var found []string
+		if !m.extract {
+			found = []string{v}
+		} else if m.typ == sources.Subdomain {
+			found = ex.subdomains.Extract(v)
+		} else {
+			found = ex.urls.Extract(v)
+		}
+		for _, f := range found {
+			if f = strings.TrimSpace(f); f != "" {
+				results = append(results, sources.Result{Type: m.typ, Value: f, Source: source})
+			}
+		}
+	}
+	return results
+}
+
+// extractors hold the extractors for the queried domain.
+type extractors struct {
+	subdomains *sources.SubdomainExtractor
+	urls       *sources.URLExtractor
+}
+
+func newExtractors(domain string) (*extractors, error) {
+	subdomains, err := sources.NewSubdomainExtractor(domain)
+	if err != nil {
+		return nil, err
+	}
+	urls, err := sources.NewURLExtractor(domain)
+	if err != nil {
+		return nil, err
+	}
+	return &extractors{subdomains: subdomains, urls: urls}, nil
+}
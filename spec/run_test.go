@@ -0,0 +1,486 @@
+package spec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-appsec/scout/sources"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// localClient returns a client which sends every request to the handler, regardless of the requested host.
+func localClient(t *testing.T, handler http.Handler) *http.Client {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	target, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	return &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.URL.Scheme = target.Scheme
+		req.URL.Host = target.Host
+		return server.Client().Transport.RoundTrip(req)
+	})}
+}
+
+// collect returns the results and errors of a source run.
+func collect(seq iter.Seq2[sources.Result, error]) ([]sources.Result, []error) {
+	var results []sources.Result
+	var errs []error
+	for r, err := range seq {
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			results = append(results, r)
+		}
+	}
+	return results, errs
+}
+
+func values(results []sources.Result) []string {
+	out := make([]string, len(results))
+	for i, r := range results {
+		out[i] = r.Value
+	}
+	return out
+}
+
+func compile(t *testing.T, doc string) sources.Source {
+	t.Helper()
+
+	srcs, err := Load(strings.NewReader(doc))
+	require.NoError(t, err)
+	require.Len(t, srcs, 1)
+	return srcs[0]
+}
+
+func TestBuiltinEquivalents(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		file    string
+		builtin sources.Source
+		handler http.HandlerFunc
+	}{
+		{
+			file:    "reconeer.yaml",
+			builtin: sources.Reconeer,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("X-API-KEY") != "k3y" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				_, _ = fmt.Fprint(w, `{"subdomains":[{"subdomain":"a.example.com"},{"subdomain":""},{"subdomain":"b.example.com"}]}`)
+			},
+		},
+		{
+			file:    "thc.yaml",
+			builtin: sources.THC,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				var body struct {
+					Domain    string `json:"domain"`
+					PageState string `json:"page_state"`
+				}
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				if body.Domain != "example.com" {
+					w.WriteHeader(http.StatusBadRequest)
+				} else if body.PageState == "" {
+					_, _ = fmt.Fprint(w, `{"domains":[{"domain":"a.example.com"},{"domain":"b.example.com"}],"next_page_state":"p2"}`)
+				} else {
+					_, _ = fmt.Fprint(w, `{"domains":[{"domain":"c.example.com"}],"next_page_state":""}`)
+				}
+			},
+		},
+		{
+			file:    "hackertarget.yaml",
+			builtin: sources.HackerTarget,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("apikey") != "k3y" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				_, _ = fmt.Fprint(w, "a.example.com,1.2.3.4\n\nB.example.com,5.6.7.8\n")
+			},
+		},
+		{
+			file:    "alienvault.json",
+			builtin: sources.AlienVault,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("page") == "1" {
+					_, _ = fmt.Fprint(w, `{"url_list":[{"url":"https://a.example.com/login"}],"has_next":true}`)
+				} else {
+					_, _ = fmt.Fprint(w, `{"url_list":[{"url":"https://example.com/"},{"url":""}],"has_next":false}`)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.TrimSuffix(tt.file, filepath.Ext(tt.file)), func(t *testing.T) {
+			srcs, err := LoadFile(filepath.Join("testdata", tt.file))
+			require.NoError(t, err)
+			require.Len(t, srcs, 1)
+			src := srcs[0]
+			client := localClient(t, tt.handler)
+
+			want, wantErrs := collect(tt.builtin.Run(t.Context(), client, "example.com", "k3y"))
+			got, errs := collect(src.Run(t.Context(), client, "example.com", "k3y"))
+
+			require.Empty(t, wantErrs)
+			require.Empty(t, errs)
+			require.NotEmpty(t, want)
+			assert.ElementsMatch(t, want, got)
+			assert.Equal(t, tt.builtin.Name, src.Name)
+			assert.Equal(t, tt.builtin.Yields, src.Yields)
+			assert.InDelta(t, tt.builtin.RateLimit, src.RateLimit, 0.001)
+			assert.InDelta(t, tt.builtin.KeyedRateLimit, src.KeyedRateLimit, 0.001)
+			assert.Equal(t, tt.builtin.Burst, src.Burst)
+			assert.Equal(t, tt.builtin.KeyOptional, src.KeyOptional)
+			assert.Equal(t, tt.builtin.KeyFormat, src.KeyFormat)
+			assert.Equal(t, tt.builtin.Quota, src.Quota)
+			assert.Equal(t, tt.builtin.Homepage, src.Homepage)
+			assert.Equal(t, tt.builtin.Group, src.Group)
+			assert.Equal(t, tt.builtin.Collection, src.Collection)
+			assert.Equal(t, tt.builtin.UserAgent, src.UserAgent)
+		})
+	}
+}
+
+func TestPagination(t *testing.T) {
+	t.Parallel()
+
+	t.Run("link_header", func(t *testing.T) {
+		src := compile(t, `
+name: linked
+yields: [subdomain]
+request: {url: "https://api.example.net/v1/{domain}"}
+pagination: {type: link}
+response: {results: [{type: subdomain, path: "*"}]}
+`)
+		client := localClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Query().Get("after") {
+			case "":
+				w.Header().Set("Link", `<https://api.example.net/v1/first>; rel="first", </v1/example.com?after=a>; rel="next"`)
+				_, _ = fmt.Fprint(w, `["a.example.com"]`)
+			case "a":
+				w.Header().Set("Link", `<?after=b>; rel="prev next"`)
+				_, _ = fmt.Fprint(w, `["b.example.com"]`)
+			default:
+				_, _ = fmt.Fprint(w, `["c.example.com"]`)
+			}
+		}))
+
+		results, errs := collect(src.Run(t.Context(), client, "example.com", ""))
+		require.Empty(t, errs)
+		assert.Equal(t, []string{"a.example.com", "b.example.com", "c.example.com"}, values(results))
+	})
+
+	t.Run("link_body", func(t *testing.T) {
+		src := compile(t, `
+name: linked
+yields: [url]
+request: {url: "https://api.example.net/urls?q={domain}"}
+pagination: {type: link, path: links.next}
+response: {results: [{type: url, path: data.*.url}]}
+`)
+		client := localClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("page") == "" {
+				_, _ = fmt.Fprint(w, `{"data":[{"url":"https://example.com/a"}],"links":{"next":"https://api.example.net/urls?q=example.com&page=2"}}`)
+			} else {
+				_, _ = fmt.Fprint(w, `{"data":[{"url":"https://example.com/b"}],"links":{"next":null}}`)
+			}
+		}))
+
+		results, errs := collect(src.Run(t.Context(), client, "example.com", ""))
+		require.Empty(t, errs)
+		assert.Equal(t, []string{"https://example.com/a", "https://example.com/b"}, values(results))
+		assert.Equal(t, sources.URL, results[0].Type)
+	})
+
+	t.Run("link_other_host", func(t *testing.T) {
+		src := compile(t, `
+name: linked
+yields: [url]
+auth: {in: header, name: X-Api-Key}
+request: {url: "https://api.example.net/urls?q={domain}"}
+pagination: {type: link, path: links.next}
+response: {results: [{type: url, path: data.*.url}]}
+`)
+		var hosts []string
+		client := localClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hosts = append(hosts, r.Host)
+			_, _ = fmt.Fprint(w, `{"data":[{"url":"https://example.com/a"}],"links":{"next":"https://attacker.example.org/collect"}}`)
+		}))
+
+		results, errs := collect(src.Run(t.Context(), client, "example.com", "secret"))
+		require.Empty(t, errs)
+		assert.Equal(t, []string{"https://example.com/a"}, values(results))
+		assert.Equal(t, []string{"api.example.net"}, hosts)
+	})
+
+	t.Run("next_token", func(t *testing.T) {
+		src := compile(t, `
+name: tokens
+yields: [subdomain]
+request:
+  url: "https://api.example.net/{domain}"
+  headers: {X-Page-Token: "{cursor}"}
+pagination: {type: next_token, header: X-Next-Token}
+response: {results: [{type: subdomain, path: "hosts"}]}
+`)
+		client := localClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Header.Get("X-Page-Token") {
+			case "":
+				w.Header().Set("X-Next-Token", "t2")
+				_, _ = fmt.Fprint(w, `{"hosts":"a.example.com"}`)
+			case "t2":
+				w.Header().Set("X-Next-Token", "t2") // repeated token ends paging
+				_, _ = fmt.Fprint(w, `{"hosts":"b.example.com"}`)
+			}
+		}))
+
+		results, errs := collect(src.Run(t.Context(), client, "example.com", ""))
+		require.Empty(t, errs)
+		assert.Equal(t, []string{"a.example.com", "b.example.com"}, values(results))
+	})
+
+	t.Run("offset_max_pages", func(t *testing.T) {
+		src := compile(t, `
+name: offsets
+yields: [subdomain]
+request: {url: "https://api.example.net/{domain}?offset={offset}&limit=2"}
+pagination: {type: page, size: 2, max_pages: 3}
+response: {results: [{type: subdomain, path: "*"}]}
+`)
+		var offsets []string
+		client := localClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			offset := r.URL.Query().Get("offset")
+			offsets = append(offsets, offset)
+			_, _ = fmt.Fprintf(w, `["o%s.example.com"]`, offset)
+		}))
+
+		results, errs := collect(src.Run(t.Context(), client, "example.com", ""))
+		require.Empty(t, errs)
+		assert.Equal(t, []string{"0", "2", "4"}, offsets)
+		assert.Len(t, results, 3)
+	})
+
+	t.Run("page_stops_when_empty", func(t *testing.T) {
+		src := compile(t, `
+name: pages
+yields: [subdomain]
+request: {url: "https://api.example.net/{domain}/{page}"}
+pagination: {type: page}
+response: {results: [{type: subdomain, path: "*"}]}
+`)
+		client := localClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/example.com/1" {
+				_, _ = fmt.Fprint(w, `["a.example.com"]`)
+			} else {
+				_, _ = fmt.Fprint(w, `[]`)
+			}
+		}))
+
+		results, errs := collect(src.Run(t.Context(), client, "example.com", ""))
+		require.Empty(t, errs)
+		assert.Equal(t, []string{"a.example.com"}, values(results))
+	})
+
+	t.Run("page_zero_based", func(t *testing.T) {
+		src := compile(t, `
+name: pages
+yields: [subdomain]
+request: {url: "https://api.example.net/{domain}?page={page}&offset={offset}"}
+pagination: {type: page, start: 0, size: 10}
+response: {results: [{type: subdomain, path: "*"}]}
+`)
+		var requested []string
+		client := localClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requested = append(requested, r.URL.RawQuery)
+			switch r.URL.Query().Get("page") {
+			case "0":
+				_, _ = fmt.Fprint(w, `["a.example.com"]`)
+			case "1":
+				_, _ = fmt.Fprint(w, `["b.example.com"]`)
+			default:
+				_, _ = fmt.Fprint(w, `[]`)
+			}
+		}))
+
+		results, errs := collect(src.Run(t.Context(), client, "example.com", ""))
+		require.Empty(t, errs)
+		assert.Equal(t, []string{"a.example.com", "b.example.com"}, values(results))
+		assert.Equal(t, []string{"page=0&offset=0", "page=1&offset=10", "page=2&offset=20"}, requested)
+	})
+}
+
+func TestFormats(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ndjson", func(t *testing.T) {
+		src := compile(t, `
+name: stream
+yields: [subdomain, url]
+request: {url: "https://api.example.net/{domain}"}
+response:
+  format: ndjson
+  results:
+    - {type: subdomain, path: host}
+    - {type: url, path: urls.0}
+`)
+		client := localClient(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = fmt.Fprint(w, "{\"host\":\"a.example.com\",\"urls\":[\"https://a.example.com/x\"]}\n\n{\"host\":\"b.example.com\",\"urls\":[]}\n")
+		}))
+
+		results, errs := collect(src.Run(t.Context(), client, "example.com", ""))
+		require.Empty(t, errs)
+		assert.Equal(t, []string{"a.example.com", "https://a.example.com/x", "b.example.com"}, values(results))
+	})
+
+	t.Run("html_pattern", func(t *testing.T) {
+		src := compile(t, `
+name: scraped
+yields: [subdomain]
+request: {url: "https://www.example.net/search?q={domain}"}
+response:
+  format: html
+  results:
+    - {type: subdomain, pattern: '<td class="host">([^<]+)</td>'}
+`)
+		client := localClient(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = fmt.Fprint(w, `<table><tr><td class="host">a.example.com</td></tr><tr><td class="host">b.example.com</td></tr></table>`)
+		}))
+
+		results, errs := collect(src.Run(t.Context(), client, "example.com", ""))
+		require.Empty(t, errs)
+		assert.Equal(t, []string{"a.example.com", "b.example.com"}, values(results))
+	})
+
+	t.Run("invalid_json", func(t *testing.T) {
+		src := compile(t, `
+name: broken
+yields: [subdomain]
+request: {url: "https://api.example.net/{domain}"}
+response: {results: [{type: subdomain, path: "*"}]}
+`)
+		client := localClient(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = fmt.Fprint(w, `<html>`)
+		}))
+
+		_, errs := collect(src.Run(t.Context(), client, "example.com", ""))
+		require.Len(t, errs, 1)
+		assert.ErrorContains(t, errs[0], "broken: invalid character")
+	})
+}
+
+func TestAuth(t *testing.T) {
+	t.Parallel()
+
+	t.Run("header_prefix", func(t *testing.T) {
+		src := compile(t, `
+name: bearer
+yields: [subdomain]
+auth: {required: true, in: header, name: Authorization, prefix: "Bearer "}
+request: {url: "https://api.example.net/{domain}"}
+response: {results: [{type: subdomain, path: "*"}]}
+`)
+		assert.True(t, src.AuthRequired)
+		client := localClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = fmt.Fprintf(w, `[%q]`, r.Header.Get("Authorization"))
+		}))
+
+		results, _ := collect(src.Run(t.Context(), client, "example.com", "k3y"))
+		assert.Equal(t, []string{"Bearer k3y"}, values(results))
+	})
+
+	t.Run("omitted_without_key", func(t *testing.T) {
+		src := compile(t, `
+name: optional
+yields: [subdomain]
+auth: {in: query, name: token}
+request: {url: "https://api.example.net/{domain}"}
+response: {results: [{type: subdomain, path: "*"}]}
+`)
+		client := localClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = fmt.Fprintf(w, `[%q]`, "query="+r.URL.RawQuery)
+		}))
+
+		results, _ := collect(src.Run(t.Context(), client, "example.com", ""))
+		assert.Equal(t, []string{"query="}, values(results))
+	})
+
+	t.Run("redacts_errors", func(t *testing.T) {
+		src := compile(t, `
+name: leaky
+yields: [subdomain]
+auth: {required: true}
+request: {url: "https://api.example.net/{domain}?auth_token={key}"}
+response: {results: [{type: subdomain, path: "*"}]}
+`)
+		client := &http.Client{Transport: roundTripFunc(func(*http.Request) (*http.Response, error) {
+			return nil, errors.New("connection refused")
+		})}
+
+		_, errs := collect(src.Run(t.Context(), client, "example.com", "s3cret/key"))
+		require.Len(t, errs, 1)
+		assert.NotContains(t, errs[0].Error(), "s3cret")
+		assert.Contains(t, errs[0].Error(), "REDACTED")
+	})
+
+	t.Run("unexpected_status", func(t *testing.T) {
+		src := compile(t, `
+name: limited
+yields: [subdomain]
+request: {url: "https://api.example.net/{domain}"}
+response: {results: [{type: subdomain, path: "*"}]}
+`)
+		client := localClient(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = io.WriteString(w, "slow down")
+		}))
+
+		_, errs := collect(src.Run(t.Context(), client, "example.com", ""))
+		require.Len(t, errs, 1)
+		assert.EqualError(t, errs[0], "limited: unexpected status 429")
+	})
+}
+
+func TestLinkNext(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		values []string
+		want   string
+	}{
+		{name: "none", values: nil, want: ""},
+		{name: "single", values: []string{`<https://api.example.net/?page=2>; rel="next"`}, want: "https://api.example.net/?page=2"},
+		{name: "multiple_links", values: []string{`<https://a/1>; rel="prev", <https://a/3>; rel=next`}, want: "https://a/3"},
+		{name: "multiple_headers", values: []string{`<https://a/1>; rel="prev"`, `<https://a/3>; title="x"; rel="next"`}, want: "https://a/3"},
+		{name: "no_next", values: []string{`<https://a/1>; rel="last"`}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, linkNext(tt.values))
+		})
+	}
+}
@@ -0,0 +1,486 @@
+// Package spec defines sources declaratively, so providers can be added or patched without recompiling.
+//
+// A spec describes the request to send, where the API key goes, how to page through responses,
+// and how to pick results out of each response. Specs are written in YAML or JSON:
+//
+//	name: reconeer
+//	yields: [subdomain]
+//	auth:
+//	  in: header
+//	  name: X-API-KEY
+//	request:
+//	  url: https://www.reconeer.com/api/domain/{domain}
+//	  headers:
+//	    Accept: application/json
+//	response:
+//	  format: json
+//	  results:
+//	    - type: subdomain
+//	      path: subdomains.*.subdomain
+//
+// The request URL, headers, and body may reference the placeholders {domain}, {key}, {page}, {offset}, and {cursor}.
+// Compiled specs are ordinary sources.Source values, RegisterFile adds them to the source registry,
+// replacing any built-in source of the same name.
+package spec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/go-appsec/scout/sources"
+)
+
+// Spec declares a source.
+type Spec struct {
+	Name      string     `yaml:"name"`      // Unique source name
+	Yields    []string   `yaml:"yields"`    // Result types produced: subdomain, url
+	Recursive bool       `yaml:"recursive"` // Source returns more results when queried directly for a deep subdomain
+	Group     string     `yaml:"group"`     // Provider quota shared with other sources, default the source name
+	Auth      Auth       `yaml:"auth"`
+	Request   Request    `yaml:"request"`
+	Paginate  Pagination `yaml:"pagination"`
+	Response  Response   `yaml:"response"`
+
+	RateLimit      float64 `yaml:"rate_limit"`       // Recommended requests per second without an API key
+	KeyedRateLimit float64 `yaml:"keyed_rate_limit"` // Recommended requests per second with an API key, default rate_limit
+	Burst          int     `yaml:"burst"`            // Requests allowed at once before the rate limit applies, default 1
+	Quota          Quota   `yaml:"quota"`
+	Homepage       string  `yaml:"homepage"`
+	Collection     string  `yaml:"collection"` // api or scraping, default api
+	UserAgent      string  `yaml:"user_agent"` // Preferred User-Agent profile: default or browser, default default
+}
+
+// Auth declares where the API key is placed. The key is only sent when one is configured.
+type Auth struct {
+	Required bool   `yaml:"required"` // Source is skipped without an API key
+	In       string `yaml:"in"`       // header or query, empty when the key is placed with a {key} placeholder
+	Name     string `yaml:"name"`     // Header or query parameter name
+	Prefix   string `yaml:"prefix"`   // Prepended to the key, such as "Bearer "
+	Optional bool   `yaml:"optional"` // Source works without a key, but a key raises limits or improves results
+	Format   string `yaml:"format"`   // Expected key, such as "key" or "email:key", default key when a key is used
+}
+
+// Quota declares the provider's free usage allowance.
+type Quota struct {
+	Requests int    `yaml:"requests"` // Requests allowed per period
+	Period   string `yaml:"period"`   // sec, min, hour, day, month, or a duration such as 12h
+	Note     string `yaml:"note"`
+}
+
+// Request declares the HTTP request for each page.
+type Request struct {
+	Method  string            `yaml:"method"` // Default is GET, or POST when a body is set
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	Body    string            `yaml:"body"` // Placeholder values are escaped for use within JSON strings
+}
+
+// Pagination types.
+const (
+	PageNone      = "none"       // A single request
+	PageNumber    = "page"       // {page} counts up from Start, {offset} steps by Size
+	PageCursor    = "cursor"     // {cursor} is read from the response body at Path
+	PageLink      = "link"       // The next URL is read from the Link header, or from the response body at Path
+	PageNextToken = "next_token" // {cursor} is read from the response header named by Header
+)
+
+// Pagination declares how further pages are requested.
+type Pagination struct {
+	Type     string `yaml:"type"`      // One of the Page constants, default none
+	Start    *int   `yaml:"start"`     // First page number, default 1, 0 for zero based APIs
+	Size     int    `yaml:"size"`      // Results per page, {offset} is (page - start) * size
+	Path     string `yaml:"path"`      // Body path of the cursor or next URL
+	Header   string `yaml:"header"`    // Header holding the next token
+	HasMore  string `yaml:"has_more"`  // Body path of a boolean, paging stops once it is not true
+	MaxPages int    `yaml:"max_pages"` // Bound on requests per run, default 100
+}
+
+// Response formats.
+const (
+	FormatJSON   = "json"   // A JSON document, results are selected by path
+	FormatNDJSON = "ndjson" // One JSON document per line, results are selected by path from each
+	FormatLines  = "lines"  // Each non-empty line is a value
+	FormatHTML   = "html"   // The whole body is a value, typically narrowed with a pattern or extraction
+)
+
+// Response declares how results are read from a response.
+type Response struct {
+	Format  string    `yaml:"format"` // One of the Format constants, default json
+	Results []Mapping `yaml:"results"`
+}
+
+// Mapping selects results of a single type from a response.
+// Values are selected by Path, then narrowed by Pattern, then by extraction, each step being optional.
+type Mapping struct {
+	Type    string `yaml:"type"`    // subdomain or url
+	Path    string `yaml:"path"`    // Dot separated path into JSON values, * matches every element
+	Pattern string `yaml:"pattern"` // Regular expression matched against values, yielding the first group if present
+	Extract bool   `yaml:"extract"` // Extract subdomains or URLs of the queried domain from values
+}
+
+// Parse reads specs from YAML or JSON. The input may hold several YAML documents,
+// each either a single spec or a list of specs. Unknown fields are rejected.
+func Parse(r io.Reader) ([]Spec, error) {
+	var specs []Spec
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	for {
+		var node yaml.Node
+		if err := dec.Decode(&node); errors.Is(err, io.EOF) {
+			return specs, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("spec: %w", err)
+		}
+
+		if len(node.Content) == 1 && node.Content[0].Kind == yaml.SequenceNode {
+			var list []Spec
+			if err := decodeStrict(&node, &list); err != nil {
+				return nil, fmt.Errorf("spec: %w", err)
+			}
+			specs = append(specs, list...)
+		} else {
+			var s Spec
+			if err := decodeStrict(&node, &s); err != nil {
+				return nil, fmt.Errorf("spec: %w", err)
+			}
+			specs = append(specs, s)
+		}
+	}
+}
+
+// decodeStrict decodes a node rejecting unknown fields, which yaml.Node.Decode does not support directly.
+func decodeStrict(node *yaml.Node, v any) error {
+	data, err := yaml.Marshal(node)
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(strings.NewReader(string(data)))
+	dec.KnownFields(true)
+	return dec.Decode(v)
+}
+
+// Load parses and compiles the specs read from r.
+func Load(r io.Reader) ([]sources.Source, error) {
+	specs, err := Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	srcs := make([]sources.Source, len(specs))
+	seen := make(map[string]bool)
+	for i, s := range specs {
+		if seen[s.Name] {
+			return nil, fmt.Errorf("spec: duplicate source %q", s.Name)
+		}
+		seen[s.Name] = true
+		if srcs[i], err = s.Compile(); err != nil {
+			return nil, err
+		}
+	}
+	return srcs, nil
+}
+
+// LoadFile parses and compiles the specs in a file.
+func LoadFile(path string) ([]sources.Source, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("spec: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	srcs, err := Load(f)
+	if err != nil {
+		return nil, fmt.Errorf("%w (%s)", err, path)
+	}
+	return srcs, nil
+}
+
+// RegisterFile loads the specs in a file and registers their sources, replacing registered sources of the same name.
+// Nothing is registered if any spec is invalid.
+func RegisterFile(path string) ([]sources.Source, error) {
+	srcs, err := LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, src := range srcs {
+		sources.Register(src)
+	}
+	return srcs, nil
+}
+
+// Compile validates the spec and returns it as a source.
+func (s Spec) Compile() (sources.Source, error) {
+	c, err := s.compile()
+	if err != nil {
+		if s.Name == "" {
+			return sources.Source{}, fmt.Errorf("spec: %w", err)
+		}
+		return sources.Source{}, fmt.Errorf("spec: %s: %w", s.Name, err)
+	}
+	return sources.Source{
+		Name:           s.Name,
+		Yields:         c.yields,
+		AuthRequired:   s.Auth.Required,
+		Recursive:      s.Recursive,
+		Group:          s.Group,
+		RateLimit:      s.RateLimit,
+		KeyedRateLimit: s.KeyedRateLimit,
+		Burst:          s.Burst,
+		KeyOptional:    s.Auth.Optional,
+		KeyFormat:      c.Auth.Format,
+		Quota:          c.quota,
+		Homepage:       s.Homepage,
+		Collection:     c.collection,
+		UserAgent:      c.userAgent,
+		Run:            c.run,
+	}, nil
+}
+
+// compiled is a validated spec with defaults applied.
+type compiled struct {
+	Spec
+	yields     sources.ResultType
+	mappings   []mapping
+	quota      sources.Quota
+	collection sources.Collection
+	userAgent  sources.UserAgentProfile
+}
+
+// mapping is a validated Mapping.
+type mapping struct {
+	typ     sources.ResultType
+	path    []string
+	pattern *regexp.Regexp
+	extract bool
+}
+
+func (s Spec) compile() (*compiled, error) {
+	if s.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	c := &compiled{Spec: s}
+	for _, name := range s.Yields {
+		t, err := sources.ParseResultType(name)
+		if err != nil {
+			return nil, fmt.Errorf("yields: %w", err)
+		}
+		c.yields |= t
+	}
+	if c.yields == 0 {
+		return nil, errors.New("yields is required")
+	}
+
+	if err := c.compileRequest(); err != nil {
+		return nil, err
+	} else if err := c.compilePagination(); err != nil {
+		return nil, err
+	} else if err := c.compileMetadata(); err != nil {
+		return nil, err
+	}
+	return c, c.compileResponse()
+}
+
+func (c *compiled) compileMetadata() error {
+	if c.RateLimit < 0 || c.KeyedRateLimit < 0 {
+		return errors.New("rate_limit must not be negative")
+	} else if c.KeyedRateLimit > 0 && !c.Auth.Required && !c.Auth.Optional {
+		return errors.New("keyed_rate_limit requires auth.required or auth.optional")
+	} else if c.Burst < 0 {
+		return errors.New("burst must not be negative")
+	}
+	if c.Collection != "" {
+		collection, err := sources.ParseCollection(c.Collection)
+		if err != nil {
+			return err
+		}
+		c.collection = collection
+	}
+	if c.UserAgent != "" {
+		profile, err := sources.ParseUserAgentProfile(c.UserAgent)
+		if err != nil {
+			return err
+		}
+		c.userAgent = profile
+	}
+
+	c.quota = sources.Quota{Requests: c.Quota.Requests, Note: c.Quota.Note}
+	if c.Quota.Requests < 0 {
+		return errors.New("quota: requests must not be negative")
+	} else if (c.Quota.Requests > 0) != (c.Quota.Period != "") {
+		return errors.New("quota: requests and period must be set together")
+	} else if c.Quota.Period != "" {
+		period, err := parsePeriod(c.Quota.Period)
+		if err != nil {
+			return fmt.Errorf("quota: %w", err)
+		}
+		c.quota.Period = period
+	}
+	return nil
+}
+
+// parsePeriod reads a quota period as a common window name or a duration.
+func parsePeriod(s string) (time.Duration, error) {
+	switch s {
+	case "sec", "second":
+		return time.Second, nil
+	case "min", "minute":
+		return time.Minute, nil
+	case "hour":
+		return time.Hour, nil
+	case "day":
+		return 24 * time.Hour, nil
+	case "month":
+		return 30 * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("unknown period %q", s)
+	} else if d <= 0 {
+		return 0, errors.New("period must be positive")
+	}
+	return d, nil
+}
+
+func (c *compiled) compileRequest() error {
+	if c.Request.URL == "" {
+		return errors.New("request: url is required")
+	}
+	example := strings.NewReplacer("{domain}", "example.com", "{key}", "key", "{page}", "1", "{offset}", "0", "{cursor}", "c")
+	u, err := url.Parse(example.Replace(c.Request.URL))
+	if err != nil {
+		return fmt.Errorf("request: %w", err)
+	} else if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("request: url must be absolute http or https")
+	}
+	if c.Request.Method == "" {
+		c.Request.Method = "GET"
+		if c.Request.Body != "" {
+			c.Request.Method = "POST"
+		}
+	}
+	c.Request.Method = strings.ToUpper(c.Request.Method)
+
+	switch c.Auth.In {
+	case "":
+		if c.Auth.Required && !c.references("{key}") {
+			return errors.New("auth: required key must be placed with auth.in or a {key} placeholder")
+		}
+	case "header", "query":
+		if c.Auth.Name == "" {
+			return fmt.Errorf("auth: name is required for %s placement", c.Auth.In)
+		}
+	default:
+		return fmt.Errorf("auth: unknown placement %q", c.Auth.In)
+	}
+	if c.Auth.Required && c.Auth.Optional {
+		return errors.New("auth: required and optional are exclusive")
+	}
+	if c.Auth.Format == "" && (c.Auth.Required || c.Auth.Optional) {
+		c.Auth.Format = "key"
+	}
+	return nil
+}
+
+// references reports whether the request URL, headers, or body contain the placeholder.
+func (c *compiled) references(placeholder string) bool {
+	if strings.Contains(c.Request.URL, placeholder) || strings.Contains(c.Request.Body, placeholder) {
+		return true
+	}
+	for _, v := range c.Request.Headers {
+		if strings.Contains(v, placeholder) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *compiled) compilePagination() error {
+	p := &c.Paginate
+	if p.Type == "" {
+		p.Type = PageNone
+	}
+	if p.Start == nil {
+		start := 1
+		p.Start = &start
+	}
+	if p.MaxPages == 0 {
+		p.MaxPages = 100
+	} else if p.MaxPages < 0 {
+		return errors.New("pagination: max_pages must be positive")
+	}
+
+	switch p.Type {
+	case PageNone:
+		return nil
+	case PageNumber:
+		if !c.references("{page}") && !c.references("{offset}") {
+			return errors.New("pagination: page requires a {page} or {offset} placeholder")
+		} else if c.references("{offset}") && p.Size < 1 {
+			return errors.New("pagination: {offset} requires a positive size")
+		}
+	case PageCursor:
+		if p.Path == "" {
+			return errors.New("pagination: cursor requires a path")
+		} else if !c.references("{cursor}") {
+			return errors.New("pagination: cursor requires a {cursor} placeholder")
+		}
+	case PageNextToken:
+		if p.Header == "" {
+			return errors.New("pagination: next_token requires a header")
+		} else if !c.references("{cursor}") {
+			return errors.New("pagination: next_token requires a {cursor} placeholder")
+		}
+	case PageLink:
+	default:
+		return fmt.Errorf("pagination: unknown type %q", p.Type)
+	}
+	return nil
+}
+
+func (c *compiled) compileResponse() error {
+	if c.Response.Format == "" {
+		c.Response.Format = FormatJSON
+	}
+	structured := c.Response.Format == FormatJSON || c.Response.Format == FormatNDJSON
+	switch c.Response.Format {
+	case FormatJSON, FormatNDJSON, FormatLines, FormatHTML:
+	default:
+		return fmt.Errorf("response: unknown format %q", c.Response.Format)
+	}
+	if !structured && (c.Paginate.HasMore != "" || (c.Paginate.Path != "" && c.Paginate.Type != PageNone)) {
+		return fmt.Errorf("pagination: body paths require a json or ndjson response, not %s", c.Response.Format)
+	}
+	if len(c.Response.Results) == 0 {
+		return errors.New("response: results are required")
+	}
+
+	for i, m := range c.Response.Results {
+		t, err := sources.ParseResultType(m.Type)
+		if err != nil {
+			return fmt.Errorf("response: results %d: %w", i, err)
+		} else if c.yields&t == 0 {
+			return fmt.Errorf("response: results %d: type %s is not in yields", i, m.Type)
+		}
+		cm := mapping{typ: t, extract: m.Extract}
+		if m.Path != "" {
+			if !structured {
+				return fmt.Errorf("response: results %d: path requires a json or ndjson response", i)
+			}
+			cm.path = splitPath(m.Path)
+		}
+		if m.Pattern != "" {
+			if cm.pattern, err = regexp.Compile(m.Pattern); err != nil {
+				return fmt.Errorf("response: results %d: %w", i, err)
+			}
+		}
+		c.mappings = append(c.mappings, cm)
+	}
+	return nil
+}
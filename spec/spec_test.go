@@ -0,0 +1,226 @@
+package spec
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-appsec/scout/sources"
+)
+
+const minimal = `
+name: minimal
+yields: [subdomain]
+request: {url: "https://api.example.net/{domain}"}
+response: {results: [{type: subdomain, path: "*"}]}
+`
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	t.Run("documents_and_lists", func(t *testing.T) {
+		specs, err := Parse(strings.NewReader(minimal + "---\n- name: a\n- name: b\n"))
+		require.NoError(t, err)
+
+		require.Len(t, specs, 3)
+		assert.Equal(t, "minimal", specs[0].Name)
+		assert.Equal(t, []string{"subdomain"}, specs[0].Yields)
+		assert.Equal(t, "https://api.example.net/{domain}", specs[0].Request.URL)
+		assert.Equal(t, "a", specs[1].Name)
+		assert.Equal(t, "b", specs[2].Name)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		specs, err := Parse(strings.NewReader(`{"name": "j", "yields": ["url"], "pagination": {"type": "page", "max_pages": 5}}`))
+		require.NoError(t, err)
+
+		require.Len(t, specs, 1)
+		assert.Equal(t, PageNumber, specs[0].Paginate.Type)
+		assert.Equal(t, 5, specs[0].Paginate.MaxPages)
+	})
+
+	t.Run("unknown_field", func(t *testing.T) {
+		_, err := Parse(strings.NewReader("name: x\nyeilds: [subdomain]\n"))
+		assert.ErrorContains(t, err, "field yeilds not found")
+	})
+
+	t.Run("unknown_field_in_list", func(t *testing.T) {
+		_, err := Parse(strings.NewReader("- name: x\n  request: {uri: https://example.net}\n"))
+		assert.ErrorContains(t, err, "field uri not found")
+	})
+}
+
+func TestCompile(t *testing.T) {
+	t.Parallel()
+
+	valid := func() Spec {
+		return Spec{
+			Name:     "valid",
+			Yields:   []string{"subdomain"},
+			Request:  Request{URL: "https://api.example.net/{domain}?page={page}&cursor={cursor}"},
+			Response: Response{Results: []Mapping{{Type: "subdomain", Path: "*"}}},
+		}
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		s := valid()
+		s.Recursive = true
+		src, err := s.Compile()
+		require.NoError(t, err)
+
+		assert.Equal(t, "valid", src.Name)
+		assert.Equal(t, sources.Subdomain, src.Yields)
+		assert.True(t, src.Recursive)
+		assert.False(t, src.AuthRequired)
+		assert.NotNil(t, src.Run)
+
+		c, err := valid().compile()
+		require.NoError(t, err)
+		assert.Equal(t, "GET", c.Request.Method)
+		assert.Equal(t, PageNone, c.Paginate.Type)
+		assert.Equal(t, 1, *c.Paginate.Start)
+		assert.Equal(t, 100, c.Paginate.MaxPages)
+		assert.Equal(t, FormatJSON, c.Response.Format)
+	})
+
+	t.Run("metadata", func(t *testing.T) {
+		s := valid()
+		s.RateLimit = 0.25
+		s.KeyedRateLimit = 1
+		s.Burst = 3
+		s.Quota = Quota{Requests: 4, Period: "min", Note: "per key"}
+		s.Homepage = "https://example.net"
+		s.Group = "example"
+		s.Collection = "scraping"
+		s.UserAgent = "browser"
+		s.Auth = Auth{Optional: true, In: "header", Name: "X-Key"}
+		src, err := s.Compile()
+		require.NoError(t, err)
+
+		assert.InDelta(t, 0.25, src.RateLimit, 0.001)
+		assert.InDelta(t, 1, src.KeyedRateLimit, 0.001)
+		assert.Equal(t, 3, src.Burst)
+		assert.Equal(t, sources.Quota{Requests: 4, Period: time.Minute, Note: "per key"}, src.Quota)
+		assert.Equal(t, "https://example.net", src.Homepage)
+		assert.Equal(t, "example", src.Group)
+		assert.Equal(t, sources.CollectionScraping, src.Collection)
+		assert.Equal(t, sources.UserAgentBrowser, src.UserAgent)
+		assert.True(t, src.KeyOptional)
+		assert.Equal(t, "key", src.KeyFormat)
+	})
+
+	t.Run("post_with_body", func(t *testing.T) {
+		s := valid()
+		s.Request.Body = `{"domain":"{domain}"}`
+		c, err := s.compile()
+		require.NoError(t, err)
+		assert.Equal(t, "POST", c.Request.Method)
+	})
+
+	tests := []struct {
+		name    string
+		modify  func(*Spec)
+		wantErr string
+	}{
+		{name: "missing_name", modify: func(s *Spec) { s.Name = "" }, wantErr: "spec: name is required"},
+		{name: "missing_yields", modify: func(s *Spec) { s.Yields = nil }, wantErr: "spec: valid: yields is required"},
+		{name: "unknown_yield", modify: func(s *Spec) { s.Yields = []string{"ip"} }, wantErr: `unknown result type "ip"`},
+		{name: "missing_url", modify: func(s *Spec) { s.Request.URL = "" }, wantErr: "url is required"},
+		{name: "relative_url", modify: func(s *Spec) { s.Request.URL = "/api/{domain}" }, wantErr: "url must be absolute"},
+		{name: "unplaced_key", modify: func(s *Spec) { s.Auth.Required = true }, wantErr: "auth: required key must be placed"},
+		{name: "unnamed_header", modify: func(s *Spec) { s.Auth.In = "header" }, wantErr: "auth: name is required for header"},
+		{name: "unknown_placement", modify: func(s *Spec) { s.Auth = Auth{In: "cookie", Name: "k"} }, wantErr: `unknown placement "cookie"`},
+		{name: "unknown_pagination", modify: func(s *Spec) { s.Paginate.Type = "scroll" }, wantErr: `unknown type "scroll"`},
+		{name: "negative_max_pages", modify: func(s *Spec) { s.Paginate.MaxPages = -1 }, wantErr: "max_pages must be positive"},
+		{name: "page_placeholder", modify: func(s *Spec) {
+			s.Paginate.Type = PageNumber
+			s.Request.URL = "https://api.example.net/{domain}"
+		}, wantErr: "{page} or {offset} placeholder"},
+		{name: "offset_size", modify: func(s *Spec) {
+			s.Paginate.Type = PageNumber
+			s.Request.URL = "https://api.example.net/{domain}?offset={offset}"
+		}, wantErr: "{offset} requires a positive size"},
+		{name: "cursor_path", modify: func(s *Spec) { s.Paginate.Type = PageCursor }, wantErr: "cursor requires a path"},
+		{name: "cursor_placeholder", modify: func(s *Spec) {
+			s.Paginate = Pagination{Type: PageCursor, Path: "next"}
+			s.Request.URL = "https://api.example.net/{domain}"
+		}, wantErr: "cursor requires a {cursor} placeholder"},
+		{name: "next_token_header", modify: func(s *Spec) { s.Paginate.Type = PageNextToken }, wantErr: "next_token requires a header"},
+		{name: "unknown_format", modify: func(s *Spec) { s.Response.Format = "xml" }, wantErr: `unknown format "xml"`},
+		{name: "missing_results", modify: func(s *Spec) { s.Response.Results = nil }, wantErr: "results are required"},
+		{name: "result_not_yielded", modify: func(s *Spec) { s.Response.Results[0].Type = "url" }, wantErr: "type url is not in yields"},
+		{name: "path_on_lines", modify: func(s *Spec) { s.Response.Format = FormatLines }, wantErr: "path requires a json or ndjson response"},
+		{name: "has_more_on_html", modify: func(s *Spec) {
+			s.Response = Response{Format: FormatHTML, Results: []Mapping{{Type: "subdomain", Extract: true}}}
+			s.Paginate = Pagination{Type: PageNumber, HasMore: "more"}
+		}, wantErr: "body paths require a json or ndjson response"},
+		{name: "negative_rate_limit", modify: func(s *Spec) { s.RateLimit = -1 }, wantErr: "rate_limit must not be negative"},
+		{name: "negative_keyed_rate_limit", modify: func(s *Spec) { s.KeyedRateLimit = -1 }, wantErr: "rate_limit must not be negative"},
+		{name: "keyed_rate_without_key", modify: func(s *Spec) { s.KeyedRateLimit = 2 }, wantErr: "keyed_rate_limit requires auth"},
+		{name: "negative_burst", modify: func(s *Spec) { s.Burst = -1 }, wantErr: "burst must not be negative"},
+		{name: "unknown_collection", modify: func(s *Spec) { s.Collection = "crawl" }, wantErr: `unknown collection "crawl"`},
+		{name: "unknown_user_agent", modify: func(s *Spec) { s.UserAgent = "mobile" }, wantErr: `unknown user agent profile "mobile"`},
+		{name: "quota_without_period", modify: func(s *Spec) { s.Quota.Requests = 50 }, wantErr: "quota: requests and period must be set together"},
+		{name: "unknown_period", modify: func(s *Spec) { s.Quota = Quota{Requests: 50, Period: "fortnight"} }, wantErr: `unknown period "fortnight"`},
+		{name: "required_and_optional", modify: func(s *Spec) { s.Auth = Auth{Required: true, Optional: true, In: "query", Name: "k"} }, wantErr: "auth: required and optional are exclusive"},
+		{name: "invalid_pattern", modify: func(s *Spec) { s.Response.Results[0].Pattern = "(" }, wantErr: "missing closing )"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := valid()
+			tt.modify(&s)
+			_, err := s.Compile()
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	t.Run("duplicate", func(t *testing.T) {
+		_, err := Load(strings.NewReader(minimal + "---\n" + minimal))
+		assert.ErrorContains(t, err, `duplicate source "minimal"`)
+	})
+
+	t.Run("file_error_names_path", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "bad.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("name: bad\n"), 0o600))
+
+		_, err := LoadFile(path)
+		assert.ErrorContains(t, err, "spec: bad: yields is required")
+		assert.ErrorContains(t, err, path)
+	})
+}
+
+func TestRegisterFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "specs.yaml")
+	doc := strings.ReplaceAll(minimal, "name: minimal", "name: spec-register-test")
+	require.NoError(t, os.WriteFile(path, []byte(doc), 0o600))
+
+	srcs, err := RegisterFile(path)
+	require.NoError(t, err)
+	require.Len(t, srcs, 1)
+
+	registered := sources.ByName("spec-register-test")
+	require.NotNil(t, registered)
+	assert.Equal(t, sources.Subdomain, registered.Yields)
+
+	t.Run("invalid_registers_nothing", func(t *testing.T) {
+		bad := filepath.Join(dir, "bad.yaml")
+		doc := strings.ReplaceAll(minimal, "name: minimal", "name: spec-register-valid") + "---\nname: spec-register-bad\n"
+		require.NoError(t, os.WriteFile(bad, []byte(doc), 0o600))
+
+		_, err := RegisterFile(bad)
+		require.Error(t, err)
+		assert.Nil(t, sources.ByName("spec-register-valid"))
+	})
+}
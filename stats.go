@@ -0,0 +1,327 @@
+package scout
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/go-appsec/scout/cluster"
+)
+
+// SourceStatus describes the outcome of a source within a Query.
+type SourceStatus uint8
+
+const (
+	StatusRan          SourceStatus = iota // Source completed without error
+	StatusSkippedNoKey                     // Source requires an API key which was not provided
+	StatusCircuitOpen                      // Source was skipped by the circuit breaker
+	StatusCancelled                        // Source was still running when the query ended
+	StatusFailed                           // Source reported an error or timed out
+)
+
+func (s SourceStatus) String() string {
+	switch s {
+	case StatusRan:
+		return "ran"
+	case StatusSkippedNoKey:
+		return "skipped-no-key"
+	case StatusCircuitOpen:
+		return "circuit-open"
+	case StatusCancelled:
+		return "cancelled"
+	case StatusFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText encodes the status as its string form.
+func (s SourceStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes a status from its string form.
+func (s *SourceStatus) UnmarshalText(text []byte) error {
+	for status := StatusRan; status <= StatusFailed; status++ {
+		if status.String() == string(text) {
+			*s = status
+			return nil
+		}
+	}
+	return fmt.Errorf("scout: unknown source status %q", text)
+}
+
+// SourceStats reports the activity of a single source during a Query.
+// With recursion enabled, runs against every target are combined.
+type SourceStats struct {
+	Name          string        // Source name
+	Status        SourceStatus  // Most severe outcome across runs
+	Runs          int           // Number of targets the source ran against
+	Requests      int64         // HTTP requests made
+	Bytes         int64         // Response body bytes read
+	Duration      time.Duration // Total time spent running
+	Blocked       time.Duration // Time the source spent blocked waiting on the consumer, within Duration
+	RawResults    int64         // Results produced before deduplication
+	UniqueResults int64         // Results yielded after deduplication
+	RateLimit     float64       // Rate limit in requests/second when the source last finished, 0 if unlimited
+	Errors        []error       // Errors reported by the source
+}
+
+// sourceStatsJSON is the JSON form of SourceStats.
+type sourceStatsJSON struct {
+	Name          string       `json:"name"`
+	Status        SourceStatus `json:"status"`
+	Runs          int          `json:"runs"`
+	Requests      int64        `json:"requests"`
+	Bytes         int64        `json:"bytes"`
+	DurationMS    int64        `json:"duration_ms"`
+	BlockedMS     int64        `json:"blocked_ms"`
+	RawResults    int64        `json:"raw_results"`
+	UniqueResults int64        `json:"unique_results"`
+	RateLimit     float64      `json:"rate_limit"`
+	Errors        []string     `json:"errors"`
+}
+
+// MarshalJSON encodes the stats with durations in milliseconds and errors as strings.
+func (s SourceStats) MarshalJSON() ([]byte, error) {
+	errs := make([]string, len(s.Errors))
+	for i, err := range s.Errors {
+		errs[i] = err.Error()
+	}
+	return json.Marshal(sourceStatsJSON{s.Name, s.Status, s.Runs, s.Requests, s.Bytes, s.Duration.Milliseconds(),
+		s.Blocked.Milliseconds(), s.RawResults, s.UniqueResults, s.RateLimit, errs})
+}
+
+// UnmarshalJSON decodes stats encoded by MarshalJSON, errors are restored as plain errors with the same message.
+func (s *SourceStats) UnmarshalJSON(data []byte) error {
+	var v sourceStatsJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*s = SourceStats{
+		Name:          v.Name,
+		Status:        v.Status,
+		Runs:          v.Runs,
+		Requests:      v.Requests,
+		Bytes:         v.Bytes,
+		Duration:      time.Duration(v.DurationMS) * time.Millisecond,
+		Blocked:       time.Duration(v.BlockedMS) * time.Millisecond,
+		RawResults:    v.RawResults,
+		UniqueResults: v.UniqueResults,
+		RateLimit:     v.RateLimit,
+	}
+	for _, msg := range v.Errors {
+		s.Errors = append(s.Errors, errors.New(msg))
+	}
+	return nil
+}
+
+// Summary reports the activity of a completed Query.
+type Summary struct {
+	Domain   string        // Queried domain
+	Started  time.Time     // When the query started
+	Duration time.Duration // Total query time
+	Results  int64         // Unique results yielded
+	Errors   int64         // Errors yielded
+	Sources  []SourceStats // Per-source stats, sorted by name
+
+	// URL clusters, largest first, and the number of URLs excluded by extension, if URL reduction is enabled
+	Clusters     []cluster.Cluster
+	ExcludedURLs int64
+}
+
+// summaryJSON is the JSON form of Summary.
+type summaryJSON struct {
+	Domain       string            `json:"domain"`
+	Started      time.Time         `json:"started"`
+	DurationMS   int64             `json:"duration_ms"`
+	Results      int64             `json:"results"`
+	Errors       int64             `json:"errors"`
+	Sources      []SourceStats     `json:"sources"`
+	Clusters     []cluster.Cluster `json:"clusters,omitempty"`
+	ExcludedURLs int64             `json:"excluded_urls,omitempty"`
+}
+
+// MarshalJSON encodes the summary with the duration in milliseconds.
+func (s Summary) MarshalJSON() ([]byte, error) {
+	return json.Marshal(summaryJSON{s.Domain, s.Started, s.Duration.Milliseconds(), s.Results, s.Errors, s.Sources,
+		s.Clusters, s.ExcludedURLs})
+}
+
+// UnmarshalJSON decodes a summary encoded by MarshalJSON.
+func (s *Summary) UnmarshalJSON(data []byte) error {
+	var v summaryJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*s = Summary{
+		Domain:       v.Domain,
+		Started:      v.Started,
+		Duration:     time.Duration(v.DurationMS) * time.Millisecond,
+		Results:      v.Results,
+		Errors:       v.Errors,
+		Sources:      v.Sources,
+		Clusters:     v.Clusters,
+		ExcludedURLs: v.ExcludedURLs,
+	}
+	return nil
+}
+
+// Source returns the stats for a source by name, or nil if it was not part of the query.
+func (s *Summary) Source(name string) *SourceStats {
+	for i := range s.Sources {
+		if s.Sources[i].Name == name {
+			return &s.Sources[i]
+		}
+	}
+	return nil
+}
+
+// sourceRun tracks a single run of a source against a target.
+// Counters may be read while the run is active, other fields only after the run reports done.
+type sourceRun struct {
+	source    string
+	requests  atomic.Int64
+	bytes     atomic.Int64
+	raw       atomic.Int64
+	blocked   atomic.Int64 // nanoseconds spent blocked on the consumer
+	done      bool
+	rateLimit rate.Limit // Source rate limit when the run finished, zero if the source did not run
+	status    SourceStatus
+	duration  time.Duration
+	errs      []error
+}
+
+// summaryBuilder aggregates source runs into a Summary, it is only accessed from the query goroutine.
+type summaryBuilder struct {
+	domain  string
+	started time.Time
+	results int64
+	errors  int64
+	runs    []*sourceRun
+	unique  map[string]int64
+	reducer *cluster.Reducer // nil unless URL reduction is enabled
+}
+
+func newSummaryBuilder(domain string) *summaryBuilder {
+	return &summaryBuilder{
+		domain:  domain,
+		started: time.Now(),
+		unique:  make(map[string]int64),
+	}
+}
+
+// build creates the Summary, runs which have not reported done are marked cancelled.
+func (b *summaryBuilder) build() *Summary {
+	bySource := make(map[string]*SourceStats)
+	for _, run := range b.runs {
+		stats, ok := bySource[run.source]
+		if !ok {
+			stats = &SourceStats{Name: run.source, Status: StatusSkippedNoKey}
+			bySource[run.source] = stats
+		}
+		status := StatusCancelled
+		if run.done {
+			status = run.status
+			stats.Duration += run.duration
+			stats.Errors = append(stats.Errors, run.errs...)
+			if run.rateLimit != 0 {
+				stats.RateLimit = 0
+				if run.rateLimit != rate.Inf {
+					stats.RateLimit = float64(run.rateLimit)
+				}
+			}
+		}
+		if status != StatusSkippedNoKey && status != StatusCircuitOpen {
+			stats.Runs++
+		}
+		if statusSeverity(status) > statusSeverity(stats.Status) {
+			stats.Status = status
+		}
+		stats.Requests += run.requests.Load()
+		stats.Bytes += run.bytes.Load()
+		stats.RawResults += run.raw.Load()
+		stats.Blocked += time.Duration(run.blocked.Load())
+	}
+
+	summary := &Summary{
+		Domain:   b.domain,
+		Started:  b.started,
+		Duration: time.Since(b.started),
+		Results:  b.results,
+		Errors:   b.errors,
+		Sources:  make([]SourceStats, 0, len(bySource)),
+	}
+	for name, stats := range bySource {
+		stats.UniqueResults = b.unique[name]
+		summary.Sources = append(summary.Sources, *stats)
+	}
+	slices.SortFunc(summary.Sources, func(a, b SourceStats) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	if b.reducer != nil {
+		summary.Clusters = b.reducer.Clusters()
+		summary.ExcludedURLs = int64(b.reducer.Excluded())
+	}
+	return summary
+}
+
+// statusSeverity orders statuses so the most significant outcome across runs is reported.
+func statusSeverity(s SourceStatus) int {
+	switch s {
+	case StatusSkippedNoKey:
+		return 0
+	case StatusCircuitOpen:
+		return 1
+	case StatusRan:
+		return 2
+	case StatusCancelled:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// statsTransport wraps an http.RoundTripper to count requests and response bytes for a source run.
+type statsTransport struct {
+	base http.RoundTripper
+	run  *sourceRun
+}
+
+func (t *statsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.run.requests.Add(1)
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &countingBody{ReadCloser: resp.Body, count: &t.run.bytes}
+	return resp, nil
+}
+
+// countingBody counts bytes read from a response body.
+type countingBody struct {
+	io.ReadCloser
+	count *atomic.Int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.count.Add(int64(n))
+	return n, err
+}
+
+// statsMiddleware returns middleware counting requests and response bytes for the run.
+func statsMiddleware(run *sourceRun) Middleware {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return &statsTransport{base: rt, run: run}
+	}
+}
@@ -0,0 +1,343 @@
+package scout
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-appsec/scout/cluster"
+	"github.com/go-appsec/scout/sources"
+)
+
+func TestSourceStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		status SourceStatus
+		want   string
+	}{
+		{StatusRan, "ran"},
+		{StatusSkippedNoKey, "skipped-no-key"},
+		{StatusCircuitOpen, "circuit-open"},
+		{StatusCancelled, "cancelled"},
+		{StatusFailed, "failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.status.String())
+
+			text, err := tt.status.MarshalText()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(text))
+		})
+	}
+}
+
+// querySummary runs a query to completion and returns the summary.
+func querySummary(t *testing.T, opts ...Option) ([]sources.Result, *Summary) {
+	t.Helper()
+
+	var summary *Summary
+	opts = append(opts, WithSummary(func(s *Summary) { summary = s }))
+	results, _ := Collect(Query(t.Context(), "example.com", opts...))
+	require.NotNil(t, summary)
+	return results, summary
+}
+
+func TestQuerySummary(t *testing.T) {
+	t.Parallel()
+
+	t.Run("counts_raw_and_unique", func(t *testing.T) {
+		src1 := mockSource("src1", sources.Subdomain, []sources.Result{
+			{Type: sources.Subdomain, Value: "api.example.com", Source: "src1"},
+			{Type: sources.Subdomain, Value: "www.example.com", Source: "src1"},
+		}, nil)
+		src2 := mockSource("src2", sources.Subdomain, []sources.Result{
+			{Type: sources.Subdomain, Value: "api.example.com", Source: "src2"},
+		}, nil)
+
+		_, summary := querySummary(t, WithSources([]sources.Source{src1, src2}), WithParallelism(1))
+
+		assert.Equal(t, "example.com", summary.Domain)
+		assert.Equal(t, int64(2), summary.Results)
+		require.Len(t, summary.Sources, 2)
+		assert.Equal(t, "src1", summary.Sources[0].Name)
+		assert.Equal(t, "src2", summary.Sources[1].Name)
+
+		var raw, unique int64
+		for _, s := range summary.Sources {
+			assert.Equal(t, StatusRan, s.Status)
+			assert.Equal(t, 1, s.Runs)
+			raw += s.RawResults
+			unique += s.UniqueResults
+		}
+		assert.Equal(t, int64(3), raw)
+		assert.Equal(t, int64(2), unique)
+	})
+
+	t.Run("skipped_no_key", func(t *testing.T) {
+		authSrc := mockSource("auth-required", sources.Subdomain, nil, nil)
+		authSrc.AuthRequired = true
+
+		_, summary := querySummary(t, WithSources([]sources.Source{authSrc}))
+
+		stats := summary.Source("auth-required")
+		require.NotNil(t, stats)
+		assert.Equal(t, StatusSkippedNoKey, stats.Status)
+		assert.Zero(t, stats.Runs)
+	})
+
+	t.Run("failed_with_errors", func(t *testing.T) {
+		testErr := errors.New("failing: unexpected status 500")
+		src := mockSource("failing", sources.Subdomain, nil, []error{testErr})
+
+		_, summary := querySummary(t, WithSources([]sources.Source{src}))
+
+		stats := summary.Source("failing")
+		require.NotNil(t, stats)
+		assert.Equal(t, StatusFailed, stats.Status)
+		assert.Equal(t, []error{testErr}, stats.Errors)
+		assert.Equal(t, int64(1), summary.Errors)
+	})
+
+	t.Run("timeout_failed", func(t *testing.T) {
+		slow := sources.Source{
+			Name:   "slow",
+			Yields: sources.Subdomain,
+			Run: func(ctx context.Context, _ *http.Client, _ string, _ string) iter.Seq2[sources.Result, error] {
+				return func(_ func(sources.Result, error) bool) {
+					<-ctx.Done()
+				}
+			},
+		}
+
+		_, summary := querySummary(t, WithSources([]sources.Source{slow}), WithTimeout(10*time.Millisecond))
+
+		stats := summary.Source("slow")
+		require.NotNil(t, stats)
+		assert.Equal(t, StatusFailed, stats.Status)
+		require.Len(t, stats.Errors, 1)
+		assert.ErrorIs(t, stats.Errors[0], context.DeadlineExceeded)
+		assert.GreaterOrEqual(t, stats.Duration, 10*time.Millisecond)
+	})
+
+	t.Run("circuit_open", func(t *testing.T) {
+		breaker := NewCircuitBreaker(CircuitBreakerOptions{ConsecutiveFailures: 1})
+		breaker.record("src", errors.New("down"))
+		src := mockSource("src", sources.Subdomain, nil, nil)
+
+		_, summary := querySummary(t, WithSources([]sources.Source{src}), WithCircuitBreaker(breaker))
+
+		stats := summary.Source("src")
+		require.NotNil(t, stats)
+		assert.Equal(t, StatusCircuitOpen, stats.Status)
+	})
+
+	t.Run("cancelled_on_early_stop", func(t *testing.T) {
+		src := mockSource("src", sources.Subdomain, []sources.Result{
+			{Type: sources.Subdomain, Value: "a.example.com", Source: "src"},
+			{Type: sources.Subdomain, Value: "b.example.com", Source: "src"},
+		}, nil)
+
+		var summary *Summary
+		for range Query(t.Context(), "example.com",
+			WithSources([]sources.Source{src}),
+			WithSummary(func(s *Summary) { summary = s }),
+		) {
+			break
+		}
+
+		require.NotNil(t, summary)
+		stats := summary.Source("src")
+		require.NotNil(t, stats)
+		assert.Equal(t, StatusCancelled, stats.Status)
+		assert.Equal(t, int64(1), stats.UniqueResults)
+	})
+
+	t.Run("rate_limit", func(t *testing.T) {
+		limited := mockSource("limited", sources.Subdomain, nil, nil)
+		limited.RateLimit = 2
+		unlimited := mockSource("unlimited", sources.Subdomain, nil, nil)
+
+		var summary *Summary
+		_, err := Collect(Query(t.Context(), "example.com",
+			WithSources([]sources.Source{limited, unlimited}),
+			WithSourceRateLimit("unlimited", 0),
+			WithSummary(func(s *Summary) { summary = s }),
+		))
+		require.NoError(t, err)
+
+		require.NotNil(t, summary)
+		assert.InDelta(t, 2, summary.Source("limited").RateLimit, 0.001)
+		assert.Zero(t, summary.Source("unlimited").RateLimit)
+	})
+
+	t.Run("counts_requests_and_bytes", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte("0123456789"))
+		}))
+		t.Cleanup(server.Close)
+
+		fetching := sources.Source{
+			Name:   "fetching",
+			Yields: sources.Subdomain,
+			Run: func(ctx context.Context, client *http.Client, _ string, _ string) iter.Seq2[sources.Result, error] {
+				return func(yield func(sources.Result, error) bool) {
+					for range 2 {
+						req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+						if err != nil {
+							yield(sources.Result{}, err)
+							return
+						}
+						resp, err := client.Do(req)
+						if err != nil {
+							yield(sources.Result{}, err)
+							return
+						}
+						_, _ = io.Copy(io.Discard, resp.Body)
+						_ = resp.Body.Close()
+					}
+				}
+			},
+		}
+
+		_, summary := querySummary(t, WithSources([]sources.Source{fetching}), WithHTTPClient(server.Client()))
+
+		stats := summary.Source("fetching")
+		require.NotNil(t, stats)
+		assert.Equal(t, StatusRan, stats.Status)
+		assert.Equal(t, int64(2), stats.Requests)
+		assert.Equal(t, int64(20), stats.Bytes)
+	})
+
+	t.Run("combines_recursive_runs", func(t *testing.T) {
+		src, _ := recordingSource("deep", true, map[string][]string{
+			"example.com":      {"corp.example.com"},
+			"corp.example.com": {"vpn.corp.example.com"},
+		})
+
+		_, summary := querySummary(t, WithSources([]sources.Source{src}), WithRecursion(1, 0))
+
+		stats := summary.Source("deep")
+		require.NotNil(t, stats)
+		assert.Equal(t, 2, stats.Runs)
+		assert.Equal(t, int64(2), stats.UniqueResults)
+	})
+}
+
+func TestQueryURLReduction(t *testing.T) {
+	t.Parallel()
+
+	src := mockSource("src", sources.URL, []sources.Result{
+		{Type: sources.URL, Value: "https://example.com/product?id=1", Source: "src"},
+		{Type: sources.URL, Value: "https://example.com/product?id=2", Source: "src"},
+		{Type: sources.URL, Value: "https://example.com/product?id=3", Source: "src"},
+		{Type: sources.URL, Value: "https://example.com/blog/2021/05/", Source: "src"},
+		{Type: sources.URL, Value: "https://example.com/blog/2022/01/", Source: "src"},
+		{Type: sources.URL, Value: "https://example.com/logo.png", Source: "src"},
+		{Type: sources.Subdomain, Value: "api.example.com", Source: "src"},
+	}, nil)
+
+	results, summary := querySummary(t, WithSources([]sources.Source{src}), WithURLReduction(cluster.Options{Samples: 2}))
+
+	values := make([]string, 0, len(results))
+	for _, r := range results {
+		values = append(values, r.Value)
+	}
+	assert.Equal(t, []string{
+		"https://example.com/product?id=1",
+		"https://example.com/product?id=2",
+		"https://example.com/blog/2021/05/",
+		"https://example.com/blog/2022/01/",
+		"api.example.com",
+	}, values)
+	assert.Equal(t, int64(5), summary.Results)
+	assert.Equal(t, int64(1), summary.ExcludedURLs)
+	require.Len(t, summary.Clusters, 2)
+	assert.Equal(t, "example.com/product?id", summary.Clusters[0].Key)
+	assert.Equal(t, 3, summary.Clusters[0].Size)
+	assert.Equal(t, "example.com/blog/{int}/{int}/", summary.Clusters[1].Key)
+	assert.Equal(t, 2, summary.Clusters[1].Size)
+}
+
+func TestSummaryMarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	summary := Summary{
+		Domain:   "example.com",
+		Duration: 1500 * time.Millisecond,
+		Results:  2,
+		Sources: []SourceStats{{
+			Name:     "crtsh",
+			Status:   StatusFailed,
+			Runs:     1,
+			Duration: 250 * time.Millisecond,
+			Blocked:  100 * time.Millisecond,
+			Errors:   []error{errors.New("crtsh: unexpected status 502")},
+		}},
+	}
+
+	data, err := json.Marshal(summary)
+	require.NoError(t, err)
+
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.InDelta(t, 1500, decoded["duration_ms"], 0.001)
+	sources := decoded["sources"].([]any)
+	require.Len(t, sources, 1)
+	src := sources[0].(map[string]any)
+	assert.Equal(t, "failed", src["status"])
+	assert.InDelta(t, 250, src["duration_ms"], 0.001)
+	assert.InDelta(t, 100, src["blocked_ms"], 0.001)
+	assert.Equal(t, []any{"crtsh: unexpected status 502"}, src["errors"])
+}
+
+func TestSummaryUnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	summary := Summary{
+		Domain:   "example.com",
+		Started:  time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		Duration: 1500 * time.Millisecond,
+		Results:  2,
+		Errors:   1,
+		Sources: []SourceStats{{
+			Name:          "crtsh",
+			Status:        StatusFailed,
+			Runs:          1,
+			Requests:      3,
+			Bytes:         1024,
+			Duration:      250 * time.Millisecond,
+			Blocked:       40 * time.Millisecond,
+			RawResults:    4,
+			UniqueResults: 2,
+			RateLimit:     0.5,
+			Errors:        []error{errors.New("crtsh: unexpected status 502")},
+		}},
+		Clusters: []cluster.Cluster{{Key: "example.com/p?id", Host: "example.com", Template: "/p", Params: []string{"id"},
+			Size: 3, Samples: []string{"https://example.com/p?id=1"}}},
+		ExcludedURLs: 4,
+	}
+	data, err := json.Marshal(summary)
+	require.NoError(t, err)
+
+	var decoded Summary
+	require.NoError(t, json.Unmarshal(data, &decoded))
+
+	assert.Equal(t, summary, decoded)
+
+	t.Run("unknown_status", func(t *testing.T) {
+		var status SourceStatus
+		assert.ErrorContains(t, json.Unmarshal([]byte(`"paused"`), &status), `unknown source status "paused"`)
+	})
+}
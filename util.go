@@ -3,6 +3,9 @@ package scout
 import (
 	"errors"
 	"iter"
+	"net/http"
+	"strconv"
+	"time"
 )
 
 // Collect iterates over all results, collecting results without error.
@@ -20,3 +23,16 @@ func Collect[T any](seq iter.Seq2[T, error]) ([]T, error) {
 	}
 	return results, errors.Join(errs...)
 }
+
+// ParseRetryAfter reads a Retry-After header value, given as delay seconds or an HTTP date, as the time to retry at.
+// It returns false if the value is missing or invalid.
+func ParseRetryAfter(value string, now time.Time) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	} else if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return now.Add(time.Duration(seconds) * time.Second), true
+	} else if t, err := http.ParseTime(value); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
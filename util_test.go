@@ -3,7 +3,9 @@ package scout
 import (
 	"errors"
 	"iter"
+	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -91,3 +93,29 @@ func TestCollect(t *testing.T) {
 		})
 	}
 }
+
+func TestParseRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name   string
+		value  string
+		want   time.Time
+		wantOk bool
+	}{
+		{name: "seconds", value: "30", want: now.Add(30 * time.Second), wantOk: true},
+		{name: "zero", value: "0", want: now, wantOk: true},
+		{name: "date", value: now.Add(time.Minute).Format(http.TimeFormat), want: now.Add(time.Minute), wantOk: true},
+		{name: "negative", value: "-1"},
+		{name: "missing", value: ""},
+		{name: "invalid", value: "soon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseRetryAfter(tt.value, now)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.True(t, tt.want.Equal(got), "got %v", got)
+		})
+	}
+}
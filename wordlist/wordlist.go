@@ -0,0 +1,191 @@
+// Package wordlist extracts fuzzing inputs from discovered URLs: query parameter names, path segments,
+// file extensions, API endpoints, and JavaScript and JSON files, each with the number of URLs it appeared in.
+package wordlist
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/go-appsec/scout/cluster"
+	"github.com/go-appsec/scout/normalize"
+	"github.com/go-appsec/scout/sources"
+)
+
+// Kind identifies a list extracted from URLs.
+type Kind uint8
+
+const (
+	Params     Kind = iota // Query parameter names, such as "id"
+	Segments               // Path segments, excluding numeric, UUID, and hash segments, such as "login.php"
+	Extensions             // File extensions without the dot, lowercased, such as "php"
+	Endpoints              // API endpoints as URLs without query, with path templates, such as "https://example.com/api/v1/users/{int}"
+	Files                  // JavaScript and JSON files as URLs without query, such as "https://example.com/static/app.js"
+	kindCount
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Params:
+		return "params"
+	case Segments:
+		return "segments"
+	case Extensions:
+		return "extensions"
+	case Endpoints:
+		return "endpoints"
+	case Files:
+		return "files"
+	default:
+		return "unknown"
+	}
+}
+
+// Kinds returns every kind, in order.
+func Kinds() []Kind {
+	return []Kind{Params, Segments, Extensions, Endpoints, Files}
+}
+
+// ParseKind parses a kind from its string form.
+func ParseKind(s string) (Kind, error) {
+	for _, k := range Kinds() {
+		if k.String() == s {
+			return k, nil
+		}
+	}
+	return 0, fmt.Errorf("wordlist: unknown kind %q", s)
+}
+
+// Entry is an extracted value with the number of URLs it appeared in.
+type Entry struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+var (
+	// apiSegment matches path segments marking an API, such as "api", "rest", "graphql", or a version like "v2".
+	apiSegment = regexp.MustCompile(`^(?i:api|apis|rest|graphql|v[0-9]+(?:\.[0-9]+)?)$`)
+	// extension matches plausible file extensions, rejecting dots in version numbers and domain names.
+	extension = regexp.MustCompile(`^[a-z][a-z0-9]{0,7}$`)
+)
+
+// fileExtensions are the extensions of JavaScript and JSON files.
+var fileExtensions = map[string]bool{"js": true, "mjs": true, "cjs": true, "jsx": true, "json": true, "map": true}
+
+// Extractor accumulates lists from added URLs. It is safe for concurrent use.
+type Extractor struct {
+	mu     sync.Mutex
+	counts [kindCount]map[string]int
+	urls   int
+}
+
+// New returns an empty Extractor.
+func New() *Extractor {
+	e := &Extractor{}
+	for i := range e.counts {
+		e.counts[i] = make(map[string]int)
+	}
+	return e
+}
+
+// Add extracts values from the URL, reporting false if it is not an absolute URL.
+// Values are counted once per URL, however often they appear in it.
+func (e *Extractor) Add(rawURL string) bool {
+	normalized, err := normalize.URL(rawURL, normalize.URLOptions{})
+	if err != nil {
+		return false
+	}
+	u, err := url.Parse(normalized)
+	if err != nil {
+		return false
+	}
+
+	var found [kindCount][]string
+	for name := range u.Query() {
+		if name != "" {
+			found[Params] = append(found[Params], name)
+		}
+	}
+
+	var api bool
+	for segment := range strings.SplitSeq(strings.Trim(u.Path, "/"), "/") {
+		if segment == "" || cluster.Template(segment) != segment {
+			continue // numeric, UUID, and hash segments are identifiers rather than words
+		}
+		found[Segments] = append(found[Segments], segment)
+		api = api || apiSegment.MatchString(segment)
+	}
+
+	base := u.Scheme + "://" + u.Host
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(u.Path), "."))
+	if !extension.MatchString(ext) || strings.HasSuffix(u.Path, "/") {
+		ext = ""
+	}
+	if ext != "" {
+		found[Extensions] = append(found[Extensions], ext)
+	}
+	if api || ext == "json" {
+		found[Endpoints] = append(found[Endpoints], base+cluster.Template(u.EscapedPath()))
+	}
+	if fileExtensions[ext] {
+		found[Files] = append(found[Files], base+u.EscapedPath())
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.urls++
+	for kind, values := range found {
+		slices.Sort(values)
+		for _, v := range slices.Compact(values) {
+			e.counts[kind][v]++
+		}
+	}
+	return true
+}
+
+// AddResults adds the URL results of the sequence, such as from scout.Query or scout.URLs,
+// ignoring other result types. Errors are joined and returned once the sequence ends.
+func (e *Extractor) AddResults(seq iter.Seq2[sources.Result, error]) error {
+	var errs []error
+	for r, err := range seq {
+		if err != nil {
+			errs = append(errs, err)
+		} else if r.Type == sources.URL {
+			e.Add(r.Value)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// URLs returns the number of URLs added.
+func (e *Extractor) URLs() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.urls
+}
+
+// List returns the extracted values of the kind, most frequent first, then by value.
+func (e *Extractor) List(kind Kind) []Entry {
+	if kind >= kindCount {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	entries := make([]Entry, 0, len(e.counts[kind]))
+	for v, n := range e.counts[kind] {
+		entries = append(entries, Entry{Value: v, Count: n})
+	}
+	slices.SortFunc(entries, func(a, b Entry) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return strings.Compare(a.Value, b.Value)
+	})
+	return entries
+}
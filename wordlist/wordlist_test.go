@@ -0,0 +1,102 @@
+package wordlist
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-appsec/scout/sources"
+)
+
+func TestKind(t *testing.T) {
+	t.Parallel()
+
+	for _, k := range Kinds() {
+		t.Run(k.String(), func(t *testing.T) {
+			parsed, err := ParseKind(k.String())
+			require.NoError(t, err)
+			assert.Equal(t, k, parsed)
+		})
+	}
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := ParseKind("words")
+		assert.ErrorContains(t, err, `unknown kind "words"`)
+	})
+}
+
+func TestExtractor(t *testing.T) {
+	t.Parallel()
+
+	e := New()
+	for _, u := range []string{
+		"https://example.com/search.php?q=test&page=2&q=again",
+		"https://example.com/search.php?q=other",
+		"https://Example.com/api/v1/users/42?fields=name",
+		"https://example.com/api/v1/users/7",
+		"https://example.com/v2/orders/3f2c1a9e-8b7d-4c6e-9f10-2a3b4c5d6e7f",
+		"https://example.com/static/app.js?v=1",
+		"https://example.com/static/app.js?v=2",
+		"https://example.com/data/config.json",
+		"https://example.com/releases/1.2.3/",
+	} {
+		assert.True(t, e.Add(u), u)
+	}
+	assert.False(t, e.Add("/relative"))
+	assert.Equal(t, 9, e.URLs())
+
+	t.Run("params", func(t *testing.T) {
+		assert.Equal(t, []Entry{{"q", 2}, {"v", 2}, {"fields", 1}, {"page", 1}}, e.List(Params))
+	})
+
+	t.Run("segments", func(t *testing.T) {
+		assert.Equal(t, []Entry{
+			{"api", 2}, {"app.js", 2}, {"search.php", 2}, {"static", 2}, {"users", 2}, {"v1", 2},
+			{"1.2.3", 1}, {"config.json", 1}, {"data", 1}, {"orders", 1}, {"releases", 1}, {"v2", 1},
+		}, e.List(Segments))
+	})
+
+	t.Run("extensions", func(t *testing.T) {
+		assert.Equal(t, []Entry{{"js", 2}, {"php", 2}, {"json", 1}}, e.List(Extensions))
+	})
+
+	t.Run("endpoints", func(t *testing.T) {
+		assert.Equal(t, []Entry{
+			{"https://example.com/api/v1/users/{int}", 2},
+			{"https://example.com/data/config.json", 1},
+			{"https://example.com/v2/orders/{uuid}", 1},
+		}, e.List(Endpoints))
+	})
+
+	t.Run("files", func(t *testing.T) {
+		assert.Equal(t, []Entry{
+			{"https://example.com/static/app.js", 2},
+			{"https://example.com/data/config.json", 1},
+		}, e.List(Files))
+	})
+
+	t.Run("unknown_kind", func(t *testing.T) {
+		assert.Nil(t, e.List(kindCount))
+	})
+}
+
+func TestExtractorAddResults(t *testing.T) {
+	t.Parallel()
+
+	srcErr := errors.New("commoncrawl: unexpected status 503")
+	seq := func(yield func(sources.Result, error) bool) {
+		_ = yield(sources.Result{Type: sources.URL, Value: "https://example.com/?id=1"}, nil) &&
+			yield(sources.Result{Type: sources.Subdomain, Value: "api.example.com"}, nil) &&
+			yield(sources.Result{}, srcErr) &&
+			yield(sources.Result{Type: sources.URL, Value: "https://example.com/?id=2"}, nil)
+	}
+
+	e := New()
+	err := e.AddResults(seq)
+
+	assert.ErrorIs(t, err, srcErr)
+	assert.Equal(t, 2, e.URLs())
+	assert.Equal(t, []Entry{{"id", 2}}, e.List(Params))
+}